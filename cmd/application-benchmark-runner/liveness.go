@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application/benchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/application/latency"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/probe"
+	"github.com/christophwitzko/masters-thesis/pkg/retry"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	livenessNone  = "none"
+	livenessFlag  = "flag"
+	livenessAbort = "abort"
+)
+
+var errTargetDown = errors.New("target stopped answering")
+
+func setupProbeFlags(cmd *cobra.Command) {
+	defaultConfig := probe.DefaultConfig()
+	cmd.Flags().String("probe-path", defaultConfig.Path, "HTTP path of the readiness and liveness probe (if empty only the TCP port is checked)")
+	cmd.Flags().Int("probe-status", defaultConfig.ExpectedStatus, "expected HTTP status of the probe")
+	cmd.Flags().Duration("probe-timeout", defaultConfig.Timeout, "timeout of a single probe")
+	cmd.Flags().Duration("probe-interval", defaultConfig.Interval, "interval between probes")
+	cmd.Flags().Int("probe-failure-threshold", defaultConfig.FailureThreshold, "consecutive failed probes after which a target is considered down")
+	cmd.Flags().String("liveness", livenessFlag, "action if a target stops answering during the benchmark [none, flag or abort]")
+}
+
+func probeConfigFromFlags(cmd *cobra.Command) (probe.Config, string, error) {
+	probeConf := probe.Config{
+		Path:             cli.MustGetString(cmd, "probe-path"),
+		ExpectedStatus:   cli.MustGetInt(cmd, "probe-status"),
+		Timeout:          cli.MustGetDuration(cmd, "probe-timeout"),
+		Interval:         cli.MustGetDuration(cmd, "probe-interval"),
+		FailureThreshold: cli.MustGetInt(cmd, "probe-failure-threshold"),
+	}
+	liveness := cli.MustGetString(cmd, "liveness")
+	switch liveness {
+	case livenessNone, livenessFlag, livenessAbort:
+	default:
+		return probe.Config{}, "", fmt.Errorf("invalid liveness action: %s", liveness)
+	}
+	return probeConf, liveness, probeConf.Validate()
+}
+
+func waitForTargets(ctx context.Context, log *logger.Logger, probeConf probe.Config, targets []*benchmark.TargetInfo) error {
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	for _, targetInfo := range targets {
+		targetInfo := targetInfo
+		errGroup.Go(func() error {
+			log.Infof("waiting for target %s (%s%s)", targetInfo.Name, targetInfo.Endpoint, probeConf.Path)
+			return probeConf.WaitReady(groupCtx, targetInfo.Endpoint)
+		})
+	}
+	return errGroup.Wait()
+}
+
+// livenessMonitor probes all targets while the load is generated and records
+// when a target stops or starts answering again.
+type livenessMonitor struct {
+	log      *logger.Logger
+	conf     probe.Config
+	action   string
+	recorder *probe.Recorder
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// start monitors the targets until stop is called. In abort mode abort is
+// called as soon as a target is down.
+func (m *livenessMonitor) start(ctx context.Context, targets []*benchmark.TargetInfo, abort context.CancelCauseFunc) {
+	if m.action == livenessNone {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.recorder = &probe.Recorder{}
+	m.done = make(chan struct{})
+	group := errgroup.Group{}
+	for _, targetInfo := range targets {
+		targetInfo := targetInfo
+		group.Go(func() error {
+			m.conf.Monitor(ctx, targetInfo.Name, targetInfo.Endpoint, func(e probe.Event) {
+				m.recorder.Record(e)
+				if e.Alive {
+					m.log.Warnf("[liveness] target %s is answering again", e.Target)
+					return
+				}
+				m.log.Errorf("[liveness] target %s stopped answering: %s", e.Target, e.Error)
+				if m.action == livenessAbort {
+					abort(fmt.Errorf("%w: %s at %s: %s", errTargetDown, e.Target, e.Time.Format("15:04:05"), e.Error))
+				}
+			})
+			return nil
+		})
+	}
+	go func() {
+		_ = group.Wait()
+		close(m.done)
+	}()
+}
+
+// stop stops the monitors and stores the recorded events next to the
+// benchmark results. It returns an error if the load was aborted because a
+// target stopped answering.
+func (m *livenessMonitor) stop(ctx, loadCtx context.Context, benchConf *benchmark.Config) error {
+	if m.action == livenessNone {
+		return nil
+	}
+	m.cancel()
+	<-m.done
+	if err := m.storeEvents(ctx, benchConf); err != nil {
+		m.log.Errorf("[liveness] failed to store events: %v", err)
+	}
+	if cause := context.Cause(loadCtx); errors.Is(cause, errTargetDown) {
+		return fmt.Errorf("benchmark aborted: %w", cause)
+	}
+	return nil
+}
+
+func (m *livenessMonitor) storeEvents(ctx context.Context, benchConf *benchmark.Config) error {
+	events := m.recorder.Events()
+	if len(events) == 0 {
+		m.log.Infof("[liveness] all targets answered during the benchmark")
+		return nil
+	}
+	m.log.Warnf("[liveness] run flagged: %d liveness changes recorded", len(events))
+	buf := &bytes.Buffer{}
+	if err := m.recorder.WriteCSV(buf); err != nil {
+		return err
+	}
+	livenessFile := filepath.Join(benchConf.ConfigDir, latency.LivenessFile)
+	if err := os.WriteFile(livenessFile, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if !benchConf.HasOutput() {
+		m.log.Infof("[liveness] events written to %s", livenessFile)
+		return nil
+	}
+	err := retry.OnError(ctx, m.log, "[liveness]", func() error {
+		return benchConf.UploadToBucketFromFile(ctx, latency.LivenessFile, livenessFile)
+	})
+	if err != nil {
+		return err
+	}
+	m.log.Infof("[liveness] events uploaded to %s", benchConf.GetOutputObjectName(latency.LivenessFile))
+	return nil
+}
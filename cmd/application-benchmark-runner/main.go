@@ -12,7 +12,7 @@ import (
 	"github.com/christophwitzko/masters-thesis/pkg/application/benchmark"
 	"github.com/christophwitzko/masters-thesis/pkg/cli"
 	"github.com/christophwitzko/masters-thesis/pkg/logger"
-	"github.com/christophwitzko/masters-thesis/pkg/netutil"
+	"github.com/christophwitzko/masters-thesis/pkg/metrics"
 	"github.com/christophwitzko/masters-thesis/pkg/profile"
 	"github.com/christophwitzko/masters-thesis/pkg/retry"
 	"github.com/christophwitzko/masters-thesis/pkg/setup"
@@ -50,6 +50,8 @@ func main() {
 	rootCmd.Flags().Duration("profiling-duration", 30*time.Second, "profiling duration")
 	rootCmd.Flags().String("tool", "artillery", "tool to run the benchmarks [artillery or k6]")
 	rootCmd.Flags().StringArray("env", []string{}, "additional environment variables to set")
+	rootCmd.Flags().Int("metrics-port", 0, "if set exposes prometheus metrics on this port")
+	setupProbeFlags(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -148,18 +150,6 @@ func runContinuousProfiler(ctx context.Context, log *logger.Logger, benchConf *b
 	}
 }
 
-func waitForTargets(ctx context.Context, log *logger.Logger, targets []*benchmark.TargetInfo) error {
-	errGroup, groupCtx := errgroup.WithContext(ctx)
-	for _, targetInfo := range targets {
-		targetInfo := targetInfo
-		errGroup.Go(func() error {
-			log.Infof("waiting for target %s (%s)", targetInfo.Name, targetInfo.Endpoint)
-			return netutil.WaitForPortOpen(groupCtx, targetInfo.Endpoint)
-		})
-	}
-	return errGroup.Wait()
-}
-
 //gocyclo:ignore
 func rootRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
 	referenceOrPath := cli.MustGetString(cmd, "reference")
@@ -177,6 +167,12 @@ func rootRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
 	}
 	appBenchTool := strings.ToLower(cli.MustGetString(cmd, "tool"))
 	envConfig := cli.MustGetStringArray(cmd, "env")
+	metricsPort := cli.MustGetInt(cmd, "metrics-port")
+
+	probeConf, livenessAction, err := probeConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
 
 	if appBenchTool != "artillery" && appBenchTool != "k6" {
 		return fmt.Errorf("invalid benchmark tool: %s", appBenchTool)
@@ -227,14 +223,35 @@ func rootRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
 	}
 	targets := parseInputTargets(appBenchConfig, inputTargets)
 
+	var appBenchMetrics *metrics.ApplicationBenchmarkMetrics
+	if metricsPort > 0 {
+		registry := metrics.NewRegistry()
+		appBenchMetrics = metrics.NewApplicationBenchmarkMetrics(registry)
+		metrics.Start(ctx, log, metricsPort, registry)
+		observers := make(map[string]func(line string), len(targets))
+		for _, targetInfo := range targets {
+			appBenchMetrics.SetTargetStatus(targetInfo.Name, metrics.TargetStatusWaiting)
+			observers[targetInfo.Name] = appBenchMetrics.OutputObserver(targetInfo.Name)
+			go appBenchMetrics.MonitorTarget(ctx, targetInfo.Name, targetInfo.Endpoint, 5*time.Second)
+		}
+		appBenchConfig.OutputObserver = func(targetName, line string) {
+			observers[targetName](line)
+		}
+	}
+
 	log.Info("waiting for targets to be ready....")
-	err = waitForTargets(ctx, log, targets)
+	err = waitForTargets(ctx, log, probeConf, targets)
 	if err != nil {
 		return err
 	}
 
 	log.Infof("starting %s...", appBenchTool)
-	errGroup, groupCtx := errgroup.WithContext(ctx)
+	// the load context is aborted if a target stops answering (liveness=abort)
+	loadCtx, abortLoad := context.WithCancelCause(ctx)
+	defer abortLoad(nil)
+	liveness := &livenessMonitor{log: log, conf: probeConf, action: livenessAction}
+	liveness.start(loadCtx, targets, abortLoad)
+	errGroup, groupCtx := errgroup.WithContext(loadCtx)
 	// the profile context is linked to the group error group
 	// with their own cancel function
 	profileCtx, cancelProfile := context.WithCancel(groupCtx)
@@ -242,7 +259,17 @@ func rootRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
 	for _, targetInfo := range targets {
 		targetInfo := targetInfo
 		errGroup.Go(func() error {
-			return benchmark.Run(groupCtx, log, appBenchConfig, targetInfo)
+			if appBenchMetrics == nil {
+				return benchmark.Run(groupCtx, log, appBenchConfig, targetInfo)
+			}
+			appBenchMetrics.SetTargetStatus(targetInfo.Name, metrics.TargetStatusRunning)
+			runErr := benchmark.Run(groupCtx, log, appBenchConfig, targetInfo)
+			if runErr != nil {
+				appBenchMetrics.SetTargetStatus(targetInfo.Name, metrics.TargetStatusFailed)
+			} else {
+				appBenchMetrics.SetTargetStatus(targetInfo.Name, metrics.TargetStatusFinished)
+			}
+			return runErr
 		})
 		if shouldProfile {
 			profileGroup.Go(func() error {
@@ -252,6 +279,9 @@ func rootRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
 	}
 	err = errGroup.Wait()
 	cancelProfile()
+	if livenessErr := liveness.stop(ctx, loadCtx, appBenchConfig); livenessErr != nil {
+		return livenessErr
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application/control"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+func setupControlFlags(cmd *cobra.Command) {
+	cmd.Flags().String("control-address", "", "address of the HTTP/JSON control API (disabled if empty) [e.g. 127.0.0.1:7000]")
+	cmd.Flags().String("control-token-file", "", "file with the bearer token required by the control API")
+}
+
+type controlConfig struct {
+	address string
+	token   string
+}
+
+// controlFromFlags returns the config of the control API or nil if it is
+// disabled.
+func controlFromFlags(cmd *cobra.Command) (*controlConfig, error) {
+	address := cli.MustGetString(cmd, "control-address")
+	if address == "" {
+		return nil, nil
+	}
+	tokenFile := cli.MustGetString(cmd, "control-token-file")
+	if tokenFile == "" {
+		return nil, errors.New("--control-token-file is required for the control API")
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read control token: %w", err)
+	}
+	conf := &controlConfig{address: address, token: strings.TrimSpace(string(token))}
+	if conf.token == "" {
+		return nil, errors.New("control token is empty")
+	}
+	return conf, nil
+}
+
+// startControlServer serves the control API of the runner if it is enabled.
+// The returned function stops the server.
+func startControlServer(log *logger.Logger, conf *controlConfig, runner control.Runner) (func() error, error) {
+	if conf == nil {
+		return func() error { return nil }, nil
+	}
+	listener, err := net.Listen("tcp", conf.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for control API: %w", err)
+	}
+	server := &http.Server{
+		Handler:           control.NewHandler(runner, conf.token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("control API failed: %v", err)
+		}
+	}()
+	log.Infof("-> control API listening on %s", listener.Addr())
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}, nil
+}
@@ -2,18 +2,20 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sync"
+	"time"
 
 	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/application/timeslice"
 	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
 	"github.com/christophwitzko/masters-thesis/pkg/cli"
 	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/christophwitzko/masters-thesis/pkg/netutil"
 	"github.com/christophwitzko/masters-thesis/pkg/setup"
-	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 )
@@ -23,46 +25,197 @@ func main() {
 	rootCmd := &cobra.Command{
 		Use:   "application-runner",
 		Short: "application runner tool",
-		Long:  "This tool builds and runs two versions of an application concurrently.",
-		Args:  cobra.NoArgs,
-		Run:   cli.WrapRunE(log, rootRun),
+		Long: `This tool builds and runs several labelled versions of an application concurrently.
+
+Each version gets its own port of the port range. Once all versions are built,
+a manifest of the versions and their endpoints is printed to stdout as a
+single line prefixed with "` + application.ManifestPrefix + `".`,
+		Args: cobra.NoArgs,
+		Run:  cli.WrapRunE(log, rootRun),
 		CompletionOptions: cobra.CompletionOptions{
 			DisableDefaultCmd: true,
 		},
 	}
-	rootCmd.Flags().String("v1", "", "source path or git reference for version 1")
-	rootCmd.Flags().String("v2", "", "source path or git reference for version 2")
+	rootCmd.Flags().String("v1", "", "source path or git reference for version 1 (shorthand for --version v1=...)")
+	rootCmd.Flags().String("v2", "", "source path or git reference for version 2 (shorthand for --version v2=...)")
+	rootCmd.Flags().StringArray("version", []string{}, "labelled version of the application as label=source path or git reference")
 	rootCmd.Flags().String("git-repository", "", "git repository to use for installing the applications")
 	rootCmd.Flags().String("application-directory", "/tmp/.application", "directory to use for running the application")
 	rootCmd.Flags().String("application-package", "./", "package that should be build and run")
+	rootCmd.Flags().String("commands-file", "", "YAML file with custom build steps and run command templates (defaults to go build)")
 	rootCmd.Flags().String("bind", "127.0.0.1", "bind address")
+	rootCmd.Flags().String("port-range", "3000-3010", "range of ports assigned to the versions")
+	rootCmd.Flags().String("manifest", "", "also write the manifest to this file")
 	rootCmd.Flags().StringArray("env", []string{}, "environment variable to set")
 	rootCmd.Flags().Bool("limit-cpu", false, "grant each version an equal share of the CPU")
+	setupStatsFlags(rootCmd)
+	setupMemoryEventFlags(rootCmd)
+	setupResultsFlags(rootCmd)
+	setupControlFlags(rootCmd)
+	setupTimeSliceFlags(rootCmd)
+	rootCmd.Flags().String("resources-file", "", "YAML file with the cgroup resource profiles of the versions (implies cgroups)")
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
 
+func versionsFromFlags(cmd *cobra.Command) ([]application.Version, error) {
+	versions := make([]string, 0)
+	for _, name := range []string{"v1", "v2"} {
+		if sourcePathOrRef := cli.MustGetString(cmd, name); sourcePathOrRef != "" {
+			versions = append(versions, fmt.Sprintf("%s=%s", name, sourcePathOrRef))
+		}
+	}
+	versions = append(versions, cli.MustGetStringArray(cmd, "version")...)
+	return application.ParseVersions(versions)
+}
+
+func commandsFromFlags(cmd *cobra.Command) (*application.Commands, error) {
+	commands := &application.Commands{}
+	if commandsFile := cli.MustGetString(cmd, "commands-file"); commandsFile != "" {
+		f, err := os.Open(commandsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		commands, err = application.ReadCommands(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return commands, commands.Validate(cli.MustGetString(cmd, "application-package"))
+}
+
+// resourcesFromFlags returns the resource profiles of the versions or nil if
+// no cgroups should be used.
+func resourcesFromFlags(cmd *cobra.Command) (*cgroups.ResourceConfig, error) {
+	resourcesFile := cli.MustGetString(cmd, "resources-file")
+	if resourcesFile == "" {
+		if cli.MustGetBool(cmd, "limit-cpu") {
+			return cgroups.DefaultResourceConfig(), nil
+		}
+		return nil, checkNoMonitorFlags(cmd)
+	}
+	f, err := os.Open(resourcesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return cgroups.ReadResourceConfig(f)
+}
+
+// runOptions configure how the versions are run once they are built.
+type runOptions struct {
+	results *runResults
+	// control is nil if the control API is disabled.
+	control *controlConfig
+	// timeSlice is nil if the versions run concurrently.
+	timeSlice *timeslice.Config
+}
+
+func runOptionsFromFlags(log *logger.Logger, cmd *cobra.Command, applicationDirectory string, useCgroups bool) (*runOptions, error) {
+	results, err := newRunResults(log, cmd, applicationDirectory)
+	if err != nil {
+		return nil, err
+	}
+	controlConf, err := controlFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	timeSliceConf, err := timeSliceFromFlags(cmd, useCgroups)
+	if err != nil {
+		return nil, err
+	}
+	return &runOptions{results: results, control: controlConf, timeSlice: timeSliceConf}, nil
+}
+
+// allocatePorts assigns each version a free port of the port range. The
+// returned allocator hands out the remaining ports.
+func allocatePorts(cmd *cobra.Command, bindAddress string, versions []application.Version) (*application.PortAllocator, error) {
+	portRange, err := application.ParsePortRange(cli.MustGetString(cmd, "port-range"))
+	if err != nil {
+		return nil, err
+	}
+	portAllocator := portRange.Allocator(bindAddress, func(endpoint string) bool {
+		return netutil.IsPortOpen(endpoint, time.Second)
+	})
+	return portAllocator, portAllocator.AllocateVersions(versions)
+}
+
+// announceVersions reports the manifest of the versions once they are built.
+// router is only set if the versions run in time slices.
+func announceVersions(log *logger.Logger, cmd *cobra.Command, versions []application.Version, router *application.Version) error {
+	manifest := &application.Manifest{Versions: versions, Router: router}
+	if err := writeManifest(manifest, cli.MustGetString(cmd, "manifest")); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	for _, v := range versions {
+		log.Infof("-> %s (%s) listening on %s", v.Name, v.Source, v.Endpoint())
+	}
+	if router != nil {
+		log.Infof("-> time slices of all versions routed via %s", router.Endpoint())
+	}
+	return nil
+}
+
+func writeManifest(manifest *application.Manifest, manifestFile string) error {
+	line, err := manifest.Line()
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(os.Stdout, line+"\n"); err != nil {
+		return err
+	}
+	if manifestFile == "" {
+		return nil
+	}
+	f, err := os.Create(manifestFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return manifest.Write(f)
+}
+
 func rootRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
-	sourcePathOrRefV1 := cli.MustGetString(cmd, "v1")
-	sourcePathOrRefV2 := cli.MustGetString(cmd, "v2")
 	gitRepository := cli.MustGetString(cmd, "git-repository")
 	applicationDirectory := cli.MustGetString(cmd, "application-directory")
 	applicationPackage := cli.MustGetString(cmd, "application-package")
 	bindAddress := cli.MustGetString(cmd, "bind")
 	envVars := cli.MustGetStringArray(cmd, "env")
-	limitCPU := cli.MustGetBool(cmd, "limit-cpu")
 
-	if limitCPU {
-		log.Infof("setting up cgroups...")
-		err := cgroups.Setup()
-		if err != nil {
-			return err
-		}
+	versions, err := versionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	commands, err := commandsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	resources, err := resourcesFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	useCgroups := resources != nil
+	opts, err := runOptionsFromFlags(log, cmd, applicationDirectory, useCgroups)
+	if err != nil {
+		return err
+	}
+	portAllocator, err := allocatePorts(cmd, bindAddress, versions)
+	if err != nil {
+		return err
+	}
+	slicing, err := newTimeSlicing(log, opts.timeSlice, portAllocator, bindAddress, versions, opts.results.runConf.OutputDir)
+	if err != nil {
+		return err
 	}
 
-	sourcePathV1, sourcePathV2, err := setup.SourcePaths(log, applicationDirectory, gitRepository, sourcePathOrRefV1, sourcePathOrRefV2)
+	if err := setupCgroups(log, resources, versions); err != nil {
+		return err
+	}
+
+	sourcePaths, err := setup.VersionSourcePaths(log, applicationDirectory, gitRepository, versions)
 	if err != nil {
 		return err
 	}
@@ -70,54 +223,121 @@ func rootRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
 	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
 	defer cancel()
 
-	execFileV1 := filepath.Join(sourcePathV1, "v1")
-	execFileV2 := filepath.Join(sourcePathV2, "v2")
-	buildGroup, buildCtx := errgroup.WithContext(ctx)
-	buildGroup.Go(func() error {
-		return application.Build(buildCtx, log, sourcePathV1, applicationPackage, execFileV1)
-	})
-	buildGroup.Go(func() error {
-		return application.Build(buildCtx, log, sourcePathV2, applicationPackage, execFileV2)
-	})
-	err = buildGroup.Wait()
+	templateData := versionTemplateData(versions, sourcePaths, applicationPackage)
+	err = assignSidecars(templateData, commands.Sidecars, portAllocator, applicationDirectory)
+	if err != nil {
+		return err
+	}
+	err = buildVersions(ctx, log, commands.BuildCommands(), templateData)
 	if err != nil {
 		return err
 	}
 	log.Info("-> all builds finished successfully")
 
-	var mErrMutex sync.Mutex
-	var mErr error
-	wg := sync.WaitGroup{}
-	startPort := 3000
-	for i, execFile := range []string{execFileV1, execFileV2} {
-		wg.Add(1)
-		go func(i int, execFile string) {
-			defer wg.Done()
-			runEnv := append([]string{
-				fmt.Sprintf("BIND_ADDRESS=%s:%d", bindAddress, startPort+i),
-			}, envVars...)
-			vName := fmt.Sprintf("v%d", i+1)
-			var pidCb application.PidCallbackFunc
-			if limitCPU {
-				pidCb = func(pid int) error {
-					log.Infof("|%s| setting up cgroup for pid %d", vName, pid)
-					return cgroups.AddProcess(vName, pid)
-				}
+	if err := announceVersions(log, cmd, versions, slicing.routerTarget()); err != nil {
+		return err
+	}
+
+	sup := &supervisor{
+		log:                  log,
+		commands:             commands,
+		envVars:              envVars,
+		useCgroups:           useCgroups,
+		runConf:              opts.results.runConf,
+		applicationDirectory: applicationDirectory,
+		gitRepository:        gitRepository,
+		keepAlive:            opts.control != nil,
+		shutdown:             cancel,
+		processes:            newVersionProcesses(templateData),
+	}
+	return runWithMonitors(ctx, log, cmd, useCgroups, versions, func(ctx context.Context) error {
+		return sup.run(ctx, opts, slicing)
+	})
+}
+
+func setupCgroups(log *logger.Logger, resources *cgroups.ResourceConfig, versions []application.Version) error {
+	if resources == nil {
+		return nil
+	}
+	log.Infof("setting up cgroups...")
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = v.Name
+	}
+	return cgroups.Setup(resources, names...)
+}
+
+func versionTemplateData(versions []application.Version, sourcePaths []string, applicationPackage string) []application.TemplateData {
+	templateData := make([]application.TemplateData, len(versions))
+	for i, v := range versions {
+		sourcePath := cli.GetAbsolutePath(sourcePaths[i])
+		templateData[i] = application.TemplateData{
+			Version:    v,
+			SourcePath: sourcePath,
+			ExecFile:   application.ExecFilePath(sourcePath, v.Name),
+			Package:    applicationPackage,
+		}
+	}
+	return templateData
+}
+
+// assignSidecars allocates a port and creates a data directory for each
+// sidecar of each version.
+func assignSidecars(templateData []application.TemplateData, sidecars []application.Sidecar, portAllocator *application.PortAllocator, applicationDirectory string) error {
+	for i := range templateData {
+		templateData[i].Sidecars = make(map[string]application.SidecarInstance, len(sidecars))
+		for _, sidecar := range sidecars {
+			port, err := portAllocator.Next()
+			if err != nil {
+				return fmt.Errorf("failed to allocate port for sidecar %s of %s: %w", sidecar.Name, templateData[i].Name, err)
+			}
+			dataDir := filepath.Join(cli.GetAbsolutePath(applicationDirectory), "sidecars", templateData[i].Name, sidecar.Name)
+			if err := setup.CreateDirectory(dataDir); err != nil {
+				return err
 			}
-			appErr := application.Run(ctx, log, execFile, runEnv, pidCb)
-			if appErr != nil {
-				log.Warnf("-> application %s exited with error: %v", execFile, appErr)
-				mErrMutex.Lock()
-				mErr = multierror.Append(mErr, appErr)
-				mErrMutex.Unlock()
+			templateData[i].Sidecars[sidecar.Name] = application.SidecarInstance{
+				Name:    sidecar.Name,
+				Host:    "127.0.0.1",
+				Port:    port,
+				DataDir: dataDir,
 			}
-		}(i, execFile)
+		}
 	}
-	wg.Wait()
+	return nil
+}
 
-	if errors.Is(mErr, context.Canceled) {
-		log.Warnf("-> applications stopped")
-		return nil
+func buildVersions(ctx context.Context, log *logger.Logger, steps []application.Command, templateData []application.TemplateData) error {
+	buildGroup, buildCtx := errgroup.WithContext(ctx)
+	for _, data := range templateData {
+		data := data
+		buildGroup.Go(func() error {
+			return application.Build(buildCtx, log, steps, data)
+		})
+	}
+	return buildGroup.Wait()
+}
+
+// runVersion starts the sidecars of the version, runs the version until the
+// context is done and stops the sidecars afterwards.
+func runVersion(ctx context.Context, log *logger.Logger, commands *application.Commands, data application.TemplateData, envVars []string, useCgroups bool, runConf application.RunConfig) (*application.ExitStatus, error) {
+	var pidCb application.PidCallbackFunc
+	if useCgroups {
+		pidCb = func(pid int) error {
+			log.Infof("|%s| setting up cgroup for pid %d", data.Name, pid)
+			return cgroups.AddProcess(data.Name, pid)
+		}
+	}
+	sidecarEnv, stopSidecars, err := application.StartSidecars(ctx, log, commands.Sidecars, data, pidCb)
+	if err != nil {
+		return nil, err
+	}
+	runEnv := append([]string{
+		fmt.Sprintf("BIND_ADDRESS=%s", data.Endpoint()),
+	}, envVars...)
+	runEnv = append(runEnv, sidecarEnv...)
+	status, appErr := application.Run(ctx, log, commands.RunCommand(), data, runEnv, pidCb, runConf)
+	if len(commands.Sidecars) != 0 {
+		log.Infof("|%s| stopping sidecars...", data.Name)
 	}
-	return err
+	return status, merror.MaybeMultiError(appErr, stopSidecars())
 }
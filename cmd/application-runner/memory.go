@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/spf13/cobra"
+)
+
+// errMemoryEvent is the cause of a run that was stopped by a memory event.
+var errMemoryEvent = errors.New("memory event")
+
+func setupMemoryEventFlags(cmd *cobra.Command) {
+	cmd.Flags().String("memory-events-output", "", "output for the memory events (high, max, oom, oom_kill) of the versions as csv [e.g. gs://ab-results/app/memory-events.csv]")
+	cmd.Flags().Bool("fail-on-oom", false, "stop all versions if a version runs out of memory or is OOM-killed")
+	cmd.Flags().Int("fail-on-memory-high", 0, "stop all versions if a version exceeds memory.high this many times (0 disables)")
+}
+
+// memoryEventWatcher logs the memory events of all versions, records them for
+// the memory events output and stops the run on OOM if configured.
+type memoryEventWatcher struct {
+	log        *logger.Logger
+	recorder   *cgroups.MemoryEventRecorder
+	out        io.WriteCloser
+	failOnOOM  bool
+	failOnHigh int
+	stopRun    context.CancelCauseFunc
+}
+
+func newMemoryEventWatcher(log *logger.Logger, cmd *cobra.Command, stopRun context.CancelCauseFunc) (*memoryEventWatcher, error) {
+	w := &memoryEventWatcher{
+		log:        log,
+		recorder:   &cgroups.MemoryEventRecorder{},
+		failOnOOM:  cli.MustGetBool(cmd, "fail-on-oom"),
+		failOnHigh: cli.MustGetInt(cmd, "fail-on-memory-high"),
+		stopRun:    stopRun,
+	}
+	if w.failOnHigh < 0 {
+		return nil, fmt.Errorf("invalid memory high threshold: %d", w.failOnHigh)
+	}
+	if location := cli.MustGetString(cmd, "memory-events-output"); location != "" {
+		// the output is not bound to the context, so that the events are still
+		// uploaded after the applications were stopped
+		out, format, err := output.NewRawWriter(context.Background(), location, "csv")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open memory events output %s: %w", location, err)
+		}
+		if format != "csv" {
+			_ = out.Close()
+			return nil, fmt.Errorf("unsupported memory events format: %s", format)
+		}
+		w.out = out
+	}
+	return w, nil
+}
+
+func (w *memoryEventWatcher) onEvent(e cgroups.MemoryEvent) {
+	w.recorder.Record(e)
+	if e.Kind == cgroups.MemoryEventHigh {
+		w.log.Warnf("|%s| %s", e.Version, e)
+	} else {
+		w.log.Errorf("|%s| !!! %s", e.Version, e)
+	}
+	switch {
+	case w.failOnOOM && e.IsOOM():
+		w.stopRun(fmt.Errorf("%w: %s ran out of memory (%s)", errMemoryEvent, e.Version, e.Kind))
+	case w.failOnHigh > 0 && e.High >= uint64(w.failOnHigh):
+		w.stopRun(fmt.Errorf("%w: %s exceeded memory.high %d times", errMemoryEvent, e.Version, e.High))
+	}
+}
+
+// start watches the memory events until the returned stop function is called.
+func (w *memoryEventWatcher) start(ctx context.Context, versions []application.Version) func() error {
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = v.Name
+	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cgroups.WatchMemoryEvents(watchCtx, names, w.onEvent)
+	}()
+	return func() error {
+		cancel()
+		return merror.MaybeMultiError(<-errCh, w.writeEvents())
+	}
+}
+
+func (w *memoryEventWatcher) writeEvents() error {
+	if w.out == nil {
+		return nil
+	}
+	if err := w.recorder.WriteCSV(w.out); err != nil {
+		_ = w.out.Close()
+		return fmt.Errorf("failed to write memory events: %w", err)
+	}
+	return w.out.Close()
+}
+
+// checkNoMonitorFlags returns an error if a cgroup monitor is configured
+// although no cgroups are used.
+func checkNoMonitorFlags(cmd *cobra.Command) error {
+	for _, name := range []string{"cgroup-stats-output", "memory-events-output", "fail-on-oom", "fail-on-memory-high"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s requires --limit-cpu or --resources-file", name)
+		}
+	}
+	return nil
+}
+
+// runWithMonitors samples the cgroup stats and watches the memory events of
+// the versions while run is running. A memory event that stops the run is
+// returned as its error.
+func runWithMonitors(ctx context.Context, log *logger.Logger, cmd *cobra.Command, useCgroups bool, versions []application.Version, run func(ctx context.Context) error) error {
+	if !useCgroups {
+		return run(ctx)
+	}
+	runCtx, stopRun := context.WithCancelCause(ctx)
+	defer stopRun(nil)
+	watcher, err := newMemoryEventWatcher(log, cmd, stopRun)
+	if err != nil {
+		return err
+	}
+	stopStats, err := startStatsSampler(ctx, log, cmd, versions)
+	if err != nil {
+		return merror.MaybeMultiError(err, watcher.writeEvents())
+	}
+	stopWatcher := watcher.start(ctx, versions)
+	runErr := run(runCtx)
+	if cause := context.Cause(runCtx); errors.Is(cause, errMemoryEvent) {
+		runErr = cause
+	}
+	return merror.MaybeMultiError(runErr, stopWatcher(), stopStats())
+}
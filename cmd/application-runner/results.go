@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/christophwitzko/masters-thesis/pkg/retry"
+	"github.com/spf13/cobra"
+)
+
+const exitStatusFileName = "exit-status.json"
+
+func setupResultsFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("grace-period", 10*time.Second, "time the versions have to exit after SIGTERM before they are killed (0 kills immediately)")
+	cmd.Flags().String("output-dir", "", "directory for the captured stdout and stderr and the exit status of the versions (default <application-directory>/output)")
+	cmd.Flags().String("results-output", "", "location the captured output and exit status are uploaded to after the versions stopped [e.g. gs://ab-results/app]")
+}
+
+// runResults captures the output of the versions and reports their exit
+// status.
+type runResults struct {
+	log     *logger.Logger
+	runConf application.RunConfig
+	output  string
+}
+
+func newRunResults(log *logger.Logger, cmd *cobra.Command, applicationDirectory string) (*runResults, error) {
+	r := &runResults{
+		log: log,
+		runConf: application.RunConfig{
+			GracePeriod: cli.MustGetDuration(cmd, "grace-period"),
+			OutputDir:   cli.MustGetString(cmd, "output-dir"),
+		},
+		output: strings.TrimSuffix(cli.MustGetString(cmd, "results-output"), "/"),
+	}
+	if r.runConf.GracePeriod < 0 {
+		return nil, fmt.Errorf("invalid grace period: %s", r.runConf.GracePeriod)
+	}
+	if r.runConf.OutputDir == "" {
+		r.runConf.OutputDir = filepath.Join(applicationDirectory, "output")
+	}
+	if r.output != "" {
+		u, err := url.Parse(r.output)
+		if err != nil {
+			return nil, fmt.Errorf("invalid results output: %w", err)
+		}
+		if u.Scheme != "" && !output.IsValidSchema(u.Scheme) {
+			return nil, fmt.Errorf("unsupported results output schema: %s", u.Scheme)
+		}
+	}
+	return r, nil
+}
+
+// report logs the exit status of all versions, writes it next to the
+// captured output and uploads the files and the additional files to the
+// results output.
+func (r *runResults) report(statuses []*application.ExitStatus, additionalFiles ...string) error {
+	for _, status := range statuses {
+		switch {
+		case status.ExitCode == 0 || status.Stopped && status.Signal == syscall.SIGTERM.String():
+			r.log.Infof("-> %s exited: %s", status.Name, status)
+		case status.Stopped:
+			r.log.Warnf("-> %s exited: %s", status.Name, status)
+		default:
+			r.log.Errorf("-> %s exited: %s: %s", status.Name, status, status.Error)
+		}
+	}
+	exitStatusFile := filepath.Join(r.runConf.OutputDir, exitStatusFileName)
+	if err := writeExitStatus(exitStatusFile, statuses); err != nil {
+		return fmt.Errorf("failed to write exit status: %w", err)
+	}
+	if r.output == "" {
+		return nil
+	}
+	files := append([]string{exitStatusFile}, additionalFiles...)
+	for _, status := range statuses {
+		stdoutFile, stderrFile := r.runConf.OutputFiles(status.Name)
+		files = append(files, stdoutFile, stderrFile)
+	}
+	// the versions are already stopped, upload independently of their context
+	ctx := context.Background()
+	var err error
+	for _, file := range files {
+		err = merror.MaybeMultiError(err, retry.OnError(ctx, r.log, "[results]", func() error {
+			return r.upload(ctx, file)
+		}))
+	}
+	if err == nil {
+		r.log.Infof("-> output and exit status uploaded to %s", r.output)
+	}
+	return err
+}
+
+func (r *runResults) upload(ctx context.Context, file string) error {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		// the version did not start
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, _, err := output.NewRawWriter(ctx, r.output+"/"+filepath.Base(file), "log")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func writeExitStatus(file string, statuses []*application.ExitStatus) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(statuses)
+}
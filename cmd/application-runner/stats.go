@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/spf13/cobra"
+)
+
+func setupStatsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("cgroup-stats-output", []string{}, "output for the cgroup stats time series of the versions as csv or json [e.g. gs://ab-results/app/cgroup-stats.csv?compress=gzip]")
+	cmd.Flags().Duration("cgroup-stats-interval", time.Second, "interval between two cgroup stats samples")
+}
+
+// startStatsSampler samples the cgroup stats of all versions to the configured
+// outputs until the returned stop function is called.
+func startStatsSampler(ctx context.Context, log *logger.Logger, cmd *cobra.Command, versions []application.Version) (func() error, error) {
+	outputs := cli.MustGetStringArray(cmd, "cgroup-stats-output")
+	if len(outputs) == 0 {
+		return func() error { return nil }, nil
+	}
+	interval := cli.MustGetDuration(cmd, "cgroup-stats-interval")
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid cgroup stats interval: %s", interval)
+	}
+
+	writers := make([]*cgroups.StatsWriter, 0, len(outputs))
+	closeWriters := func() error {
+		var err error
+		for _, w := range writers {
+			err = merror.MaybeMultiError(err, w.Close())
+		}
+		return err
+	}
+	for _, location := range outputs {
+		// the outputs are not bound to ctx, so that the stats are still uploaded
+		// after the applications were stopped
+		w, format, err := output.NewRawWriter(context.Background(), location, "csv")
+		if err != nil {
+			return nil, merror.MaybeMultiError(fmt.Errorf("failed to open cgroup stats output %s: %w", location, err), closeWriters())
+		}
+		statsWriter, err := cgroups.NewStatsWriter(w, format)
+		if err != nil {
+			_ = w.Close()
+			return nil, merror.MaybeMultiError(err, closeWriters())
+		}
+		writers = append(writers, statsWriter)
+	}
+
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = v.Name
+	}
+	log.Infof("sampling cgroup stats every %s...", interval)
+	sampleCtx, cancel := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cgroups.SampleStats(sampleCtx, log, names, interval, writers)
+	}()
+	return func() error {
+		cancel()
+		return merror.MaybeMultiError(<-errCh, closeWriters())
+	}, nil
+}
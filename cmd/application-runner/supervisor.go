@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/application/control"
+	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/christophwitzko/masters-thesis/pkg/netutil"
+	"github.com/christophwitzko/masters-thesis/pkg/setup"
+	"github.com/hashicorp/go-multierror"
+)
+
+// controlLogLines is the number of output lines kept per version for the
+// control API.
+const controlLogLines = 1000
+
+// versionProcess is the state of a supervised version.
+type versionProcess struct {
+	mu        sync.Mutex
+	data      application.TemplateData
+	state     string
+	restarts  int
+	switches  int
+	startedAt time.Time
+	lastExit  *application.ExitStatus
+	err       string
+	// stop stops the running process, restart marks the stop as restart.
+	stop      context.CancelFunc
+	restart   bool
+	restartCh chan struct{}
+	logs      *application.LogBuffer
+	// switchMu serializes the switches of the version.
+	switchMu sync.Mutex
+}
+
+func newVersionProcesses(templateData []application.TemplateData) []*versionProcess {
+	processes := make([]*versionProcess, len(templateData))
+	for i, data := range templateData {
+		processes[i] = &versionProcess{
+			data:      data,
+			state:     control.StateBuilding,
+			restartCh: make(chan struct{}, 1),
+			logs:      application.NewLogBuffer(controlLogLines),
+		}
+	}
+	return processes
+}
+
+// started marks the version as running and returns its template data.
+func (p *versionProcess) started(stop context.CancelFunc) application.TemplateData {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.startedAt.IsZero() {
+		p.restarts++
+	}
+	p.state = control.StateRunning
+	p.startedAt = time.Now()
+	p.stop = stop
+	return p.data
+}
+
+// exited records the exit status of the version and reports whether it was
+// stopped to be restarted.
+func (p *versionProcess) exited(status *application.ExitStatus) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = control.StateExited
+	if status != nil && status.Stopped {
+		p.state = control.StateStopped
+	}
+	p.lastExit = status
+	p.stop = nil
+	restart := p.restart
+	p.restart = false
+	return restart
+}
+
+func (p *versionProcess) status() control.VersionStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return control.VersionStatus{
+		Name:      p.data.Name,
+		Source:    p.data.Source,
+		Endpoint:  p.data.Endpoint(),
+		State:     p.state,
+		Restarts:  p.restarts,
+		StartedAt: p.startedAt,
+		LastExit:  p.lastExit,
+		Error:     p.err,
+	}
+}
+
+// supervisor runs the versions and restarts or switches them on request of
+// the control API. It implements control.Runner.
+type supervisor struct {
+	log                  *logger.Logger
+	commands             *application.Commands
+	envVars              []string
+	useCgroups           bool
+	runConf              application.RunConfig
+	applicationDirectory string
+	gitRepository        string
+	// keepAlive keeps a version that exited by itself waiting for a restart
+	// instead of returning its exit status.
+	keepAlive bool
+	// shutdown stops all versions and the runner.
+	shutdown  context.CancelFunc
+	processes []*versionProcess
+}
+
+func (s *supervisor) process(name string) (*versionProcess, error) {
+	for _, p := range s.processes {
+		if p.status().Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", control.ErrUnknownVersion, name)
+}
+
+// run serves the control API and the time slices while the versions run and
+// reports their exit status once they stopped.
+func (s *supervisor) run(ctx context.Context, opts *runOptions, slicing *timeSlicing) error {
+	stopControlServer, err := startControlServer(s.log, opts.control, s)
+	if err != nil {
+		return err
+	}
+	stopTimeSlicing, err := slicing.start(ctx, s.shutdown)
+	if err != nil {
+		return merror.MaybeMultiError(err, stopControlServer())
+	}
+	statuses, runErr := s.runVersions(ctx)
+	if errors.Is(runErr, context.Canceled) {
+		s.log.Warnf("-> applications stopped")
+		runErr = nil
+	}
+	runErr = merror.MaybeMultiError(runErr, stopTimeSlicing())
+	return merror.MaybeMultiError(runErr, opts.results.report(statuses, slicing.files()...), stopControlServer())
+}
+
+// runVersions runs all versions until the context is done and returns their
+// last exit status.
+func (s *supervisor) runVersions(ctx context.Context) ([]*application.ExitStatus, error) {
+	var mErrMutex sync.Mutex
+	var mErr error
+	statuses := make([]*application.ExitStatus, len(s.processes))
+	wg := sync.WaitGroup{}
+	for i, p := range s.processes {
+		wg.Add(1)
+		go func(i int, p *versionProcess) {
+			defer wg.Done()
+			status, appErr := s.supervise(ctx, p)
+			name := p.status().Name
+			statuses[i] = status
+			if status == nil {
+				statuses[i] = &application.ExitStatus{Name: name, ExitCode: -1, Time: time.Now()}
+				if appErr != nil {
+					statuses[i].Error = appErr.Error()
+				}
+			}
+			if appErr != nil {
+				s.log.Warnf("-> application %s exited with error: %v", name, appErr)
+				mErrMutex.Lock()
+				mErr = multierror.Append(mErr, appErr)
+				mErrMutex.Unlock()
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	return statuses, mErr
+}
+
+// supervise runs the version and restarts it on request until the context
+// is done.
+func (s *supervisor) supervise(ctx context.Context, p *versionProcess) (*application.ExitStatus, error) {
+	runConf := s.runConf
+	runConf.Logs = p.logs
+	for {
+		runCtx, stop := context.WithCancel(ctx)
+		data := p.started(stop)
+		status, err := runVersion(runCtx, s.log, s.commands, data, s.envVars, s.useCgroups, runConf)
+		stop()
+		// keep the output of the previous runs
+		runConf.AppendOutput = true
+		restart := p.exited(status)
+		if ctx.Err() != nil || !restart && !s.keepAlive {
+			return status, err
+		}
+		if !restart {
+			s.log.Warnf("-> %s exited (%v), waiting for restart", data.Name, err)
+			select {
+			case <-ctx.Done():
+				return status, err
+			case <-p.restartCh:
+			}
+		}
+		s.log.Infof("-> restarting %s", data.Name)
+	}
+}
+
+func (s *supervisor) Status() []control.VersionStatus {
+	statuses := make([]control.VersionStatus, len(s.processes))
+	for i, p := range s.processes {
+		statuses[i] = p.status()
+		statuses[i].Ready = statuses[i].State == control.StateRunning && netutil.IsPortOpen(statuses[i].Endpoint, time.Second)
+	}
+	return statuses
+}
+
+func (s *supervisor) Restart(name string) error {
+	p, err := s.process(name)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		p.restart = true
+		p.stop()
+		return nil
+	}
+	select {
+	case p.restartCh <- struct{}{}:
+	default:
+		// a restart is already pending
+	}
+	return nil
+}
+
+func (s *supervisor) Switch(ctx context.Context, name, source string) error {
+	p, err := s.process(name)
+	if err != nil {
+		return err
+	}
+	p.switchMu.Lock()
+	defer p.switchMu.Unlock()
+	p.mu.Lock()
+	data := p.data
+	p.switches++
+	checkout := data.Version
+	checkout.Name = fmt.Sprintf("%s-switch-%d", data.Name, p.switches)
+	checkout.Source = source
+	p.mu.Unlock()
+
+	s.log.Infof("-> switching %s to %s", name, source)
+	err = s.build(ctx, &data, checkout)
+	p.mu.Lock()
+	if err != nil {
+		p.err = fmt.Sprintf("failed to switch to %s: %s", source, err)
+		p.mu.Unlock()
+		return fmt.Errorf("failed to switch %s to %s: %w", name, source, err)
+	}
+	p.data = data
+	p.err = ""
+	p.mu.Unlock()
+	return s.Restart(name)
+}
+
+// build checks out the source of the version and builds it to data.
+func (s *supervisor) build(ctx context.Context, data *application.TemplateData, checkout application.Version) error {
+	sourcePaths, err := setup.VersionSourcePaths(s.log, s.applicationDirectory, s.gitRepository, []application.Version{checkout})
+	if err != nil {
+		return err
+	}
+	data.Source = checkout.Source
+	data.SourcePath = cli.GetAbsolutePath(sourcePaths[0])
+	data.ExecFile = application.ExecFilePath(data.SourcePath, data.Name)
+	return application.Build(ctx, s.log, s.commands.BuildCommands(), *data)
+}
+
+func (s *supervisor) Logs(name string, n int) ([]string, error) {
+	p, err := s.process(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.logs.Lines(n), nil
+}
+
+func (s *supervisor) CgroupStats(name string) (*cgroups.StatsSample, error) {
+	if _, err := s.process(name); err != nil {
+		return nil, err
+	}
+	if !s.useCgroups {
+		return nil, control.ErrNoCgroups
+	}
+	return cgroups.ReadStats(name)
+}
+
+func (s *supervisor) Shutdown() {
+	s.log.Warnf("-> shutdown requested")
+	s.shutdown()
+}
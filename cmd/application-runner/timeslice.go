@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/application/timeslice"
+	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/spf13/cobra"
+)
+
+// timeSliceTarget is the label of the time slicing router in the manifest.
+const timeSliceTarget = "timeslice"
+
+func setupTimeSliceFlags(cmd *cobra.Command) {
+	defaults := timeslice.DefaultConfig()
+	cmd.Flags().Bool("time-slicing", false, "run the versions in alternating randomized time slices, the manifest only contains a router to the thawed version (requires cgroups)")
+	cmd.Flags().Duration("slice-min", defaults.MinSlice, "minimum duration of a time slice")
+	cmd.Flags().Duration("slice-max", defaults.MaxSlice, "maximum duration of a time slice")
+	cmd.Flags().Int64("slice-seed", 0, "seed of the random time slices (0 uses a random seed)")
+	cmd.Flags().Duration("slice-drain-timeout", defaults.DrainTimeout, "time the requests to a version have to finish before it is frozen")
+}
+
+// timeSliceFromFlags returns the config of the time slices or nil if time
+// slicing is disabled.
+func timeSliceFromFlags(cmd *cobra.Command, useCgroups bool) (*timeslice.Config, error) {
+	if !cli.MustGetBool(cmd, "time-slicing") {
+		return nil, nil
+	}
+	if !useCgroups {
+		return nil, errors.New("time slicing requires cgroups (--limit-cpu or --resources-file)")
+	}
+	conf := &timeslice.Config{
+		MinSlice:     cli.MustGetDuration(cmd, "slice-min"),
+		MaxSlice:     cli.MustGetDuration(cmd, "slice-max"),
+		Seed:         cli.MustGetInt64(cmd, "slice-seed"),
+		DrainTimeout: cli.MustGetDuration(cmd, "slice-drain-timeout"),
+	}
+	return conf, conf.Validate()
+}
+
+// cgroupFreezer freezes the cgroups of the versions.
+type cgroupFreezer struct{}
+
+func (cgroupFreezer) Freeze(name string) error {
+	return cgroups.Freeze(name)
+}
+
+func (cgroupFreezer) Thaw(name string) error {
+	return cgroups.Thaw(name)
+}
+
+// timeSlicing serves the router of the time slices and records the schedule.
+type timeSlicing struct {
+	log          *logger.Logger
+	router       *timeslice.Router
+	target       application.Version
+	scheduleFile string
+}
+
+// newTimeSlicing allocates the port of the router. It returns nil if time
+// slicing is disabled.
+func newTimeSlicing(log *logger.Logger, conf *timeslice.Config, portAllocator *application.PortAllocator, bindAddress string, versions []application.Version, outputDir string) (*timeSlicing, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	port, err := portAllocator.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate port for time slicing router: %w", err)
+	}
+	router, err := timeslice.NewRouter(log, conf, cgroupFreezer{}, versions)
+	if err != nil {
+		return nil, err
+	}
+	return &timeSlicing{
+		log:          log,
+		router:       router,
+		target:       application.Version{Name: timeSliceTarget, Host: bindAddress, Port: port},
+		scheduleFile: filepath.Join(outputDir, timeslice.ScheduleFile),
+	}, nil
+}
+
+// routerTarget returns the router for the manifest or nil if time slicing is
+// disabled.
+func (t *timeSlicing) routerTarget() *application.Version {
+	if t == nil {
+		return nil
+	}
+	return &t.target
+}
+
+// start serves the router and starts the time slices. If switching the
+// slices fails, abort is called. The returned function stops the time
+// slices, thaws all versions and writes the schedule.
+func (t *timeSlicing) start(ctx context.Context, abort func()) (func() error, error) {
+	if t == nil {
+		return func() error { return nil }, nil
+	}
+	listener, err := net.Listen("tcp", t.target.Endpoint())
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for time slicing router: %w", err)
+	}
+	server := &http.Server{Handler: t.router, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.log.Errorf("time slicing router failed: %v", err)
+		}
+	}()
+	t.log.Infof("-> time slicing router listening on %s (seed %d)", listener.Addr(), t.router.Seed())
+
+	runCtx, cancel := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		runErr := t.router.Run(runCtx)
+		if runErr != nil && runCtx.Err() == nil {
+			t.log.Errorf("-> time slicing failed: %v", runErr)
+			abort()
+		}
+		errCh <- runErr
+	}()
+	return func() error {
+		cancel()
+		err := <-errCh
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		return merror.MaybeMultiError(err, server.Shutdown(shutdownCtx), t.writeSchedule())
+	}, nil
+}
+
+func (t *timeSlicing) writeSchedule() error {
+	if err := os.MkdirAll(filepath.Dir(t.scheduleFile), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(t.scheduleFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := t.router.Schedule().WriteCSV(f); err != nil {
+		return fmt.Errorf("failed to write time slice schedule: %w", err)
+	}
+	return nil
+}
+
+// files returns the files that are uploaded with the results.
+func (t *timeSlicing) files() []string {
+	if t == nil {
+		return nil
+	}
+	return []string{t.scheduleFile}
+}
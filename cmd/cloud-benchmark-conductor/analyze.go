@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/christophwitzko/masters-thesis/pkg/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func analyzeCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "analyze <results>...",
+		Short: "Compute bootstrap confidence intervals of the v2/v1 performance ratios",
+		Long: `Compute the median v2/v1 ratio of each benchmark function together with its
+percentile bootstrap confidence interval and classify the function as improved,
+regressed or unchanged.
+
+Results can be read from files, chunked outputs, directories, buckets
+(e.g. gs://cbc-results/mb/) or SQLite result stores (e.g. sqlite://results.db).`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cli.WrapRunE(log, analyzeRun),
+	}
+	setupAnalysisFlags(cmd.Flags())
+	setupTableOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func setupAnalysisFlags(flags *pflag.FlagSet) {
+	defaultConfig := analysis.DefaultConfig()
+	flags.String("metric", defaultConfig.Metric, "metric to compare (ops, bytes or allocs)")
+	flags.Int("iterations", defaultConfig.Iterations, "bootstrap iterations")
+	flags.Float64("confidence-level", defaultConfig.ConfidenceLevel, "confidence level in percent")
+	flags.Float64("threshold", defaultConfig.Threshold, "minimal relative change (e.g. 0.01) to classify a function as improved or regressed")
+	flags.Int64("seed", defaultConfig.Seed, "seed of the bootstrap random number generator")
+	flags.String("thresholds-file", "", "file with per-function maximum ratios (<regexp>=<max ratio> per line, e.g. written by calibrate) that override the threshold")
+}
+
+func setupTableOutputFlags(flags *pflag.FlagSet) {
+	flags.String("format", "table", "output format ("+strings.Join(table.Formats, ", ")+")")
+	flags.StringP("output", "o", "-", "output file (default stdout)")
+}
+
+func analysisConfigFromFlags(cmd *cobra.Command) (analysis.Config, error) {
+	config := analysis.Config{
+		Metric:          cli.MustGetString(cmd, "metric"),
+		Iterations:      cli.MustGetInt(cmd, "iterations"),
+		ConfidenceLevel: cli.MustGetFloat64(cmd, "confidence-level"),
+		Threshold:       cli.MustGetFloat64(cmd, "threshold"),
+		Seed:            cli.MustGetInt64(cmd, "seed"),
+	}
+	if thresholdsFile := cli.MustGetString(cmd, "thresholds-file"); thresholdsFile != "" {
+		thresholds, err := readThresholdsFile(thresholdsFile)
+		if err != nil {
+			return config, err
+		}
+		config.Thresholds = thresholds
+	}
+	return config, config.Validate()
+}
+
+func readThresholdsFile(path string) ([]analysis.BenchmarkThreshold, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	thresholds, err := analysis.ReadBenchmarkThresholds(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid thresholds file %s: %w", path, err)
+	}
+	return thresholds, nil
+}
+
+func readResults(ctx context.Context, log *logger.Logger, locations []string) (microbenchmark.Results, error) {
+	results := make(microbenchmark.Results, 0)
+	for _, location := range locations {
+		log.Infof("reading results from %s...", location)
+		locationResults, err := output.ReadResults(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, locationResults...)
+	}
+	log.Infof("read %d results", len(results))
+	return results, nil
+}
+
+// writeTable writes the table in the selected format to the selected output.
+func writeTable(cmd *cobra.Command, header []string, rows [][]string) error {
+	return writeTableFile(cmd, cli.MustGetString(cmd, "output"), header, rows)
+}
+
+// writeTableFile writes the table in the selected format to the file (- for stdout).
+func writeTableFile(cmd *cobra.Command, path string, header []string, rows [][]string) error {
+	format := cli.MustGetString(cmd, "format")
+	if !table.IsValidFormat(format) {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	return writeFileOrStdout(path, func(w io.Writer) error {
+		return table.Write(w, format, header, rows)
+	})
+}
+
+func analyzeRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
+	config, err := analysisConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	results, err := readResults(ctx, log, args)
+	if err != nil {
+		return err
+	}
+	analysisResults, err := analysis.Analyze(results, config)
+	if err != nil {
+		return err
+	}
+	return writeTable(cmd, analysis.TableHeader, analysis.TableRows(analysisResults))
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/christophwitzko/masters-thesis/pkg/cli"
 	"github.com/christophwitzko/masters-thesis/pkg/config"
@@ -13,6 +15,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// appReadyTimeout is the time the versions have to accept connections after
+// they were built.
+const appReadyTimeout = 5 * time.Minute
+
 func applicationBenchmarkCmd(log *logger.Logger) *cobra.Command {
 	return &cobra.Command{
 		Use:     "application-benchmark",
@@ -44,35 +50,34 @@ func applicationBenchmarkRun(log *logger.Logger, cmd *cobra.Command, args []stri
 	}
 	log.Info("running application benchmarks...")
 
-	internalIPCh := make(chan string)
+	appCh := make(chan *run.RunningApplication)
 	appErrCh := make(chan error)
 	go func() {
 		defer close(appErrCh)
-		appErr := run.Application(ctx, log, service, internalIPCh)
+		appErr := run.Application(ctx, log, service, appCh)
 		if appErr != nil && !errors.Is(appErr, context.Canceled) {
 			log.Errorf("error running application: %s", appErr)
 		}
 		appErrCh <- appErr
 	}()
 
-	internalIP := <-internalIPCh
-	if internalIP == "" {
+	app := <-appCh
+	if app == nil {
 		// some error happened during application setup
 		return <-appErrCh
 	}
-
-	// v1 is always running on port 3000 and v2 on port 3001
-	targets := []string{
-		fmt.Sprintf("v1=%s:3000", internalIP),
-		fmt.Sprintf("v2=%s:3001", internalIP),
+	if err := waitReady(ctx, log, app); err != nil {
+		cancel()
+		<-appErrCh
+		return err
 	}
-	log.Infof("starting benchmarks on internal IP: %s", internalIP)
-	err = run.ApplicationBenchmark(ctx, log, service, targets)
+
+	err = runBenchmarkPhases(ctx, log, service, app)
 	if err != nil {
 		log.Errorf("error running application benchmark: %s", err)
 	}
 	log.Infof("stopping applications...")
-	cancel()
+	stopApplication(log, app, cancel)
 	err = <-appErrCh
 	if err != nil && !errors.Is(err, context.Canceled) {
 		return err
@@ -80,3 +85,122 @@ func applicationBenchmarkRun(log *logger.Logger, cmd *cobra.Command, args []stri
 	log.Info("done")
 	return nil
 }
+
+// runBenchmarkPhases runs the benchmark once or all configured phases against
+// the same application runner. Versions are restarted or switched via the
+// control API before a phase.
+func runBenchmarkPhases(ctx context.Context, log *logger.Logger, service gcloud.Service, app *run.RunningApplication) error {
+	phases := service.Config().Application.Phases
+	if len(phases) == 0 {
+		log.Infof("starting benchmarks on targets: %v", app.Targets)
+		return run.ApplicationBenchmark(ctx, log, service, app.Targets, nil)
+	}
+	for i := range phases {
+		phase := &phases[i]
+		if err := preparePhase(ctx, log, app, phase); err != nil {
+			return fmt.Errorf("failed to prepare phase %s: %w", phase.Name, err)
+		}
+		log.Infof("starting benchmark phase %s (%d/%d) on targets: %v", phase.Name, i+1, len(phases), app.Targets)
+		if err := run.ApplicationBenchmark(ctx, log, service, app.Targets, phase); err != nil {
+			return fmt.Errorf("phase %s failed: %w", phase.Name, err)
+		}
+	}
+	return nil
+}
+
+// preparePhase switches and restarts the versions of the phase and waits
+// until they were started again and are ready.
+func preparePhase(ctx context.Context, log *logger.Logger, app *run.RunningApplication, phase *config.ConductorApplicationPhase) error {
+	// restarts are the number of restarts of the versions when they were
+	// restarted, the restart is asynchronous
+	restarts := make(map[string]int)
+	switched := make([]string, 0, len(phase.Switch))
+	for name := range phase.Switch {
+		switched = append(switched, name)
+	}
+	sort.Strings(switched)
+	for _, name := range switched {
+		log.Infof("switching %s to %s...", name, phase.Switch[name])
+		status, err := app.Control.Switch(ctx, name, phase.Switch[name])
+		if err != nil {
+			return err
+		}
+		restarts[name] = status.Restarts
+	}
+	for _, name := range phase.Restart {
+		log.Infof("restarting %s...", name)
+		status, err := app.Control.Restart(ctx, name)
+		if err != nil {
+			return err
+		}
+		restarts[name] = status.Restarts
+	}
+	if len(restarts) == 0 {
+		return nil
+	}
+	return waitRestarted(ctx, log, app, restarts)
+}
+
+// waitRestarted waits until the versions have more restarts than given and
+// all versions are ready.
+func waitRestarted(ctx context.Context, log *logger.Logger, app *run.RunningApplication, restarts map[string]int) error {
+	readyCtx, cancel := context.WithTimeout(ctx, appReadyTimeout)
+	defer cancel()
+	for {
+		statuses, err := app.Control.WaitReady(readyCtx, time.Second)
+		if err != nil {
+			return err
+		}
+		restarted := true
+		for _, status := range statuses {
+			if previous, ok := restarts[status.Name]; ok && status.Restarts <= previous {
+				restarted = false
+			}
+		}
+		if restarted {
+			for _, status := range statuses {
+				log.Infof("-> %s (%s) ready on %s", status.Name, status.Source, status.Endpoint)
+			}
+			return nil
+		}
+		select {
+		case <-readyCtx.Done():
+			return fmt.Errorf("versions not restarted: %w", readyCtx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// waitReady waits until all versions accept connections if the control API
+// of the application runner is enabled.
+func waitReady(ctx context.Context, log *logger.Logger, app *run.RunningApplication) error {
+	if app.Control == nil {
+		return nil
+	}
+	log.Info("waiting for the applications to become ready...")
+	readyCtx, cancel := context.WithTimeout(ctx, appReadyTimeout)
+	defer cancel()
+	statuses, err := app.Control.WaitReady(readyCtx, time.Second)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		log.Infof("-> %s (%s) ready on %s", status.Name, status.Source, status.Endpoint)
+	}
+	return nil
+}
+
+// stopApplication stops the versions via the control API of the application
+// runner and falls back to interrupting the application runner.
+func stopApplication(log *logger.Logger, app *run.RunningApplication, cancel context.CancelFunc) {
+	if app.Control != nil {
+		ctx, ctxCancel := context.WithTimeout(context.Background(), time.Minute)
+		defer ctxCancel()
+		err := app.Control.Shutdown(ctx)
+		if err == nil {
+			return
+		}
+		log.Warnf("failed to stop applications via control API: %v", err)
+	}
+	cancel()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+func calibrateCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "calibrate <results>...",
+		Short: "Measure the false-positive rate of the analysis with A/A experiments",
+		Long: `Analyze the results of A/A experiments, i.e. microbenchmark runs in which v1
+and v2 are the same version (e.g. microbenchmark --microbenchmark-v2 main with
+main as v1), and report the empirical false-positive rate per benchmark and
+overall for each threshold and confidence level.
+
+Every benchmark is analyzed with its original v1/v2 split and --splits random
+splits of its pooled trials. Each split that is classified as improved or
+regressed is a false positive.
+
+For every benchmark the smallest threshold with a false-positive rate of at
+most --max-false-positive-rate at --confidence-level is recommended, together
+with the minimal detectable effect at this threshold. The recommended
+thresholds can be written to a thresholds file that is used by analyze, gate,
+report and correlate with --thresholds-file.`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cli.WrapRunE(log, calibrateRun),
+	}
+	defaultConfig := analysis.DefaultCalibrationConfig()
+	cmd.Flags().String("metric", defaultConfig.Metric, "metric to compare (ops, bytes or allocs)")
+	cmd.Flags().Int("iterations", defaultConfig.Iterations, "bootstrap iterations")
+	cmd.Flags().Int64("seed", defaultConfig.Seed, "seed of the random number generator")
+	cmd.Flags().Int("splits", defaultConfig.Splits, "random v1/v2 splits per benchmark in addition to the original split")
+	cmd.Flags().Float64Slice("thresholds", defaultConfig.Thresholds, "calibrated thresholds")
+	cmd.Flags().Float64Slice("confidence-levels", defaultConfig.ConfidenceLevels, "calibrated confidence levels in percent")
+	cmd.Flags().Float64("confidence-level", defaultConfig.ConfidenceLevel, "confidence level of the recommended thresholds")
+	cmd.Flags().Float64("max-false-positive-rate", defaultConfig.MaxFalsePositiveRate, "maximal false-positive rate of the recommended thresholds")
+	cmd.Flags().String("recommendations", "", "write the recommended thresholds and minimal detectable effects as table to this file (- for stdout)")
+	cmd.Flags().String("thresholds-output", "", "write the recommended thresholds as thresholds file to this file (- for stdout)")
+	setupTableOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func calibrationConfigFromFlags(cmd *cobra.Command) analysis.CalibrationConfig {
+	return analysis.CalibrationConfig{
+		Metric:               cli.MustGetString(cmd, "metric"),
+		Iterations:           cli.MustGetInt(cmd, "iterations"),
+		Seed:                 cli.MustGetInt64(cmd, "seed"),
+		Splits:               cli.MustGetInt(cmd, "splits"),
+		Thresholds:           cli.MustGetFloat64Slice(cmd, "thresholds"),
+		ConfidenceLevels:     cli.MustGetFloat64Slice(cmd, "confidence-levels"),
+		ConfidenceLevel:      cli.MustGetFloat64(cmd, "confidence-level"),
+		MaxFalsePositiveRate: cli.MustGetFloat64(cmd, "max-false-positive-rate"),
+	}
+}
+
+func writeRecommendations(log *logger.Logger, cmd *cobra.Command, confidenceLevel float64, recommendations []analysis.Recommendation) error {
+	if recommendationsOutput := cli.MustGetString(cmd, "recommendations"); recommendationsOutput != "" {
+		err := writeTableFile(cmd, recommendationsOutput, analysis.RecommendationTableHeader, analysis.RecommendationTableRows(recommendations))
+		if err != nil {
+			return err
+		}
+		log.Infof("recommendations written to %s", recommendationsOutput)
+	}
+	if thresholdsOutput := cli.MustGetString(cmd, "thresholds-output"); thresholdsOutput != "" {
+		err := writeFileOrStdout(thresholdsOutput, func(w io.Writer) error {
+			return analysis.WriteThresholds(w, confidenceLevel, recommendations)
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("thresholds written to %s", thresholdsOutput)
+	}
+	return nil
+}
+
+func calibrateRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
+	config := calibrationConfigFromFlags(cmd)
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	results, err := readResults(ctx, log, args)
+	if err != nil {
+		return err
+	}
+	calibration, err := analysis.Calibrate(results, config)
+	if err != nil {
+		return err
+	}
+	if err := writeTable(cmd, analysis.FalsePositiveRateTableHeader, analysis.FalsePositiveRateTableRows(calibration.Rates)); err != nil {
+		return err
+	}
+	for _, r := range calibration.Rates {
+		if r.Function == analysis.Overall && r.ConfidenceLevel == config.ConfidenceLevel {
+			log.Infof("overall false-positive rate at threshold %s: %d of %d (%s%%)", strconv.FormatFloat(r.Threshold, 'g', -1, 64),
+				r.FalsePositives, r.Experiments, strconv.FormatFloat(r.Rate*100, 'f', 2, 64))
+		}
+	}
+	return writeRecommendations(log, cmd, config.ConfidenceLevel, calibration.Recommendations)
+}
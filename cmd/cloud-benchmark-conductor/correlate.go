@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/application/latency"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/profile"
+	"github.com/spf13/cobra"
+)
+
+func correlateCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "correlate",
+		Short: "Correlate microbenchmark and application benchmark results",
+		Long: `Analyze the microbenchmark (MB) and application benchmark (AB) results of the
+same v1/v2 pair and check whether both levels detect the same changes.
+
+Benchmarks are linked to the endpoints whose hot paths they cover using the
+cpu profiles of the profiling mode of both runners. A benchmark covers an
+endpoint if at least --min-coverage of the endpoint cpu time passes through
+functions that are executed by the benchmark. By default the hot path of an
+endpoint is the whole application profile, with --endpoint-handler it is
+restricted to the call stacks below the handler of the endpoint.
+
+Every linked pair is classified as agreement, mb-only, ab-only or conflict.
+Benchmarks and endpoints without link are reported as mb-only or ab-only if
+they changed and as unlinked otherwise.`,
+		Args: cobra.NoArgs,
+		Run:  cli.WrapRunE(log, correlateRun),
+	}
+	setupAnalysisFlags(cmd.Flags())
+	setupTableOutputFlags(cmd.Flags())
+	cmd.Flags().StringArray("mb", []string{}, "location of microbenchmark results")
+	cmd.Flags().StringArray("ab", []string{}, "location of application benchmark results")
+	cmd.Flags().StringArray("mb-profiles", []string{}, "location of microbenchmark profiles (<package>.<function>.out)")
+	cmd.Flags().StringArray("ab-profiles", []string{}, "location of application benchmark profiles (pprof-<target>-<index>.out)")
+	cmd.Flags().String("v1", "v1", "name of version 1 in the application benchmark results")
+	cmd.Flags().String("v2", "v2", "name of version 2 in the application benchmark results")
+	cmd.Flags().Duration("warmup", 0, "duration that is removed from the start of the common application benchmark window")
+	cmd.Flags().Duration("cooldown", 0, "duration that is removed from the end of the common application benchmark window")
+	cmd.Flags().Float64("min-coverage", 0.05, "minimal share of the endpoint cpu time covered by a benchmark to link them")
+	cmd.Flags().StringArray("endpoint-handler", []string{}, "handler functions of an endpoint (<endpoint>=<regexp>, e.g. GET /flights=handlers\\.GetFlights)")
+	cmd.Flags().String("ignore", "", "regexp of functions that are not used to link benchmarks and endpoints")
+	cmd.Flags().Bool("include-stdlib", false, "also link via functions of the standard library and the runtime")
+	return cmd
+}
+
+func linkConfigFromFlags(cmd *cobra.Command) (analysis.LinkConfig, error) {
+	config := analysis.LinkConfig{
+		MinCoverage:   cli.MustGetFloat64(cmd, "min-coverage"),
+		IncludeStdlib: cli.MustGetBool(cmd, "include-stdlib"),
+	}
+	for _, h := range cli.MustGetStringArray(cmd, "endpoint-handler") {
+		handler, err := analysis.ParseEndpointHandler(h)
+		if err != nil {
+			return config, err
+		}
+		config.Handlers = append(config.Handlers, handler)
+	}
+	if ignore := cli.MustGetString(cmd, "ignore"); ignore != "" {
+		pattern, err := regexp.Compile(ignore)
+		if err != nil {
+			return config, fmt.Errorf("invalid ignore pattern: %w", err)
+		}
+		config.Ignore = pattern
+	}
+	return config, nil
+}
+
+// readProfiles merges the profiles of all locations.
+func readProfiles(ctx context.Context, log *logger.Logger, locations []string) (map[string][]profile.Stack, error) {
+	profiles := make(map[string][]profile.Stack)
+	for _, location := range locations {
+		log.Infof("reading profiles from %s...", location)
+		locationProfiles, err := profile.ReadLocation(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		for name, stacks := range locationProfiles {
+			profiles[name] = append(profiles[name], stacks...)
+		}
+	}
+	log.Infof("read %d profiles", len(profiles))
+	return profiles, nil
+}
+
+func analyzeApplicationBenchmark(ctx context.Context, log *logger.Logger, cmd *cobra.Command, config analysis.Config) ([]analysis.Result, error) {
+	samples, err := readLatencies(ctx, log, cli.MustGetStringArray(cmd, "ab"))
+	if err != nil {
+		return nil, err
+	}
+	preprocessed, err := latency.Preprocess(samples, latency.PreprocessConfig{
+		Warmup:   cli.MustGetDuration(cmd, "warmup"),
+		Cooldown: cli.MustGetDuration(cmd, "cooldown"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return analysis.AnalyzeLatencies(preprocessed.Samples, cli.MustGetString(cmd, "v1"), cli.MustGetString(cmd, "v2"), config)
+}
+
+func linkBenchmarks(ctx context.Context, log *logger.Logger, cmd *cobra.Command, abResults []analysis.Result) ([]analysis.Link, error) {
+	linkConfig, err := linkConfigFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	mbProfiles, err := readProfiles(ctx, log, cli.MustGetStringArray(cmd, "mb-profiles"))
+	if err != nil {
+		return nil, err
+	}
+	abProfiles, err := readProfiles(ctx, log, cli.MustGetStringArray(cmd, "ab-profiles"))
+	if err != nil {
+		return nil, err
+	}
+	appStacks := make([]profile.Stack, 0)
+	for _, stacks := range abProfiles {
+		appStacks = append(appStacks, stacks...)
+	}
+	endpoints := make([]string, 0, len(abResults))
+	for _, r := range abResults {
+		endpoints = append(endpoints, r.Function)
+	}
+	links := analysis.LinkBenchmarks(mbProfiles, appStacks, endpoints, linkConfig)
+	log.Infof("found %d links between %d benchmarks and %d endpoints", len(links), len(mbProfiles), len(endpoints))
+	return links, nil
+}
+
+func correlateRun(log *logger.Logger, cmd *cobra.Command, _ []string) error {
+	config, err := analysisConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"mb", "ab", "mb-profiles", "ab-profiles"} {
+		if len(cli.MustGetStringArray(cmd, name)) == 0 {
+			return fmt.Errorf("at least one --%s location is required", name)
+		}
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	results, err := readResults(ctx, log, cli.MustGetStringArray(cmd, "mb"))
+	if err != nil {
+		return err
+	}
+	mbResults, err := analysis.Analyze(results, config)
+	if err != nil {
+		return err
+	}
+	abResults, err := analyzeApplicationBenchmark(ctx, log, cmd, config)
+	if err != nil {
+		return err
+	}
+	links, err := linkBenchmarks(ctx, log, cmd, abResults)
+	if err != nil {
+		return err
+	}
+
+	correlations := analysis.Correlate(mbResults, abResults, links)
+	if err := writeTable(cmd, analysis.CorrelationTableHeader, analysis.CorrelationTableRows(correlations)); err != nil {
+		return err
+	}
+	summary := analysis.CorrelationSummary(correlations)
+	log.Infof("%d agreements, %d mb-only, %d ab-only, %d conflicts, %d unlinked",
+		summary[analysis.Agreement], summary[analysis.MBOnly], summary[analysis.ABOnly], summary[analysis.Conflict], summary[analysis.Unlinked])
+	return nil
+}
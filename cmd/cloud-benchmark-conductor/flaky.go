@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/spf13/cobra"
+)
+
+func flakyCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "flaky <results>...",
+		Short: "Detect flaky benchmarks across runs and experiments",
+		Long: `Analyze each run of each experiment separately (every location is an
+experiment) and compare the verdicts and distributions across the runs.
+
+A benchmark is flaky if
+  - the runs report contradictory verdicts (improved and regressed) or less
+    than --min-agreement of the runs agree (contradictory-verdicts),
+  - the trials within the runs form several clusters, i.e. the bimodality
+    coefficient of the trials normalized by their run median exceeds
+    --max-bimodality (bimodality),
+  - the trials of a run have a significant trend (Mann-Kendall test) that
+    changes the median by more than --min-effect (drift),
+  - the trials of a version differ significantly between the runs of an
+    experiment (Kruskal-Wallis test) and the run medians differ by more
+    than --min-effect (instance-dependence).
+
+The flaky benchmarks can be written to a quarantine list that is excluded by
+the microbenchmark runner (--quarantine-file) or on all instances of the
+microbenchmark command (microbenchmark.quarantineFile).`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cli.WrapRunE(log, flakyRun),
+	}
+	setupAnalysisFlags(cmd.Flags())
+	setupTableOutputFlags(cmd.Flags())
+	defaultConfig := analysis.DefaultFlakyConfig()
+	cmd.Flags().Float64("significance-level", defaultConfig.SignificanceLevel, "significance level of the homogeneity and trend tests")
+	cmd.Flags().Float64("min-effect", defaultConfig.MinEffect, "minimal relative difference between run medians or within a run")
+	cmd.Flags().Float64("min-agreement", defaultConfig.MinAgreement, "minimal share of runs with the majority verdict")
+	cmd.Flags().Float64("max-bimodality", defaultConfig.MaxBimodality, "maximal bimodality coefficient")
+	cmd.Flags().Int("min-bimodality-samples", defaultConfig.MinBimodalitySamples, "minimal trials of a version in an experiment to check the bimodality")
+	cmd.Flags().String("quarantine", "", "write the flaky benchmarks as quarantine list to this file (- for stdout)")
+	return cmd
+}
+
+func flakyConfigFromFlags(cmd *cobra.Command) (analysis.FlakyConfig, error) {
+	config, err := analysisConfigFromFlags(cmd)
+	if err != nil {
+		return analysis.FlakyConfig{}, err
+	}
+	flakyConfig := analysis.FlakyConfig{
+		Analysis:             config,
+		SignificanceLevel:    cli.MustGetFloat64(cmd, "significance-level"),
+		MinEffect:            cli.MustGetFloat64(cmd, "min-effect"),
+		MinAgreement:         cli.MustGetFloat64(cmd, "min-agreement"),
+		MaxBimodality:        cli.MustGetFloat64(cmd, "max-bimodality"),
+		MinBimodalitySamples: cli.MustGetInt(cmd, "min-bimodality-samples"),
+	}
+	return flakyConfig, flakyConfig.Validate()
+}
+
+func flakyRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
+	config, err := flakyConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	experiments := make([]microbenchmark.Results, 0, len(args))
+	for _, location := range args {
+		results, err := readResults(ctx, log, []string{location})
+		if err != nil {
+			return err
+		}
+		experiments = append(experiments, results)
+	}
+	flaky, err := analysis.DetectFlaky(experiments, config)
+	if err != nil {
+		return err
+	}
+	if err := writeTable(cmd, analysis.FlakyTableHeader, analysis.FlakyTableRows(flaky)); err != nil {
+		return err
+	}
+	flakyCount := 0
+	for _, f := range flaky {
+		if f.Flaky() {
+			flakyCount++
+			log.Warnf("%s is flaky: %v", f.Function, f.Reasons)
+		}
+	}
+	log.Infof("%d of %d benchmarks are flaky", flakyCount, len(flaky))
+
+	if quarantineOutput := cli.MustGetString(cmd, "quarantine"); quarantineOutput != "" {
+		err := writeFileOrStdout(quarantineOutput, func(w io.Writer) error {
+			return analysis.WriteQuarantine(w, flaky)
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("quarantine list written to %s", quarantineOutput)
+	}
+	return nil
+}
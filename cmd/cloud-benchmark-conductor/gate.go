@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+func gateCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gate <results>...",
+		Short: "Fail on statistically significant performance regressions",
+		Long: `Analyze the results (see analyze) and check the v2/v1 ratios against the
+thresholds. By default a benchmark fails if the lower bound of the confidence
+interval of its ratio exceeds the maximum ratio, i.e. if it is significantly
+slower than allowed. The command exits with a non-zero exit code if any
+benchmark fails and can write a JUnit XML report and a Markdown summary.`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cli.WrapRunE(log, gateRun),
+	}
+	setupAnalysisFlags(cmd.Flags())
+	setupTableOutputFlags(cmd.Flags())
+	cmd.Flags().String("criterion", analysis.CriterionCILower, "value that is compared with the maximum ratio (ci-lower, ratio or ci-upper)")
+	cmd.Flags().Float64("max-ratio", 1.05, "global maximum v2/v1 ratio")
+	cmd.Flags().StringArray("benchmark-max-ratio", []string{}, "maximum ratio for matching functions (<regexp>=<max ratio>, e.g. pkg.BenchmarkA=1.1)")
+	cmd.Flags().String("junit", "", "write a JUnit XML report to this file")
+	cmd.Flags().String("markdown", "", "write a Markdown summary to this file (- for stdout)")
+	cmd.Flags().String("suite-name", "benchmark-gate", "name of the JUnit test suite")
+	return cmd
+}
+
+func writeFileOrStdout(path string, writeFn func(w io.Writer) error) error {
+	if path == "-" {
+		return writeFn(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeFn(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// gateConfigFromFlags uses the thresholds of the thresholds file for all
+// functions without --benchmark-max-ratio.
+func gateConfigFromFlags(cmd *cobra.Command, fileThresholds []analysis.BenchmarkThreshold) (analysis.GateConfig, error) {
+	gateConfig := analysis.GateConfig{
+		Criterion: cli.MustGetString(cmd, "criterion"),
+		MaxRatio:  cli.MustGetFloat64(cmd, "max-ratio"),
+	}
+	for _, t := range cli.MustGetStringArray(cmd, "benchmark-max-ratio") {
+		threshold, err := analysis.ParseBenchmarkThreshold(t)
+		if err != nil {
+			return gateConfig, err
+		}
+		gateConfig.Thresholds = append(gateConfig.Thresholds, threshold)
+	}
+	gateConfig.Thresholds = append(gateConfig.Thresholds, fileThresholds...)
+	return gateConfig, gateConfig.Validate()
+}
+
+func writeGateReports(log *logger.Logger, cmd *cobra.Command, criterion string, gateResults []analysis.GateResult) error {
+	if junitOutput := cli.MustGetString(cmd, "junit"); junitOutput != "" {
+		err := writeFileOrStdout(junitOutput, func(w io.Writer) error {
+			return analysis.WriteJUnit(w, cli.MustGetString(cmd, "suite-name"), criterion, gateResults)
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("JUnit report written to %s", junitOutput)
+	}
+	if markdownOutput := cli.MustGetString(cmd, "markdown"); markdownOutput != "" {
+		err := writeFileOrStdout(markdownOutput, func(w io.Writer) error {
+			return analysis.WriteMarkdown(w, criterion, gateResults)
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("Markdown summary written to %s", markdownOutput)
+	}
+	return nil
+}
+
+func gateRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
+	config, err := analysisConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	gateConfig, err := gateConfigFromFlags(cmd, config.Thresholds)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	results, err := readResults(ctx, log, args)
+	if err != nil {
+		return err
+	}
+	analysisResults, err := analysis.Analyze(results, config)
+	if err != nil {
+		return err
+	}
+	gateResults := analysis.Gate(analysisResults, gateConfig)
+	if err := writeTable(cmd, analysis.GateTableHeader, analysis.GateTableRows(gateResults)); err != nil {
+		return err
+	}
+
+	if err := writeGateReports(log, cmd, gateConfig.Criterion, gateResults); err != nil {
+		return err
+	}
+
+	for _, r := range gateResults {
+		if r.Status == analysis.GateFailed {
+			log.Warnf("%s: %s", r.Function, r.Message(gateConfig.Criterion))
+		}
+	}
+	if failures := analysis.GateFailures(gateResults); failures != 0 {
+		return fmt.Errorf("%d of %d benchmarks exceeded the maximum ratio", failures, len(gateResults))
+	}
+	log.Infof("all %d benchmarks passed", len(gateResults))
+	return nil
+}
@@ -46,6 +46,15 @@ Therefore compute instances are provisioned and used to execute the benchmarks.`
 		cleanupCmd(log),
 		microbenchmarkCmd(log),
 		applicationBenchmarkCmd(log),
+		resultsCmd(log),
+		analyzeCmd(log),
+		variabilityCmd(log),
+		gateCmd(log),
+		reportCmd(log),
+		preprocessCmd(log),
+		correlateCmd(log),
+		calibrateCmd(log),
+		flakyCmd(log),
 	)
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application/latency"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+func preprocessCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preprocess <results>...",
+		Short: "Preprocess application benchmark results",
+		Long: `Read the k6 csv outputs (e.g. v1.csv.gz, v2.csv.gz) and the combined artillery
+results (combined-results.csv) of an application benchmark and compute the
+median latency per endpoint, version and second (or artillery period).
+
+The timelines of all versions are aligned and sliced to the window in which
+all versions were under load simultaneously. The warmup and cooldown are
+removed from this window. The time column is relative to the first sample of
+all versions.`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cli.WrapRunE(log, preprocessRun),
+	}
+	setupTableOutputFlags(cmd.Flags())
+	cmd.Flags().Duration("warmup", 0, "duration that is removed from the start of the common window")
+	cmd.Flags().Duration("cooldown", 0, "duration that is removed from the end of the common window")
+	return cmd
+}
+
+func preprocessRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
+	config := latency.PreprocessConfig{
+		Warmup:   cli.MustGetDuration(cmd, "warmup"),
+		Cooldown: cli.MustGetDuration(cmd, "cooldown"),
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	samples, err := readLatencies(ctx, log, args)
+	if err != nil {
+		return err
+	}
+	if versions := samples.Versions(); len(versions) < 2 {
+		log.Warnf("found %d versions, expected at least two", len(versions))
+	}
+	preprocessed, err := latency.Preprocess(samples, config)
+	if err != nil {
+		return err
+	}
+	log.Infof("common window: %s - %s (%ds), %d of %d samples",
+		time.Unix(preprocessed.Start, 0).UTC().Format(time.RFC3339),
+		time.Unix(preprocessed.End, 0).UTC().Format(time.RFC3339),
+		preprocessed.End-preprocessed.Start, len(preprocessed.Samples), len(samples))
+	return writeTable(cmd, latency.TableHeader, latency.TableRows(preprocessed.Samples))
+}
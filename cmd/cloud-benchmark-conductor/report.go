@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/application/latency"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/christophwitzko/masters-thesis/pkg/report"
+	"github.com/spf13/cobra"
+)
+
+func reportCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate a self-contained HTML report of an experiment",
+		Long: `Generate a single HTML file that summarizes an experiment. The report contains
+the experiment metadata, the analysis of the microbenchmark results (see analyze)
+with a forest plot of the v2/v1 ratios, the trial distribution of each function
+and the latency time series of each application benchmark endpoint.
+
+All plots are embedded as inline SVG, hence the report can be shared as a
+single file without network access.`,
+		Args: cobra.NoArgs,
+		Run:  cli.WrapRunE(log, reportRun),
+	}
+	setupAnalysisFlags(cmd.Flags())
+	cmd.Flags().StringArray("mb", []string{}, "location of microbenchmark results (e.g. gs://cbc-results/mb/)")
+	cmd.Flags().StringArray("ab", []string{}, "location of application benchmark k6 csv outputs (e.g. gs://cbc-results/ab/)")
+	cmd.Flags().String("title", "Benchmark report", "title of the report")
+	cmd.Flags().StringP("output", "o", "report.html", "output file (- for stdout)")
+	return cmd
+}
+
+func readMetadata(ctx context.Context, log *logger.Logger, locations []string) []*microbenchmark.Metadata {
+	allMetadata := make([]*microbenchmark.Metadata, 0)
+	for _, location := range locations {
+		if strings.HasPrefix(location, "sqlite://") {
+			continue
+		}
+		metadata, err := output.ReadMetadata(ctx, location)
+		if err != nil {
+			log.Warnf("failed to read metadata from %s: %v", location, err)
+			continue
+		}
+		allMetadata = append(allMetadata, metadata...)
+	}
+	return allMetadata
+}
+
+func readLatencies(ctx context.Context, log *logger.Logger, locations []string) (latency.Samples, error) {
+	samples := make(latency.Samples, 0)
+	for _, location := range locations {
+		log.Infof("reading application benchmark results from %s...", location)
+		locationSamples, err := latency.ReadResults(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, locationSamples...)
+	}
+	log.Infof("read %d latency samples", len(samples))
+	return samples, nil
+}
+
+func reportRun(log *logger.Logger, cmd *cobra.Command, _ []string) error {
+	config, err := analysisConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	mbLocations := cli.MustGetStringArray(cmd, "mb")
+	abLocations := cli.MustGetStringArray(cmd, "ab")
+	if len(mbLocations) == 0 && len(abLocations) == 0 {
+		return fmt.Errorf("at least one --mb or --ab location is required")
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	experiment := report.Experiment{
+		Title:       cli.MustGetString(cmd, "title"),
+		Metric:      config.Metric,
+		GeneratedAt: time.Now(),
+	}
+	if len(mbLocations) != 0 {
+		results, err := readResults(ctx, log, mbLocations)
+		if err != nil {
+			return err
+		}
+		experiment.Results = results
+		experiment.Metadata = readMetadata(ctx, log, mbLocations)
+		experiment.Analysis, err = analysis.Analyze(results, config)
+		if err != nil {
+			return err
+		}
+	}
+	latencies, err := readLatencies(ctx, log, abLocations)
+	if err != nil {
+		return err
+	}
+	experiment.Latencies = latencies
+
+	outputPath := cli.MustGetString(cmd, "output")
+	err = writeFileOrStdout(outputPath, func(w io.Writer) error {
+		return report.Write(w, experiment)
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("report written to %s", outputPath)
+	return nil
+}
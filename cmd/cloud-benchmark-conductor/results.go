@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/christophwitzko/masters-thesis/pkg/resultstore"
+	"github.com/spf13/cobra"
+)
+
+func resultsCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "results",
+		Short: "Inspect stored benchmark results",
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(resultsQueryCmd(log), resultsIngestCmd(log), resultsChangePointsCmd(log))
+	return cmd
+}
+
+func resultsQueryCmd(log *logger.Logger) *cobra.Command {
+	queryNames := make([]string, 0, len(resultstore.Queries))
+	queryHelp := make([]string, 0, len(resultstore.Queries))
+	for _, q := range resultstore.Queries {
+		queryNames = append(queryNames, q.Name)
+		queryHelp = append(queryHelp, fmt.Sprintf("  %-10s %s", q.Name, q.Description))
+	}
+	cmd := &cobra.Command{
+		Use:       "query <query>",
+		Short:     "Run a canned query against a SQLite result store",
+		Long:      "Run a canned query against a SQLite result store.\n\nAvailable queries:\n" + strings.Join(queryHelp, "\n"),
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: queryNames,
+		Run:       cli.WrapRunE(log, resultsQueryRun),
+	}
+	cmd.Flags().String("db", "results.db", "path to the SQLite result store")
+	cmd.Flags().String("label", "", "only consider runs with this label")
+	setupTableOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func resultsQueryRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
+	query, err := resultstore.FindQuery(args[0])
+	if err != nil {
+		return err
+	}
+	dbPath := cli.MustGetString(cmd, "db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return err
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	store, err := resultstore.Open(ctx, dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	header, rows, err := query.Run(ctx, store, resultstore.QueryFilter{
+		Label: cli.MustGetString(cmd, "label"),
+	})
+	if err != nil {
+		return err
+	}
+	return writeTable(cmd, header, rows)
+}
+
+func resultsIngestCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ingest <results>...",
+		Short: "Ingest finished experiments into a SQLite result store",
+		Long: `Ingest the results of finished experiments from their output locations
+(e.g. gs://cbc-results/mb/) into a local SQLite result store to build a
+history keyed by benchmark and commit.
+
+The results have to be written with metadata sidecars (metadata=sidecar).
+Each sidecar is ingested as one run together with the result files of its
+output. Runs without end timestamp are still running and are skipped. Every
+sidecar is only ingested once, hence the command can be run repeatedly, e.g.
+to add new experiments below the same prefix.`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cli.WrapRunE(log, resultsIngestRun),
+	}
+	cmd.Flags().String("db", "results.db", "path to the SQLite result store")
+	cmd.Flags().String("label", "", "label of the ingested runs")
+	return cmd
+}
+
+func ingestLocation(ctx context.Context, log *logger.Logger, store *resultstore.Store, location, label string) error {
+	sidecars, err := output.ReadSidecars(ctx, location)
+	if err != nil {
+		return err
+	}
+	if len(sidecars) == 0 {
+		return fmt.Errorf("no metadata found at %s (the results have to be written with metadata=sidecar)", location)
+	}
+	for _, sidecar := range sidecars {
+		if sidecar.Metadata.EndTime.IsZero() {
+			log.Warnf("run %d of %s is not finished yet, skipping", sidecar.Metadata.RunIndex, sidecar.Path)
+			continue
+		}
+		if err := ingestSidecar(ctx, log, store, sidecar, label); err != nil {
+			return fmt.Errorf("failed to ingest run %d of %s: %w", sidecar.Metadata.RunIndex, sidecar.Path, err)
+		}
+	}
+	return nil
+}
+
+// ingestSidecar ingests the run of the sidecar, which is identified by the
+// path of the sidecar.
+func ingestSidecar(ctx context.Context, log *logger.Logger, store *resultstore.Store, sidecar *output.Sidecar, label string) error {
+	runIndex := sidecar.Metadata.RunIndex
+	ingested, err := store.IsIngested(ctx, sidecar.Path, runIndex)
+	if err != nil {
+		return err
+	}
+	if ingested {
+		log.Infof("run %d of %s was already ingested", runIndex, sidecar.Path)
+		return nil
+	}
+	results, err := sidecar.ReadResults(ctx)
+	if err != nil {
+		return err
+	}
+	if ingested, err = store.Ingest(ctx, sidecar.Path, label, sidecar.Metadata, results); err != nil || !ingested {
+		return err
+	}
+	log.Infof("ingested run %d of %s (%d results)", runIndex, sidecar.Path, len(results))
+	return nil
+}
+
+func resultsIngestRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	store, err := resultstore.Open(ctx, cli.MustGetString(cmd, "db"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	label := cli.MustGetString(cmd, "label")
+	for _, location := range args {
+		log.Infof("ingesting %s...", location)
+		if err := ingestLocation(ctx, log, store, location, label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resultsChangePointsCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "changepoints",
+		Short: "Detect commits at which the performance shifted",
+		Long: `Detect change points in the history of each benchmark function stored in a
+SQLite result store (see results ingest). The history of a function is the
+median of all trials per commit in chronological order. Change points are
+detected with E-Divisive means and tested with a permutation test, hence
+shifts are also found if no single pairwise experiment was conclusive.`,
+		Args: cobra.NoArgs,
+		Run:  cli.WrapRunE(log, resultsChangePointsRun),
+	}
+	defaultConfig := analysis.DefaultChangePointConfig()
+	cmd.Flags().String("db", "results.db", "path to the SQLite result store")
+	cmd.Flags().String("label", "", "only consider runs with this label")
+	cmd.Flags().String("metric", "ops", "metric of the history (ops, bytes or allocs)")
+	cmd.Flags().Int("min-size", defaultConfig.MinSize, "minimal number of commits between two change points")
+	cmd.Flags().Int("permutations", defaultConfig.Permutations, "permutations of the significance test")
+	cmd.Flags().Float64("significance-level", defaultConfig.SignificanceLevel, "significance level of the permutation test")
+	cmd.Flags().Int64("seed", defaultConfig.Seed, "seed of the permutation random number generator")
+	setupTableOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func resultsChangePointsRun(log *logger.Logger, cmd *cobra.Command, _ []string) error {
+	config := analysis.ChangePointConfig{
+		MinSize:           cli.MustGetInt(cmd, "min-size"),
+		Permutations:      cli.MustGetInt(cmd, "permutations"),
+		SignificanceLevel: cli.MustGetFloat64(cmd, "significance-level"),
+		Seed:              cli.MustGetInt64(cmd, "seed"),
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	dbPath := cli.MustGetString(cmd, "db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return err
+	}
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	store, err := resultstore.Open(ctx, dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	series, err := store.History(ctx, resultstore.QueryFilter{Label: cli.MustGetString(cmd, "label")}, cli.MustGetString(cmd, "metric"))
+	if err != nil {
+		return err
+	}
+	changePoints, err := analysis.DetectChangePoints(series, config)
+	if err != nil {
+		return err
+	}
+	log.Infof("found %d change points in the history of %d functions", len(changePoints), len(series))
+	return writeTable(cmd, analysis.ChangePointTableHeader, analysis.ChangePointTableRows(changePoints))
+}
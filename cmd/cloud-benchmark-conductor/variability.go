@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+const groupByInstance = "instance"
+
+func variabilityCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "variability <results>...",
+		Short: "Report the variability (CV, RMAD and RCIW) of the benchmark results",
+		Long: `Report the coefficient of variation (CV), the relative median absolute deviation
+(RMAD) and the relative confidence interval width of the median (RCIW) per
+function and version. With --group-by run or suite the variability is reported
+per run index (instance) or suite run. With --group-by instance the variability
+of all functions is summarized per instance to detect noisy instances.
+
+Benchmarks exceeding one of the limits are flagged. With --fail-on-flagged the
+command fails if any benchmark is flagged and --trusted-output writes all
+functions that were not flagged (one per line).`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cli.WrapRunE(log, variabilityRun),
+	}
+	defaultConfig := analysis.DefaultVariabilityConfig()
+	cmd.Flags().String("group-by", defaultConfig.GroupBy, "grouping of the results (function, run, suite or instance)")
+	cmd.Flags().String("metric", defaultConfig.Metric, "metric to analyze (ops, bytes or allocs)")
+	cmd.Flags().Int("iterations", defaultConfig.Iterations, "bootstrap iterations of the RCIW")
+	cmd.Flags().Float64("confidence-level", defaultConfig.ConfidenceLevel, "confidence level of the RCIW in percent")
+	cmd.Flags().Int64("seed", defaultConfig.Seed, "seed of the bootstrap random number generator")
+	cmd.Flags().Float64("max-cv", defaultConfig.MaxCV, "maximum coefficient of variation (0 disables the limit)")
+	cmd.Flags().Float64("max-rmad", defaultConfig.MaxRMAD, "maximum relative median absolute deviation (0 disables the limit)")
+	cmd.Flags().Float64("max-rciw", defaultConfig.MaxRCIW, "maximum relative confidence interval width (0 disables the limit)")
+	cmd.Flags().Float64("noisy-instance-factor", 1.5, "instances with a median CV above this factor times the median CV of all instances are reported as noisy")
+	cmd.Flags().Bool("fail-on-flagged", false, "exit with a non-zero exit code if a benchmark exceeds a limit")
+	cmd.Flags().String("trusted-output", "", "write the functions that never exceeded a limit to this file")
+	setupTableOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func variabilityRun(log *logger.Logger, cmd *cobra.Command, args []string) error {
+	config := analysis.VariabilityConfig{
+		GroupBy:         cli.MustGetString(cmd, "group-by"),
+		Metric:          cli.MustGetString(cmd, "metric"),
+		Iterations:      cli.MustGetInt(cmd, "iterations"),
+		ConfidenceLevel: cli.MustGetFloat64(cmd, "confidence-level"),
+		Seed:            cli.MustGetInt64(cmd, "seed"),
+		MaxCV:           cli.MustGetFloat64(cmd, "max-cv"),
+		MaxRMAD:         cli.MustGetFloat64(cmd, "max-rmad"),
+		MaxRCIW:         cli.MustGetFloat64(cmd, "max-rciw"),
+	}
+	perInstance := config.GroupBy == groupByInstance
+	if perInstance {
+		config.GroupBy = analysis.GroupByRun
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	noisyInstanceFactor := cli.MustGetFloat64(cmd, "noisy-instance-factor")
+
+	ctx, cancel := cli.NewContext(cli.DefaultTimeout)
+	defer cancel()
+
+	results, err := readResults(ctx, log, args)
+	if err != nil {
+		return err
+	}
+	variabilities, err := analysis.ComputeVariability(results, config)
+	if err != nil {
+		return err
+	}
+
+	if perInstance {
+		err = writeInstanceTable(log, cmd, variabilities, noisyInstanceFactor)
+	} else {
+		err = writeTable(cmd, analysis.VariabilityTableHeader, analysis.VariabilityTableRows(variabilities))
+	}
+	if err != nil {
+		return err
+	}
+
+	trusted, flagged := analysis.TrustedFunctions(variabilities)
+	log.Infof("%d trusted and %d flagged functions", len(trusted), len(flagged))
+	for _, fn := range flagged {
+		log.Warnf("flagged: %s", fn)
+	}
+	if trustedOutput := cli.MustGetString(cmd, "trusted-output"); trustedOutput != "" {
+		if err := writeTrustedFunctions(trustedOutput, trusted); err != nil {
+			return err
+		}
+		log.Infof("trusted functions written to %s", trustedOutput)
+	}
+	if cli.MustGetBool(cmd, "fail-on-flagged") && len(flagged) != 0 {
+		return fmt.Errorf("%d functions exceeded the variability limits", len(flagged))
+	}
+	return nil
+}
+
+func writeInstanceTable(log *logger.Logger, cmd *cobra.Command, variabilities []analysis.Variability, noisyInstanceFactor float64) error {
+	instances := analysis.InstanceVariabilities(variabilities)
+	for _, iv := range instances {
+		if iv.RelativeCV > noisyInstanceFactor {
+			log.Warnf("instance of run %d is noisy: median CV %.4f is %.2fx the median of all instances", iv.Run, iv.MedianCV, iv.RelativeCV)
+		}
+	}
+	return writeTable(cmd, analysis.InstanceTableHeader, analysis.InstanceTableRows(instances))
+}
+
+func writeTrustedFunctions(path string, trusted []string) error {
+	content := strings.Join(trusted, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
@@ -10,13 +10,17 @@ import (
 
 	"github.com/christophwitzko/masters-thesis/pkg/cli"
 	"github.com/christophwitzko/masters-thesis/pkg/gcloud/storage"
+	"github.com/christophwitzko/masters-thesis/pkg/git"
+	"github.com/christophwitzko/masters-thesis/pkg/hostinfo"
 	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/metrics"
 	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
 	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
 	"github.com/christophwitzko/masters-thesis/pkg/profile"
 	"github.com/christophwitzko/masters-thesis/pkg/retry"
 	"github.com/christophwitzko/masters-thesis/pkg/setup"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func main() {
@@ -38,6 +42,7 @@ func main() {
 
 	rootCmd.Flags().Int("run", 1, "current run index")
 	rootCmd.Flags().Int("suite-runs", 3, "amount of suite runs")
+	rootCmd.Flags().Int64("seed", 0, "seed for randomizing the execution order (default: current time)")
 
 	rootCmd.Flags().StringArrayP("output", "o", []string{"-"}, "output files (default stdout)")
 	rootCmd.Flags().Bool("json", false, "output in json format")
@@ -49,12 +54,16 @@ func main() {
 	rootCmd.Flags().StringArray("function", []string{}, "specific functions to benchmark")
 	rootCmd.MarkFlagsMutuallyExclusive("function", "include-filter")
 	rootCmd.MarkFlagsMutuallyExclusive("function", "exclude-filter")
+	rootCmd.Flags().String("quarantine-file", "", "file with flaky functions that are excluded (one <package>.<function> per line)")
 
 	rootCmd.Flags().Bool("profiling", false, "create a profile for each function")
 	rootCmd.Flags().String("profiling-local-output", "./profiles", "output directory for profiling")
 	rootCmd.Flags().String("profiling-gcs-output", "", "if set uploads the profiles to google cloud storage")
 	rootCmd.Flags().Duration("timeout", 60*time.Minute, "timeout for the benchmark execution")
 	rootCmd.Flags().StringArray("env", []string{}, "additional environment variables to set")
+	rootCmd.Flags().Int("metrics-port", 0, "if set exposes prometheus metrics on this port")
+
+	rootCmd.AddCommand(mutateCmd(log))
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -94,17 +103,101 @@ func getVersionedFunctions(sourcePathV1, sourcePathV2, includeRegexp, excludeReg
 	}), nil
 }
 
-func runMicrobenchmarks(ctx context.Context, log *logger.Logger, versionedFunctions microbenchmark.VersionedFunctions, outputPaths []string, defaultOutputFormat string, suiteRuns, runIndex int, env []string) error {
-	resultWriter, err := output.New(ctx, outputPaths, defaultOutputFormat)
+func quarantineFunctions(log *logger.Logger, versionedFunctions microbenchmark.VersionedFunctions, quarantineFile string) (microbenchmark.VersionedFunctions, error) {
+	f, err := os.Open(quarantineFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+	defer f.Close()
+	quarantined, err := microbenchmark.ReadQuarantine(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine file: %w", err)
+	}
+	remaining := versionedFunctions.Quarantine(quarantined)
+	log.Infof("quarantined %d functions", len(versionedFunctions)-len(remaining))
+	return remaining, nil
+}
+
+// selectFunctions returns the filtered functions that are not quarantined.
+func selectFunctions(log *logger.Logger, cmd *cobra.Command, sourcePathV1, sourcePathV2 string) (microbenchmark.VersionedFunctions, error) {
+	versionedFunctions, err := getVersionedFunctions(sourcePathV1, sourcePathV2,
+		cli.MustGetString(cmd, "include-filter"), cli.MustGetString(cmd, "exclude-filter"), cli.MustGetStringArray(cmd, "function"))
+	if err != nil {
+		return nil, err
+	}
+	if quarantineFile := cli.MustGetString(cmd, "quarantine-file"); quarantineFile != "" {
+		versionedFunctions, err = quarantineFunctions(log, versionedFunctions, quarantineFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("found %d functions:", len(versionedFunctions))
+	for _, fn := range versionedFunctions {
+		log.Infof("%s", fn.V1.String())
+	}
+	return versionedFunctions, nil
+}
+
+func versionInfo(log *logger.Logger, reference, sourcePath string) microbenchmark.VersionInfo {
+	commit, err := git.HeadCommit(sourcePath)
+	if err != nil {
+		log.Warnf("could not resolve commit of %s: %v", sourcePath, err)
+	}
+	return microbenchmark.VersionInfo{
+		Reference:  reference,
+		Commit:     commit,
+		SourcePath: sourcePath,
+	}
+}
+
+func collectMetadata(ctx context.Context, log *logger.Logger, cmd *cobra.Command, versionedFunctions microbenchmark.VersionedFunctions, v1, v2 microbenchmark.VersionInfo, seed int64, env []string) *microbenchmark.Metadata {
+	goEnv, err := hostinfo.GoEnv(ctx, append([]string{"CGO_ENABLED=0"}, env...))
+	if err != nil {
+		log.Warnf("could not read go env: %v", err)
+	}
+	config := make(map[string]string)
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		config[flag.Name] = flag.Value.String()
+	})
+	functions := make([]string, 0, len(versionedFunctions))
+	for _, vf := range versionedFunctions {
+		functions = append(functions, vf.String())
+	}
+	return &microbenchmark.Metadata{
+		Runner:     cli.GetBuildInfo(),
+		Repository: cli.MustGetString(cmd, "git-repository"),
+		V1:         v1,
+		V2:         v2,
+		RunIndex:   cli.MustGetInt(cmd, "run"),
+		SuiteRuns:  cli.MustGetInt(cmd, "suite-runs"),
+		Seed:       seed,
+		Functions:  functions,
+		Host:       hostinfo.Collect(ctx),
+		GoVersion:  goEnv["GOVERSION"],
+		GoEnv:      goEnv,
+		Config:     config,
+		StartTime:  time.Now(),
+	}
+}
+
+func runMicrobenchmarks(ctx context.Context, log *logger.Logger, versionedFunctions microbenchmark.VersionedFunctions, outputPaths []string, defaultOutputFormat string, metadata *microbenchmark.Metadata, env []string, mbMetrics *metrics.MicrobenchmarkMetrics) error {
+	resultWriter, err := output.New(ctx, outputPaths, defaultOutputFormat, metadata)
 	if err != nil {
 		return fmt.Errorf("failed to open output: %w", err)
 	}
 	defer resultWriter.Close()
 
+	runIndex, suiteRuns := metadata.RunIndex, metadata.SuiteRuns
+	if mbMetrics != nil {
+		mbMetrics.SetRun(runIndex, suiteRuns, len(versionedFunctions))
+		resultWriter = mbMetrics.ResultWriter(resultWriter)
+	}
 	log.Infof("run index: %d", runIndex)
+	log.Infof("seed: %d", metadata.Seed)
 	for s := 1; s <= suiteRuns; s++ {
 		log.Infof("suite run: %d/%d", s, suiteRuns)
-		err := microbenchmark.RunSuite(ctx, log, resultWriter, versionedFunctions, runIndex, s, env)
+		err := microbenchmark.RunSuite(ctx, log, resultWriter, versionedFunctions, runIndex, s, metadata.Seed, env)
 		if err != nil {
 			return err
 		}
@@ -167,19 +260,16 @@ func rootRun(log *logger.Logger, cmd *cobra.Command, _ []string) error {
 	sourcePathOrRefV2 := cli.MustGetString(cmd, "v2")
 	gitRepository := cli.MustGetString(cmd, "git-repository")
 	benchmarkDirectory := cli.MustGetString(cmd, "benchmark-directory")
-	includeRegexp := cli.MustGetString(cmd, "include-filter")
-	excludeRegexp := cli.MustGetString(cmd, "exclude-filter")
-	runIndex := cli.MustGetInt(cmd, "run")
-	suiteRuns := cli.MustGetInt(cmd, "suite-runs")
+	seed := cli.MustGetInt64(cmd, "seed")
 	outputPaths := cli.MustGetStringArray(cmd, "output")
 	outputFormatJSON := cli.MustGetBool(cmd, "json")
 	outputFormatCSV := cli.MustGetBool(cmd, "csv")
-	functions := cli.MustGetStringArray(cmd, "function")
 	shouldRunProfiling := cli.MustGetBool(cmd, "profiling")
 	profilingLocalOutput := cli.MustGetString(cmd, "profiling-local-output")
 	profilingGCSOutput := cli.MustGetString(cmd, "profiling-gcs-output")
 	timeout := cli.MustGetDuration(cmd, "timeout")
 	envVars := cli.MustGetStringArray(cmd, "env")
+	metricsPort := cli.MustGetInt(cmd, "metrics-port")
 
 	if !outputFormatCSV && !outputFormatJSON {
 		return fmt.Errorf("either --json or --csv must be set to true")
@@ -200,23 +290,33 @@ func rootRun(log *logger.Logger, cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
-	versionedFunctions, err := getVersionedFunctions(sourcePathV1, sourcePathV2, includeRegexp, excludeRegexp, functions)
+	versionedFunctions, err := selectFunctions(log, cmd, sourcePathV1, sourcePathV2)
 	if err != nil {
 		return err
 	}
 
-	log.Infof("found %d functions:", len(versionedFunctions))
-	for _, fn := range versionedFunctions {
-		log.Infof("%s", fn.V1.String())
-	}
-
 	log.Infof("timeout: %s", timeout)
 	ctx, cancel := cli.NewContext(timeout)
 	defer cancel()
 
+	var mbMetrics *metrics.MicrobenchmarkMetrics
+	if metricsPort > 0 {
+		registry := metrics.NewRegistry()
+		mbMetrics = metrics.NewMicrobenchmarkMetrics(registry)
+		metrics.Start(ctx, log, metricsPort, registry)
+	}
+
 	if shouldRunProfiling {
 		return runProfiling(ctx, log, versionedFunctions, filepath.Join(sourcePathV1, profilingLocalOutput), profilingGCSOutput, envVars)
 	}
 
-	return runMicrobenchmarks(ctx, log, versionedFunctions, outputPaths, defaultOutputFormat, suiteRuns, runIndex, envVars)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	metadata := collectMetadata(ctx, log, cmd, versionedFunctions,
+		versionInfo(log, sourcePathOrRefV1, sourcePathV1),
+		versionInfo(log, sourcePathOrRefV2, sourcePathV2),
+		seed, envVars,
+	)
+	return runMicrobenchmarks(ctx, log, versionedFunctions, outputPaths, defaultOutputFormat, metadata, envVars, mbMetrics)
 }
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/cli"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/christophwitzko/masters-thesis/pkg/mutation"
+	"github.com/christophwitzko/masters-thesis/pkg/setup"
+	"github.com/christophwitzko/masters-thesis/pkg/table"
+	"github.com/spf13/cobra"
+)
+
+func mutateCmd(log *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mutate",
+		Short: "Score the sensitivity of the suite with synthetic slowdowns",
+		Long: `Create performance mutants of the target functions, run the suite against
+each mutant (v1 is the original source, v2 the mutant) and report which
+benchmarks detected each mutant as regression.
+
+A mutant inserts a busy loop (busy-loop, severity in microseconds of cpu time
+per call) or additional heap allocations (alloc, severity in allocations of
+1 KiB per call) at the beginning of the target function. The mutation score of
+a function is the share of its mutants that were detected by any benchmark,
+the score of a benchmark is the share of all mutants it detected.`,
+		Args: cobra.NoArgs,
+		Run:  cli.WrapRunE(log, mutateRun),
+	}
+	cmd.Flags().String("source", "", "source path or git reference of the original version")
+	cmd.Flags().String("git-repository", "", "git repository to use for benchmarking")
+	cmd.Flags().String("benchmark-directory", "/tmp/.bench-mutation", "directory to use for the checkout and the mutants")
+	cmd.Flags().StringArray("target", []string{}, "function to mutate (<package>.<function> or <package>.<type>.<method>)")
+	cmd.Flags().StringArray("kind", []string{mutation.KindBusyLoop}, "mutation kinds ("+strings.Join(mutation.Kinds(), ", ")+")")
+	cmd.Flags().IntSlice("severity", []int{10, 100}, "mutation severities")
+	cmd.Flags().Int("busy-loop-iterations", 0, "busy loop iterations per microsecond (default: calibrated on this host)")
+
+	cmd.Flags().Int("suite-runs", 3, "amount of suite runs per mutant")
+	cmd.Flags().Int64("seed", 0, "seed for randomizing the execution order (default: current time)")
+	cmd.Flags().String("include-filter", ".*", "regular expression to filter packages or functions")
+	cmd.Flags().String("exclude-filter", "^$", "regular expression to exclude packages or functions")
+	cmd.Flags().StringArray("function", []string{}, "specific functions to benchmark")
+	cmd.MarkFlagsMutuallyExclusive("function", "include-filter")
+	cmd.MarkFlagsMutuallyExclusive("function", "exclude-filter")
+	cmd.Flags().Duration("timeout", 6*time.Hour, "timeout for all mutants")
+	cmd.Flags().StringArray("env", []string{}, "additional environment variables to set")
+	cmd.Flags().String("results-directory", "", "if set stores the results of each mutant in this directory")
+
+	defaultConfig := analysis.DefaultConfig()
+	cmd.Flags().Int("bootstrap-iterations", defaultConfig.Iterations, "bootstrap iterations of the analysis")
+	cmd.Flags().Float64("confidence-level", defaultConfig.ConfidenceLevel, "confidence level of the analysis in percent")
+	cmd.Flags().Float64("threshold", defaultConfig.Threshold, "minimal relative slowdown to detect a mutant")
+
+	cmd.Flags().String("format", "table", "output format ("+strings.Join(table.Formats, ", ")+")")
+	cmd.Flags().StringP("output", "o", "-", "output file of the detected mutants (default stdout)")
+	cmd.Flags().String("function-scores", "", "write the mutation scores per function to this file (- for stdout)")
+	cmd.Flags().String("benchmark-scores", "", "write the mutation scores per benchmark to this file (- for stdout)")
+	return cmd
+}
+
+// resultCollector keeps all results of a mutant in memory for the analysis.
+type resultCollector struct {
+	results microbenchmark.Results
+}
+
+func (c *resultCollector) Write(result microbenchmark.Result) error {
+	c.results = append(c.results, result)
+	return nil
+}
+
+func (c *resultCollector) Close() error {
+	return nil
+}
+
+func mutantsFromFlags(cmd *cobra.Command) ([]mutation.Mutant, error) {
+	targetFlags := cli.MustGetStringArray(cmd, "target")
+	if len(targetFlags) == 0 {
+		return nil, fmt.Errorf("at least one --target is required")
+	}
+	severities, err := cmd.Flags().GetIntSlice("severity")
+	if err != nil {
+		return nil, err
+	}
+	mutants := make([]mutation.Mutant, 0)
+	for _, t := range targetFlags {
+		target, err := mutation.ParseTarget(t)
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range cli.MustGetStringArray(cmd, "kind") {
+			for _, severity := range severities {
+				mutant := mutation.Mutant{Target: target, Kind: kind, Severity: severity}
+				if err := mutant.Validate(); err != nil {
+					return nil, err
+				}
+				mutants = append(mutants, mutant)
+			}
+		}
+	}
+	return mutants, nil
+}
+
+func busyLoopIterations(log *logger.Logger, cmd *cobra.Command) int {
+	iterations := cli.MustGetInt(cmd, "busy-loop-iterations")
+	if iterations <= 0 {
+		iterations = mutation.CalibrateBusyLoop()
+		log.Infof("calibrated busy loop: %d iterations per microsecond", iterations)
+	}
+	return iterations
+}
+
+type mutantRunner struct {
+	log                *logger.Logger
+	cmd                *cobra.Command
+	sourcePath         string
+	busyLoopIterations int
+	seed               int64
+	analysisConfig     analysis.Config
+}
+
+// resultWriter returns the writer for the results of the mutant and the
+// collector of the analyzed results.
+func (r *mutantRunner) resultWriter(ctx context.Context, mutant mutation.Mutant, mutantPath string, fns microbenchmark.VersionedFunctions) (microbenchmark.ResultWriter, *resultCollector, error) {
+	collector := &resultCollector{}
+	resultsDirectory := cli.MustGetString(r.cmd, "results-directory")
+	if resultsDirectory == "" {
+		return collector, collector, nil
+	}
+	functions := make([]string, 0, len(fns))
+	for _, vf := range fns {
+		functions = append(functions, vf.String())
+	}
+	metadata := &microbenchmark.Metadata{
+		Runner:    cli.GetBuildInfo(),
+		V1:        versionInfo(r.log, cli.MustGetString(r.cmd, "source"), r.sourcePath),
+		V2:        microbenchmark.VersionInfo{Reference: mutant.ID(), SourcePath: mutantPath},
+		RunIndex:  1,
+		SuiteRuns: cli.MustGetInt(r.cmd, "suite-runs"),
+		Seed:      r.seed,
+		Functions: functions,
+		StartTime: time.Now(),
+	}
+	resultsPath := filepath.Join(resultsDirectory, mutant.ID()+".csv?metadata=sidecar")
+	fileWriter, err := output.New(ctx, []string{resultsPath}, "csv", metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open output: %w", err)
+	}
+	return microbenchmark.NewMultiResultWriter([]microbenchmark.ResultWriter{collector, fileWriter}), collector, nil
+}
+
+func (r *mutantRunner) run(ctx context.Context, mutant mutation.Mutant) (mutation.Outcome, error) {
+	mutantPath := filepath.Join(cli.MustGetString(r.cmd, "benchmark-directory"), "mutants", mutant.ID())
+	if err := setup.CreateDirectory(mutantPath); err != nil {
+		return mutation.Outcome{}, err
+	}
+	defer os.RemoveAll(mutantPath)
+	if err := mutation.CopyTree(r.sourcePath, mutantPath); err != nil {
+		return mutation.Outcome{}, err
+	}
+	mutatedFile, err := mutation.Apply(mutantPath, mutant, r.busyLoopIterations)
+	if err != nil {
+		return mutation.Outcome{}, err
+	}
+	r.log.Infof("mutated %s", mutatedFile)
+	fns, err := getVersionedFunctions(r.sourcePath, mutantPath,
+		cli.MustGetString(r.cmd, "include-filter"), cli.MustGetString(r.cmd, "exclude-filter"), cli.MustGetStringArray(r.cmd, "function"))
+	if err != nil {
+		return mutation.Outcome{}, err
+	}
+	resultWriter, collector, err := r.resultWriter(ctx, mutant, mutantPath, fns)
+	if err != nil {
+		return mutation.Outcome{}, err
+	}
+	suiteRuns := cli.MustGetInt(r.cmd, "suite-runs")
+	for s := 1; s <= suiteRuns; s++ {
+		r.log.Infof("suite run: %d/%d", s, suiteRuns)
+		if err := microbenchmark.RunSuite(ctx, r.log, resultWriter, fns, 1, s, r.seed, cli.MustGetStringArray(r.cmd, "env")); err != nil {
+			_ = resultWriter.Close()
+			return mutation.Outcome{}, err
+		}
+	}
+	if err := resultWriter.Close(); err != nil {
+		return mutation.Outcome{}, err
+	}
+	results, err := analysis.Analyze(collector.results, r.analysisConfig)
+	if err != nil {
+		return mutation.Outcome{}, err
+	}
+	return mutation.NewOutcome(mutant, results), nil
+}
+
+func writeMutationTable(cmd *cobra.Command, path string, header []string, rows [][]string) error {
+	format := cli.MustGetString(cmd, "format")
+	if !table.IsValidFormat(format) {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return table.Write(w, format, header, rows)
+}
+
+func writeMutationReports(log *logger.Logger, cmd *cobra.Command, outcomes []mutation.Outcome) error {
+	if err := writeMutationTable(cmd, cli.MustGetString(cmd, "output"), mutation.OutcomeTableHeader, mutation.OutcomeTableRows(outcomes)); err != nil {
+		return err
+	}
+	scores := map[string][]mutation.Score{
+		"function-scores":  mutation.FunctionScores(outcomes),
+		"benchmark-scores": mutation.BenchmarkScores(outcomes),
+	}
+	for _, name := range []string{"function-scores", "benchmark-scores"} {
+		path := cli.MustGetString(cmd, name)
+		if path == "" {
+			continue
+		}
+		if err := writeMutationTable(cmd, path, mutation.ScoreTableHeader, mutation.ScoreTableRows(scores[name])); err != nil {
+			return err
+		}
+		log.Infof("%s written to %s", name, path)
+	}
+	return nil
+}
+
+// prepareMutationDirectories creates the results directory and returns the
+// path of the original source.
+func prepareMutationDirectories(log *logger.Logger, cmd *cobra.Command, sourcePathOrRef string) (string, error) {
+	if resultsDirectory := cli.MustGetString(cmd, "results-directory"); resultsDirectory != "" {
+		if err := os.MkdirAll(resultsDirectory, 0o755); err != nil {
+			return "", err
+		}
+	}
+	checkoutDir := filepath.Join(cli.MustGetString(cmd, "benchmark-directory"), "source")
+	return setup.ApplicationBenchmarkPath(log, checkoutDir, cli.MustGetString(cmd, "git-repository"), sourcePathOrRef)
+}
+
+func mutateRun(log *logger.Logger, cmd *cobra.Command, _ []string) error {
+	sourcePathOrRef := cli.MustGetString(cmd, "source")
+	if sourcePathOrRef == "" {
+		return fmt.Errorf("source path or git reference is required")
+	}
+	mutants, err := mutantsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	analysisConfig := analysis.DefaultConfig()
+	analysisConfig.Iterations = cli.MustGetInt(cmd, "bootstrap-iterations")
+	analysisConfig.ConfidenceLevel = cli.MustGetFloat64(cmd, "confidence-level")
+	analysisConfig.Threshold = cli.MustGetFloat64(cmd, "threshold")
+	if err := analysisConfig.Validate(); err != nil {
+		return err
+	}
+
+	log.Info(cli.GetBuildInfo())
+	sourcePath, err := prepareMutationDirectories(log, cmd, sourcePathOrRef)
+	if err != nil {
+		return err
+	}
+	runner := &mutantRunner{
+		log:            log,
+		cmd:            cmd,
+		sourcePath:     sourcePath,
+		seed:           cli.MustGetInt64(cmd, "seed"),
+		analysisConfig: analysisConfig,
+	}
+	if runner.seed == 0 {
+		runner.seed = time.Now().UnixNano()
+	}
+	runner.busyLoopIterations = busyLoopIterations(log, cmd)
+
+	ctx, cancel := cli.NewContext(cli.MustGetDuration(cmd, "timeout"))
+	defer cancel()
+
+	outcomes := make([]mutation.Outcome, 0, len(mutants))
+	for i, mutant := range mutants {
+		log.Infof("mutant %d/%d: %s", i+1, len(mutants), mutant.ID())
+		outcome, err := runner.run(ctx, mutant)
+		if err != nil {
+			return fmt.Errorf("mutant %s: %w", mutant.ID(), err)
+		}
+		log.Infof("mutant %s detected by %d of %d benchmarks", mutant.ID(), len(outcome.Detected), len(outcome.Benchmarks))
+		outcomes = append(outcomes, outcome)
+	}
+	if err := writeMutationReports(log, cmd, outcomes); err != nil {
+		return err
+	}
+	log.Infof("mutation score: %d of %d mutants detected", mutation.Killed(outcomes), len(outcomes))
+	return nil
+}
@@ -0,0 +1,217 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/stats"
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	Improved  = "improved"
+	Regressed = "regressed"
+	Unchanged = "unchanged"
+)
+
+// metrics maps the metric names to the corresponding result values.
+// For all metrics lower values are better.
+var metrics = map[string]func(r microbenchmark.Result) float64{
+	"ops":    func(r microbenchmark.Result) float64 { return r.Ops },
+	"bytes":  func(r microbenchmark.Result) float64 { return r.Bytes },
+	"allocs": func(r microbenchmark.Result) float64 { return r.Allocs },
+}
+
+func IsValidMetric(metric string) bool {
+	_, ok := metrics[metric]
+	return ok
+}
+
+// metricUnits maps the metric names to the units of the result values.
+var metricUnits = map[string]string{
+	"ops":    "sec/op",
+	"bytes":  "B/op",
+	"allocs": "allocs/op",
+}
+
+// MetricValue returns the value of the metric of the result. The metric must
+// be valid.
+func MetricValue(metric string, r microbenchmark.Result) float64 {
+	return metrics[metric](r)
+}
+
+// MetricUnit returns the unit of the metric, e.g. sec/op for ops.
+func MetricUnit(metric string) string {
+	return metricUnits[metric]
+}
+
+type Config struct {
+	Metric          string  // ops (sec/op), bytes (B/op) or allocs (allocs/op)
+	Iterations      int     // bootstrap iterations
+	ConfidenceLevel float64 // in percent, e.g. 99
+	Threshold       float64 // minimal relative change, e.g. 0.01 for 1%
+	Seed            int64
+	// Thresholds override the global threshold for all matching functions
+	// (a maximum ratio of 1.03 corresponds to a threshold of 0.03), the first
+	// matching threshold is used
+	Thresholds []BenchmarkThreshold
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Metric:          "ops",
+		Iterations:      10000,
+		ConfidenceLevel: 99,
+		Threshold:       0,
+		Seed:            42,
+	}
+}
+
+func (c Config) Validate() error {
+	var confErr error
+	if !IsValidMetric(c.Metric) {
+		confErr = multierror.Append(confErr, fmt.Errorf("unsupported metric: %s", c.Metric))
+	}
+	if c.Iterations <= 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("bootstrap iterations must be positive"))
+	}
+	if c.ConfidenceLevel <= 0 || c.ConfidenceLevel >= 100 {
+		confErr = multierror.Append(confErr, fmt.Errorf("confidence level must be between 0 and 100"))
+	}
+	if c.Threshold < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("threshold must not be negative"))
+	}
+	for _, t := range c.Thresholds {
+		if t.MaxRatio < 1 {
+			confErr = multierror.Append(confErr, fmt.Errorf("maximum ratio of %s must not be below 1", t.Pattern))
+		}
+	}
+	return confErr
+}
+
+func (c Config) threshold(function string) float64 {
+	for _, t := range c.Thresholds {
+		if t.Pattern.MatchString(function) {
+			return t.MaxRatio - 1
+		}
+	}
+	return c.Threshold
+}
+
+// Result is the performance change of a single benchmark function (or
+// application benchmark endpoint, see AnalyzeLatencies).
+type Result struct {
+	Function       string
+	N1, N2         int
+	MedianV1       float64
+	MedianV2       float64
+	Ratio          float64 // median(v2) / median(v1)
+	CILower        float64
+	CIUpper        float64
+	Classification string
+}
+
+// Classify marks a change as improvement or regression if the whole
+// confidence interval lies outside of [1-threshold, 1+threshold].
+func Classify(ciLower, ciUpper, threshold float64) string {
+	switch {
+	case ciLower > 1+threshold:
+		return Regressed
+	case ciUpper < 1-threshold:
+		return Improved
+	default:
+		return Unchanged
+	}
+}
+
+type samples struct {
+	v1, v2 []float64
+}
+
+// Analyze groups the results by function and computes the median ratio of
+// v2/v1 together with its percentile bootstrap confidence interval.
+func Analyze(results microbenchmark.Results, config Config) ([]Result, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	functions, grouped, err := groupByFunction(results, config.Metric)
+	if err != nil {
+		return nil, err
+	}
+	return compareAll(functions, grouped, config), nil
+}
+
+// groupByFunction returns the sorted function names and the metric samples of
+// both versions of each function.
+func groupByFunction(results microbenchmark.Results, metricName string) ([]string, map[string]*samples, error) {
+	metric := metrics[metricName]
+	grouped := make(map[string]*samples)
+	for _, r := range results {
+		fn := r.Function.String()
+		if grouped[fn] == nil {
+			grouped[fn] = &samples{}
+		}
+		switch r.Version {
+		case 1:
+			grouped[fn].v1 = append(grouped[fn].v1, metric(r))
+		case 2:
+			grouped[fn].v2 = append(grouped[fn].v2, metric(r))
+		default:
+			return nil, nil, fmt.Errorf("invalid version %d of %s", r.Version, fn)
+		}
+	}
+	functions := make([]string, 0, len(grouped))
+	for fn := range grouped {
+		functions = append(functions, fn)
+	}
+	// the functions are sorted to make the results reproducible for a given seed
+	sort.Strings(functions)
+	return functions, grouped, nil
+}
+
+// compareAll compares the samples of the given names in order.
+func compareAll(names []string, grouped map[string]*samples, config Config) []Result {
+	rng := rand.New(rand.NewSource(config.Seed))
+	analysisResults := make([]Result, 0, len(names))
+	for _, name := range names {
+		s := grouped[name]
+		res := Result{
+			Function: name,
+			N1:       len(s.v1),
+			N2:       len(s.v2),
+			MedianV1: stats.Median(s.v1),
+			MedianV2: stats.Median(s.v2),
+		}
+		res.Ratio = res.MedianV2 / res.MedianV1
+		res.CILower, res.CIUpper = stats.BootstrapMedianRatioCI(rng, s.v1, s.v2, config.Iterations, config.ConfidenceLevel)
+		res.Classification = Classify(res.CILower, res.CIUpper, config.threshold(name))
+		analysisResults = append(analysisResults, res)
+	}
+	return analysisResults
+}
+
+func formatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+var TableHeader = []string{"function", "n v1", "n v2", "median v1", "median v2", "ratio v2/v1", "ci lower", "ci upper", "classification"}
+
+// TableRows returns the results formatted for table.Write.
+func TableRows(results []Result) [][]string {
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, []string{
+			r.Function, strconv.Itoa(r.N1), strconv.Itoa(r.N2),
+			formatFloat(r.MedianV1), formatFloat(r.MedianV2), formatFloat(r.Ratio),
+			formatFloat(r.CILower), formatFloat(r.CIUpper), r.Classification,
+		})
+	}
+	return rows
+}
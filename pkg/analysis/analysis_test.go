@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/stretchr/testify/require"
+)
+
+func testResults() microbenchmark.Results {
+	results := make(microbenchmark.Results, 0)
+	for i := 0; i < 30; i++ {
+		noise := float64(i%5) * 0.01
+		for name, factor := range map[string]float64{"A": 1, "B": 1.5, "C": 0.5} {
+			fn := microbenchmark.Function{PackageName: "pkg", Name: "Benchmark" + name}
+			results = append(results,
+				microbenchmark.Result{Function: fn, Version: 1, Ops: 1 + noise},
+				microbenchmark.Result{Function: fn, Version: 2, Ops: factor * (1 + noise)},
+			)
+		}
+	}
+	return results
+}
+
+func TestAnalyze(t *testing.T) {
+	config := DefaultConfig()
+	config.Iterations = 1000
+	results, err := Analyze(testResults(), config)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	classifications := make(map[string]string)
+	for _, r := range results {
+		require.Equal(t, 30, r.N1)
+		require.Equal(t, 30, r.N2)
+		require.LessOrEqual(t, r.CILower, r.Ratio)
+		require.GreaterOrEqual(t, r.CIUpper, r.Ratio)
+		classifications[r.Function] = r.Classification
+	}
+	require.Equal(t, map[string]string{
+		"pkg.BenchmarkA": Unchanged,
+		"pkg.BenchmarkB": Regressed,
+		"pkg.BenchmarkC": Improved,
+	}, classifications)
+
+	// the same seed leads to the same confidence intervals
+	again, err := Analyze(testResults(), config)
+	require.NoError(t, err)
+	require.Equal(t, results, again)
+}
+
+func TestClassify(t *testing.T) {
+	require.Equal(t, Regressed, Classify(1.06, 1.1, 0.05))
+	require.Equal(t, Unchanged, Classify(1.04, 1.1, 0.05))
+	require.Equal(t, Improved, Classify(0.8, 0.9, 0.05))
+	require.Equal(t, Unchanged, Classify(0.9, 1.1, 0))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := Analyze(nil, Config{Metric: "foo"})
+	require.Error(t, err)
+}
+
+func TestVariability(t *testing.T) {
+	results := testResults()
+	// split the results into two runs and make the second run of BenchmarkA noisy
+	counts := make(map[string]int)
+	for i := range results {
+		key := results[i].Function.Name + strconv.Itoa(results[i].Version)
+		counts[key]++
+		results[i].R = 1 + counts[key]%2
+		if results[i].R == 2 && results[i].Function.Name == "BenchmarkA" && counts[key]%4 == 1 {
+			results[i].Ops *= 3
+		}
+	}
+	config := DefaultVariabilityConfig()
+	config.Iterations = 1000
+	config.GroupBy = GroupByRun
+	variabilities, err := ComputeVariability(results, config)
+	require.NoError(t, err)
+	trusted, flagged := TrustedFunctions(variabilities)
+	require.Equal(t, []string{"pkg.BenchmarkB", "pkg.BenchmarkC"}, trusted)
+	require.Equal(t, []string{"pkg.BenchmarkA"}, flagged)
+
+	instances := InstanceVariabilities(variabilities)
+	require.Len(t, instances, 2)
+	require.Equal(t, 0, instances[0].Flagged)
+	require.Equal(t, 2, instances[1].Flagged)
+}
+
+func TestVariabilityOfZeroValues(t *testing.T) {
+	results := testResults()
+	for i := range results {
+		results[i].R = 1 + i%2
+		results[i].Allocs = 0
+	}
+	config := DefaultVariabilityConfig()
+	config.Iterations = 100
+	config.Metric = "allocs"
+	config.GroupBy = GroupByRun
+	variabilities, err := ComputeVariability(results, config)
+	require.NoError(t, err)
+	for _, v := range variabilities {
+		require.Zero(t, v.CV)
+		require.Zero(t, v.RMAD)
+		require.Zero(t, v.RCIW)
+		require.False(t, v.Flagged())
+	}
+	trusted, flagged := TrustedFunctions(variabilities)
+	require.Len(t, trusted, 3)
+	require.Empty(t, flagged)
+
+	for _, iv := range InstanceVariabilities(variabilities) {
+		require.Zero(t, iv.MedianCV)
+		require.Zero(t, iv.RelativeCV)
+	}
+}
@@ -0,0 +1,303 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/stats"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Overall is the function name of the false-positive rates of all benchmarks.
+const Overall = "(overall)"
+
+type CalibrationConfig struct {
+	Metric     string
+	Iterations int // bootstrap iterations
+	Seed       int64
+	// Splits is the number of random v1/v2 splits of the pooled trials of a
+	// benchmark that are analyzed in addition to the original split.
+	Splits           int
+	Thresholds       []float64 // relative thresholds, e.g. 0.01 for 1%
+	ConfidenceLevels []float64 // in percent, e.g. 99
+	// ConfidenceLevel (one of ConfidenceLevels) and MaxFalsePositiveRate are
+	// used for the recommended thresholds.
+	ConfidenceLevel      float64
+	MaxFalsePositiveRate float64
+}
+
+func DefaultCalibrationConfig() CalibrationConfig {
+	return CalibrationConfig{
+		Metric:               "ops",
+		Iterations:           2000,
+		Seed:                 42,
+		Splits:               20,
+		Thresholds:           []float64{0, 0.01, 0.02, 0.05, 0.1},
+		ConfidenceLevels:     []float64{90, 95, 99},
+		ConfidenceLevel:      99,
+		MaxFalsePositiveRate: 0.05,
+	}
+}
+
+func (c CalibrationConfig) Validate() error {
+	var confErr error
+	if !IsValidMetric(c.Metric) {
+		confErr = multierror.Append(confErr, fmt.Errorf("unsupported metric: %s", c.Metric))
+	}
+	if c.Iterations <= 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("bootstrap iterations must be positive"))
+	}
+	if c.Splits < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("splits must not be negative"))
+	}
+	if c.MaxFalsePositiveRate < 0 || c.MaxFalsePositiveRate >= 1 {
+		confErr = multierror.Append(confErr, fmt.Errorf("maximum false-positive rate must be between 0 and 1"))
+	}
+	if err := c.validateGrid(); err != nil {
+		confErr = multierror.Append(confErr, err)
+	}
+	return confErr
+}
+
+// validateGrid validates the calibrated thresholds and confidence levels.
+func (c CalibrationConfig) validateGrid() error {
+	var confErr error
+	for _, t := range c.Thresholds {
+		if t < 0 {
+			confErr = multierror.Append(confErr, fmt.Errorf("threshold must not be negative"))
+		}
+	}
+	foundLevel := false
+	for _, l := range c.ConfidenceLevels {
+		if l <= 0 || l >= 100 {
+			confErr = multierror.Append(confErr, fmt.Errorf("confidence level must be between 0 and 100"))
+		}
+		foundLevel = foundLevel || l == c.ConfidenceLevel
+	}
+	if !foundLevel {
+		confErr = multierror.Append(confErr, fmt.Errorf("confidence level %s is not one of the calibrated confidence levels", formatFloat(c.ConfidenceLevel)))
+	}
+	return confErr
+}
+
+// FalsePositiveRate is the share of A/A experiments of a benchmark (or of
+// all benchmarks) that were classified as improved or regressed.
+type FalsePositiveRate struct {
+	Function        string
+	ConfidenceLevel float64
+	Threshold       float64
+	Experiments     int
+	FalsePositives  int
+	Rate            float64
+}
+
+// Recommendation is the calibrated threshold of a benchmark.
+type Recommendation struct {
+	Function    string
+	Experiments int
+	// Threshold is the smallest threshold whose false-positive rate does not
+	// exceed the maximum false-positive rate.
+	Threshold         float64
+	FalsePositiveRate float64 // at the recommended threshold
+	// MinDetectableEffect is the smallest relative regression that is expected
+	// to be detected with the recommended threshold: the threshold plus the
+	// median distance between the ratio and the lower bound of the confidence
+	// interval in the A/A experiments.
+	MinDetectableEffect float64
+}
+
+type Calibration struct {
+	Rates           []FalsePositiveRate
+	Recommendations []Recommendation
+}
+
+// aaExperiment is the analysis of one v1/v2 split of a benchmark for each
+// calibrated confidence level.
+type aaExperiment struct {
+	ratio        float64
+	lower, upper []float64
+	excess       []float64 // distance of the confidence intervals from 1
+	valid        bool
+}
+
+func newAAExperiment(rng *rand.Rand, v1, v2 []float64, config CalibrationConfig) aaExperiment {
+	e := aaExperiment{ratio: stats.Median(v2) / stats.Median(v1), valid: true}
+	e.lower, e.upper = stats.BootstrapMedianRatioCIs(rng, v1, v2, config.Iterations, config.ConfidenceLevels)
+	e.excess = make([]float64, len(config.ConfidenceLevels))
+	for i := range config.ConfidenceLevels {
+		// the split is classified as changed if the excess exceeds the threshold
+		e.excess[i] = math.Max(e.lower[i]-1, 1-e.upper[i])
+		e.valid = e.valid && !math.IsNaN(e.excess[i])
+	}
+	return e
+}
+
+// splitSamples randomly assigns the pooled samples of both versions to two
+// groups of the original sizes.
+func splitSamples(rng *rand.Rand, s *samples) (v1, v2 []float64) {
+	pooled := make([]float64, 0, len(s.v1)+len(s.v2))
+	pooled = append(append(pooled, s.v1...), s.v2...)
+	rng.Shuffle(len(pooled), func(i, j int) { pooled[i], pooled[j] = pooled[j], pooled[i] })
+	return pooled[:len(s.v1)], pooled[len(s.v1):]
+}
+
+// recommendThreshold returns the smallest threshold for which at most
+// maxRate of the excesses are above the threshold.
+func recommendThreshold(excess []float64, maxRate float64) float64 {
+	sorted := make([]float64, len(excess))
+	copy(sorted, excess)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	allowed := int(math.Floor(maxRate * float64(len(sorted))))
+	if allowed >= len(sorted) || sorted[allowed] <= 0 {
+		return 0
+	}
+	// rounded up to basis points to keep the thresholds readable
+	return math.Ceil(sorted[allowed]*1e4) / 1e4
+}
+
+func countAbove(values []float64, threshold float64) int {
+	count := 0
+	for _, v := range values {
+		if v > threshold {
+			count++
+		}
+	}
+	return count
+}
+
+// calibrateFunction analyzes the original and the random splits of a
+// benchmark and returns the excesses of all valid splits per confidence
+// level together with the recommended threshold.
+func calibrateFunction(rng *rand.Rand, fn string, s *samples, config CalibrationConfig) ([][]float64, Recommendation) {
+	experiments := []aaExperiment{newAAExperiment(rng, s.v1, s.v2, config)}
+	for i := 0; i < config.Splits; i++ {
+		v1, v2 := splitSamples(rng, s)
+		experiments = append(experiments, newAAExperiment(rng, v1, v2, config))
+	}
+	level := 0
+	for i, l := range config.ConfidenceLevels {
+		if l == config.ConfidenceLevel {
+			level = i
+		}
+	}
+	excess := make([][]float64, len(config.ConfidenceLevels))
+	halfWidths := make([]float64, 0, len(experiments))
+	for _, e := range experiments {
+		if !e.valid {
+			continue
+		}
+		for i := range config.ConfidenceLevels {
+			excess[i] = append(excess[i], e.excess[i])
+		}
+		halfWidths = append(halfWidths, e.ratio-e.lower[level])
+	}
+	recommendation := Recommendation{Function: fn, Experiments: len(halfWidths), FalsePositiveRate: math.NaN(), MinDetectableEffect: math.NaN()}
+	if len(halfWidths) != 0 {
+		recommendation.Threshold = recommendThreshold(excess[level], config.MaxFalsePositiveRate)
+		recommendation.FalsePositiveRate = float64(countAbove(excess[level], recommendation.Threshold)) / float64(len(halfWidths))
+		recommendation.MinDetectableEffect = recommendation.Threshold + stats.Median(halfWidths)
+	}
+	return excess, recommendation
+}
+
+// Calibrate analyzes A/A results (v1 and v2 are the same version) to measure
+// the false-positive rate of the analysis. Every benchmark is analyzed with
+// its original v1/v2 split and with Splits random splits of its pooled
+// trials. Each analyzed split that is classified as improved or regressed is
+// a false positive. Benchmarks without results of both versions are skipped.
+func Calibrate(results microbenchmark.Results, config CalibrationConfig) (*Calibration, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	functions, grouped, err := groupByFunction(results, config.Metric)
+	if err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(config.Seed))
+	calibration := &Calibration{}
+	// excesses of all benchmarks per confidence level
+	overall := make([][]float64, len(config.ConfidenceLevels))
+	for _, fn := range functions {
+		s := grouped[fn]
+		if len(s.v1) == 0 || len(s.v2) == 0 {
+			continue
+		}
+		excess, recommendation := calibrateFunction(rng, fn, s, config)
+		for i, level := range config.ConfidenceLevels {
+			overall[i] = append(overall[i], excess[i]...)
+			calibration.Rates = append(calibration.Rates, falsePositiveRates(fn, level, excess[i], config.Thresholds)...)
+		}
+		if recommendation.Experiments != 0 {
+			calibration.Recommendations = append(calibration.Recommendations, recommendation)
+		}
+	}
+	for i, level := range config.ConfidenceLevels {
+		calibration.Rates = append(calibration.Rates, falsePositiveRates(Overall, level, overall[i], config.Thresholds)...)
+	}
+	return calibration, nil
+}
+
+func falsePositiveRates(fn string, level float64, excess, thresholds []float64) []FalsePositiveRate {
+	rates := make([]FalsePositiveRate, 0, len(thresholds))
+	for _, threshold := range thresholds {
+		rate := FalsePositiveRate{
+			Function:        fn,
+			ConfidenceLevel: level,
+			Threshold:       threshold,
+			Experiments:     len(excess),
+			FalsePositives:  countAbove(excess, threshold),
+			Rate:            math.NaN(),
+		}
+		if rate.Experiments != 0 {
+			rate.Rate = float64(rate.FalsePositives) / float64(rate.Experiments)
+		}
+		rates = append(rates, rate)
+	}
+	return rates
+}
+
+var FalsePositiveRateTableHeader = []string{"function", "confidence level", "threshold", "experiments", "false positives", "false-positive rate"}
+
+func FalsePositiveRateTableRows(rates []FalsePositiveRate) [][]string {
+	rows := make([][]string, 0, len(rates))
+	for _, r := range rates {
+		rows = append(rows, []string{
+			r.Function, formatFloat(r.ConfidenceLevel), formatFloat(r.Threshold),
+			strconv.Itoa(r.Experiments), strconv.Itoa(r.FalsePositives), formatFloat(r.Rate),
+		})
+	}
+	return rows
+}
+
+var RecommendationTableHeader = []string{"function", "experiments", "threshold", "max ratio", "false-positive rate", "min detectable effect"}
+
+func RecommendationTableRows(recommendations []Recommendation) [][]string {
+	rows := make([][]string, 0, len(recommendations))
+	for _, r := range recommendations {
+		rows = append(rows, []string{
+			r.Function, strconv.Itoa(r.Experiments), formatFloat(r.Threshold), formatFloat(1 + r.Threshold),
+			formatFloat(r.FalsePositiveRate), formatFloat(r.MinDetectableEffect),
+		})
+	}
+	return rows
+}
+
+// WriteThresholds writes the recommended thresholds as thresholds file (see
+// ReadBenchmarkThresholds) that can be used by the analysis and the gate.
+func WriteThresholds(w io.Writer, confidenceLevel float64, recommendations []Recommendation) error {
+	if _, err := fmt.Fprintf(w, "# calibrated at a confidence level of %s%%\n", formatFloat(confidenceLevel)); err != nil {
+		return err
+	}
+	for _, r := range recommendations {
+		if _, err := fmt.Fprintf(w, "^%s$=%s\n", regexp.QuoteMeta(r.Function), formatFloat(1+r.Threshold)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/stretchr/testify/require"
+)
+
+func aaResults() microbenchmark.Results {
+	rng := rand.New(rand.NewSource(1))
+	results := make(microbenchmark.Results, 0)
+	for i := 0; i < 20; i++ {
+		for _, b := range []struct {
+			name  string
+			noise float64
+		}{{"Quiet", 0.001}, {"Noisy", 0.2}} {
+			fn := microbenchmark.Function{PackageName: "pkg", Name: "Benchmark" + b.name}
+			for version := 1; version <= 2; version++ {
+				results = append(results, microbenchmark.Result{Function: fn, Version: version, Ops: 1 + rng.Float64()*b.noise})
+			}
+		}
+	}
+	return results
+}
+
+func TestCalibrate(t *testing.T) {
+	config := DefaultCalibrationConfig()
+	config.Iterations = 500
+	calibration, err := Calibrate(aaResults(), config)
+	require.NoError(t, err)
+	// 2 functions and the overall rates for every threshold and confidence level
+	require.Len(t, calibration.Rates, 3*len(config.Thresholds)*len(config.ConfidenceLevels))
+	for _, r := range calibration.Rates {
+		require.GreaterOrEqual(t, r.Rate, 0.0)
+		require.LessOrEqual(t, r.Rate, 1.0)
+		if r.Function == Overall {
+			require.Equal(t, 2*(config.Splits+1), r.Experiments)
+		}
+	}
+
+	require.Len(t, calibration.Recommendations, 2)
+	recommendations := make(map[string]Recommendation)
+	for _, r := range calibration.Recommendations {
+		require.Equal(t, config.Splits+1, r.Experiments)
+		require.LessOrEqual(t, r.FalsePositiveRate, config.MaxFalsePositiveRate)
+		require.Greater(t, r.MinDetectableEffect, r.Threshold)
+		recommendations[r.Function] = r
+	}
+	require.Less(t, recommendations["pkg.BenchmarkQuiet"].MinDetectableEffect, recommendations["pkg.BenchmarkNoisy"].MinDetectableEffect)
+
+	config.ConfidenceLevel = 80
+	_, err = Calibrate(aaResults(), config)
+	require.Error(t, err)
+}
+
+func TestThresholdsFile(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteThresholds(buf, 99, []Recommendation{{Function: "pkg.BenchmarkB", Threshold: 0.6}}))
+	thresholds, err := ReadBenchmarkThresholds(buf)
+	require.NoError(t, err)
+	require.Len(t, thresholds, 1)
+	require.True(t, thresholds[0].Pattern.MatchString("pkg.BenchmarkB"))
+	require.False(t, thresholds[0].Pattern.MatchString("pkg.BenchmarkBB"))
+	require.Equal(t, 1.6, thresholds[0].MaxRatio)
+
+	// the per-function threshold hides the regression of B
+	config := DefaultConfig()
+	config.Iterations = 1000
+	config.Thresholds = thresholds
+	results, err := Analyze(testResults(), config)
+	require.NoError(t, err)
+	for _, r := range results {
+		if r.Function == "pkg.BenchmarkB" {
+			require.Equal(t, Unchanged, r.Classification)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/resultstore"
+	"github.com/christophwitzko/masters-thesis/pkg/stats"
+	"github.com/hashicorp/go-multierror"
+)
+
+type ChangePointConfig struct {
+	MinSize           int // minimal number of commits per segment
+	Permutations      int // permutations of the significance test
+	SignificanceLevel float64
+	Seed              int64
+}
+
+func DefaultChangePointConfig() ChangePointConfig {
+	return ChangePointConfig{
+		MinSize:           3,
+		Permutations:      199,
+		SignificanceLevel: 0.05,
+		Seed:              42,
+	}
+}
+
+func (c ChangePointConfig) Validate() error {
+	var confErr error
+	if c.MinSize < 2 {
+		confErr = multierror.Append(confErr, fmt.Errorf("minimal segment size must be at least 2"))
+	}
+	if c.Permutations <= 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("permutations must be positive"))
+	}
+	if c.SignificanceLevel <= 0 || c.SignificanceLevel >= 1 {
+		confErr = multierror.Append(confErr, fmt.Errorf("significance level must be between 0 and 1"))
+	}
+	return confErr
+}
+
+// ChangePoint is a commit at which the performance of a function shifted.
+type ChangePoint struct {
+	Function string
+	Previous resultstore.Commit // last commit before the shift
+	Commit   resultstore.Commit // first commit after the shift
+	// Before and After are the medians of the per-commit medians of the
+	// segments before and after the change point
+	Before, After float64
+	Ratio         float64 // After / Before
+	PValue        float64
+}
+
+// DetectChangePoints runs E-Divisive means over the per-commit medians of each
+// function. In contrast to a single experiment this also detects gradual
+// changes or shifts that were not significant in any pairwise comparison.
+func DetectChangePoints(series []resultstore.Series, config ChangePointConfig) ([]ChangePoint, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(config.Seed))
+	changePoints := make([]ChangePoint, 0)
+	for _, s := range series {
+		detected := stats.EDivisive(rng, s.Medians, config.MinSize, config.Permutations, config.SignificanceLevel)
+		for i, cp := range detected {
+			start, end := 0, len(s.Medians)
+			if i > 0 {
+				start = detected[i-1].Index
+			}
+			if i < len(detected)-1 {
+				end = detected[i+1].Index
+			}
+			before := stats.Median(s.Medians[start:cp.Index])
+			after := stats.Median(s.Medians[cp.Index:end])
+			changePoints = append(changePoints, ChangePoint{
+				Function: s.Function,
+				Previous: s.Commits[cp.Index-1],
+				Commit:   s.Commits[cp.Index],
+				Before:   before,
+				After:    after,
+				Ratio:    after / before,
+				PValue:   cp.PValue,
+			})
+		}
+	}
+	return changePoints, nil
+}
+
+var ChangePointTableHeader = []string{"function", "previous commit", "commit", "reference", "time", "median before", "median after", "ratio", "p-value"}
+
+func shortCommit(c resultstore.Commit) string {
+	id := c.ID()
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func ChangePointTableRows(changePoints []ChangePoint) [][]string {
+	rows := make([][]string, 0, len(changePoints))
+	for _, cp := range changePoints {
+		rows = append(rows, []string{
+			cp.Function, shortCommit(cp.Previous), shortCommit(cp.Commit), cp.Commit.Reference,
+			cp.Commit.Time.UTC().Format(time.RFC3339),
+			formatFloat(cp.Before), formatFloat(cp.After), formatFloat(cp.Ratio), formatFloat(cp.PValue),
+		})
+	}
+	return rows
+}
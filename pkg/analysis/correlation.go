@@ -0,0 +1,218 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/profile"
+)
+
+const (
+	Agreement = "agreement" // both levels report the same classification
+	MBOnly    = "mb-only"   // only the microbenchmark detected a change
+	ABOnly    = "ab-only"   // only the application benchmark detected a change
+	Conflict  = "conflict"  // the levels detected opposite changes
+	Unlinked  = "unlinked"  // unchanged and not linked to the other level
+)
+
+// EndpointHandler maps an endpoint to the functions handling its requests.
+type EndpointHandler struct {
+	Endpoint string
+	Pattern  *regexp.Regexp
+}
+
+// ParseEndpointHandler parses "<endpoint>=<regexp>", e.g.
+// "GET /flights=handlers\.GetFlights".
+func ParseEndpointHandler(s string) (EndpointHandler, error) {
+	idx := strings.LastIndex(s, "=")
+	if idx <= 0 {
+		return EndpointHandler{}, fmt.Errorf("invalid endpoint handler %s (expected <endpoint>=<regexp>)", s)
+	}
+	pattern, err := regexp.Compile(s[idx+1:])
+	if err != nil {
+		return EndpointHandler{}, fmt.Errorf("invalid endpoint handler pattern %s: %w", s[idx+1:], err)
+	}
+	return EndpointHandler{Endpoint: s[:idx], Pattern: pattern}, nil
+}
+
+type LinkConfig struct {
+	// MinCoverage is the minimal share of the cpu time of an endpoint that
+	// has to pass through functions executed by a benchmark to link them.
+	MinCoverage float64
+	// Handlers restrict the hot path of an endpoint to the stacks below its
+	// handler. Endpoints without handler use the whole application profile.
+	Handlers []EndpointHandler
+	// Ignore excludes functions (e.g. shared helpers) from the linking.
+	Ignore *regexp.Regexp
+	// IncludeStdlib also links benchmarks and endpoints via functions of the
+	// standard library and the runtime.
+	IncludeStdlib bool
+}
+
+func (c LinkConfig) keepFunction(fn string) bool {
+	// the entry point of the test binary and the application links everything
+	if fn == "main.main" || (c.Ignore != nil && c.Ignore.MatchString(fn)) {
+		return false
+	}
+	return c.IncludeStdlib || !profile.IsStandardLibrary(fn)
+}
+
+func (c LinkConfig) handler(endpoint string) *regexp.Regexp {
+	for _, h := range c.Handlers {
+		if h.Endpoint == endpoint {
+			return h.Pattern
+		}
+	}
+	return nil
+}
+
+// Link connects a benchmark with an endpoint whose hot path it covers.
+type Link struct {
+	Benchmark string
+	Endpoint  string
+	Coverage  float64 // share of the endpoint cpu time covered by the benchmark
+}
+
+// LinkBenchmarks links every benchmark to the endpoints whose hot paths pass
+// through functions that are executed by the benchmark. The benchmark
+// profiles are keyed by function name, the application stacks are the merged
+// profiles of the application benchmark.
+func LinkBenchmarks(benchmarkProfiles map[string][]profile.Stack, appStacks []profile.Stack, endpoints []string, config LinkConfig) []Link {
+	hotPaths := make(map[string][]profile.Stack, len(endpoints))
+	for _, endpoint := range endpoints {
+		hotPaths[endpoint] = appStacks
+		if handler := config.handler(endpoint); handler != nil {
+			hotPaths[endpoint] = profile.HotPath(appStacks, handler)
+		}
+	}
+	benchmarks := make([]string, 0, len(benchmarkProfiles))
+	for benchmark := range benchmarkProfiles {
+		benchmarks = append(benchmarks, benchmark)
+	}
+	sort.Strings(benchmarks)
+	links := make([]Link, 0)
+	for _, benchmark := range benchmarks {
+		functions := profile.Functions(benchmarkProfiles[benchmark], config.keepFunction)
+		for _, endpoint := range endpoints {
+			coverage := profile.Coverage(hotPaths[endpoint], functions)
+			if coverage > 0 && coverage >= config.MinCoverage {
+				links = append(links, Link{Benchmark: benchmark, Endpoint: endpoint, Coverage: coverage})
+			}
+		}
+	}
+	return links
+}
+
+// Correlation compares the microbenchmark result of a benchmark with the
+// application benchmark result of a linked endpoint. MB or AB is nil if the
+// benchmark or endpoint is not linked.
+type Correlation struct {
+	Benchmark string
+	Endpoint  string
+	Coverage  float64
+	MB, AB    *Result
+	Agreement string
+}
+
+func agreement(mb, ab string) string {
+	switch {
+	case mb == ab:
+		return Agreement
+	case ab == Unchanged:
+		return MBOnly
+	case mb == Unchanged:
+		return ABOnly
+	default:
+		return Conflict
+	}
+}
+
+func unlinked(classification, detected string) string {
+	if classification == Unchanged {
+		return Unlinked
+	}
+	return detected
+}
+
+// Correlate compares the classifications of all linked benchmarks and
+// endpoints. Benchmarks and endpoints without link are reported separately.
+func Correlate(mb, ab []Result, links []Link) []Correlation {
+	mbResults := make(map[string]*Result, len(mb))
+	for i := range mb {
+		mbResults[mb[i].Function] = &mb[i]
+	}
+	abResults := make(map[string]*Result, len(ab))
+	for i := range ab {
+		abResults[ab[i].Function] = &ab[i]
+	}
+	linkedBenchmarks := make(map[string]bool)
+	linkedEndpoints := make(map[string]bool)
+	correlations := make([]Correlation, 0, len(links))
+	for _, link := range links {
+		mbResult, abResult := mbResults[link.Benchmark], abResults[link.Endpoint]
+		if mbResult == nil || abResult == nil {
+			continue
+		}
+		linkedBenchmarks[link.Benchmark] = true
+		linkedEndpoints[link.Endpoint] = true
+		correlations = append(correlations, Correlation{
+			Benchmark: link.Benchmark,
+			Endpoint:  link.Endpoint,
+			Coverage:  link.Coverage,
+			MB:        mbResult,
+			AB:        abResult,
+			Agreement: agreement(mbResult.Classification, abResult.Classification),
+		})
+	}
+	for i := range mb {
+		if !linkedBenchmarks[mb[i].Function] {
+			correlations = append(correlations, Correlation{Benchmark: mb[i].Function, MB: &mb[i], Agreement: unlinked(mb[i].Classification, MBOnly)})
+		}
+	}
+	for i := range ab {
+		if !linkedEndpoints[ab[i].Function] {
+			correlations = append(correlations, Correlation{Endpoint: ab[i].Function, AB: &ab[i], Agreement: unlinked(ab[i].Classification, ABOnly)})
+		}
+	}
+	return correlations
+}
+
+// CorrelationSummary counts the correlations per agreement category.
+func CorrelationSummary(correlations []Correlation) map[string]int {
+	summary := make(map[string]int)
+	for _, c := range correlations {
+		summary[c.Agreement]++
+	}
+	return summary
+}
+
+var CorrelationTableHeader = []string{
+	"benchmark", "endpoint", "coverage",
+	"mb ratio", "mb ci lower", "mb ci upper", "mb classification",
+	"ab ratio", "ab ci lower", "ab ci upper", "ab classification",
+	"agreement",
+}
+
+func resultColumns(r *Result) []string {
+	if r == nil {
+		return []string{"", "", "", ""}
+	}
+	return []string{formatFloat(r.Ratio), formatFloat(r.CILower), formatFloat(r.CIUpper), r.Classification}
+}
+
+func CorrelationTableRows(correlations []Correlation) [][]string {
+	rows := make([][]string, 0, len(correlations))
+	for _, c := range correlations {
+		coverage := ""
+		if c.MB != nil && c.AB != nil {
+			coverage = formatFloat(c.Coverage)
+		}
+		row := []string{c.Benchmark, c.Endpoint, coverage}
+		row = append(row, resultColumns(c.MB)...)
+		row = append(row, resultColumns(c.AB)...)
+		rows = append(rows, append(row, c.Agreement))
+	}
+	return rows
+}
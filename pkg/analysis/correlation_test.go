@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/christophwitzko/masters-thesis/pkg/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkBenchmarks(t *testing.T) {
+	appStacks := []profile.Stack{
+		{Functions: []string{"net/http.(*conn).serve", "example.com/app.GetFlights", "example.com/app/db.Query", "runtime.mallocgc"}, Value: 60},
+		{Functions: []string{"net/http.(*conn).serve", "example.com/app.GetSeats", "example.com/app/seats.Render"}, Value: 40},
+	}
+	benchmarkProfiles := map[string][]profile.Stack{
+		"db.BenchmarkQuery":  {{Functions: []string{"testing.(*B).runN", "example.com/app/db.BenchmarkQuery", "example.com/app/db.Query", "runtime.mallocgc"}, Value: 1}},
+		"app.BenchmarkAlloc": {{Functions: []string{"testing.(*B).runN", "runtime.mallocgc"}, Value: 1}},
+	}
+	handler, err := ParseEndpointHandler(`GET /seats=app\.GetSeats$`)
+	require.NoError(t, err)
+
+	links := LinkBenchmarks(benchmarkProfiles, appStacks, []string{"GET /flights", "GET /seats"}, LinkConfig{
+		MinCoverage: 0.05,
+		Handlers:    []EndpointHandler{handler},
+	})
+	// the standard library is ignored and the seats hot path is restricted to its handler
+	require.Equal(t, []Link{{Benchmark: "db.BenchmarkQuery", Endpoint: "GET /flights", Coverage: 0.6}}, links)
+
+	links = LinkBenchmarks(benchmarkProfiles, appStacks, []string{"GET /flights"}, LinkConfig{IncludeStdlib: true})
+	require.Len(t, links, 2)
+}
+
+func TestCorrelate(t *testing.T) {
+	mb := []Result{
+		{Function: "pkg.BenchmarkA", Classification: Regressed},
+		{Function: "pkg.BenchmarkB", Classification: Regressed},
+		{Function: "pkg.BenchmarkC", Classification: Unchanged},
+		{Function: "pkg.BenchmarkD", Classification: Improved},
+	}
+	ab := []Result{
+		{Function: "GET /a", Classification: Regressed},
+		{Function: "GET /b", Classification: Unchanged},
+		{Function: "GET /c", Classification: Improved},
+	}
+	links := []Link{
+		{Benchmark: "pkg.BenchmarkA", Endpoint: "GET /a", Coverage: 0.5},
+		{Benchmark: "pkg.BenchmarkB", Endpoint: "GET /b", Coverage: 0.5},
+		{Benchmark: "pkg.BenchmarkC", Endpoint: "GET /a", Coverage: 0.1},
+	}
+	correlations := Correlate(mb, ab, links)
+	agreements := make([]string, 0, len(correlations))
+	for _, c := range correlations {
+		agreements = append(agreements, c.Benchmark+"|"+c.Endpoint+"|"+c.Agreement)
+	}
+	require.Equal(t, []string{
+		"pkg.BenchmarkA|GET /a|agreement",
+		"pkg.BenchmarkB|GET /b|mb-only",
+		"pkg.BenchmarkC|GET /a|ab-only",
+		"pkg.BenchmarkD||mb-only",
+		"|GET /c|ab-only",
+	}, agreements)
+	require.Len(t, CorrelationTableRows(correlations)[0], len(CorrelationTableHeader))
+}
@@ -0,0 +1,392 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/stats"
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	// ReasonVerdicts: the runs or experiments report contradictory
+	// classifications (improved and regressed) or no clear majority
+	ReasonVerdicts = "contradictory-verdicts"
+	// ReasonBimodality: the trials within the runs form several clusters
+	ReasonBimodality = "bimodality"
+	// ReasonDrift: the trials drift within a run
+	ReasonDrift = "drift"
+	// ReasonInstance: the distributions of the same version differ between
+	// runs, i.e. depend on the instance
+	ReasonInstance = "instance-dependence"
+)
+
+type FlakyConfig struct {
+	Analysis Config // analysis of the per-run verdicts
+	// SignificanceLevel of the homogeneity test between runs and of the trend
+	// test within runs
+	SignificanceLevel float64
+	// MinEffect is the minimal relative difference between run medians or
+	// between the beginning and the end of a run
+	MinEffect float64
+	// MinAgreement is the minimal share of runs with the majority verdict
+	MinAgreement float64
+	// MaxBimodality is the maximal bimodality coefficient (5/9 for the
+	// uniform distribution)
+	MaxBimodality float64
+	// MinBimodalitySamples is the minimal amount of trials of a version in an
+	// experiment to compute the bimodality coefficient
+	MinBimodalitySamples int
+}
+
+func DefaultFlakyConfig() FlakyConfig {
+	return FlakyConfig{
+		Analysis:             DefaultConfig(),
+		SignificanceLevel:    0.01,
+		MinEffect:            0.05,
+		MinAgreement:         0.75,
+		MaxBimodality:        5.0 / 9,
+		MinBimodalitySamples: 20,
+	}
+}
+
+func (c FlakyConfig) Validate() error {
+	var confErr error
+	if err := c.Analysis.Validate(); err != nil {
+		confErr = multierror.Append(confErr, err)
+	}
+	if c.SignificanceLevel <= 0 || c.SignificanceLevel >= 1 {
+		confErr = multierror.Append(confErr, fmt.Errorf("significance level must be between 0 and 1"))
+	}
+	if c.MinEffect < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("minimal effect must not be negative"))
+	}
+	if c.MinAgreement < 0 || c.MinAgreement > 1 {
+		confErr = multierror.Append(confErr, fmt.Errorf("minimal agreement must be between 0 and 1"))
+	}
+	if c.MaxBimodality <= 0 || c.MinBimodalitySamples < 4 {
+		confErr = multierror.Append(confErr, fmt.Errorf("bimodality limit must be positive and require at least 4 samples"))
+	}
+	return confErr
+}
+
+// FlakyBenchmark summarizes the consistency of a benchmark across the runs of
+// all experiments. The statistics are the most extreme values over all
+// experiments, runs and versions.
+type FlakyBenchmark struct {
+	Function string
+	Verdicts map[string]int // per-run classifications
+	// Agreement is the share of runs with the majority verdict
+	Agreement float64
+	// InstancePValue and RunSpread (max / min run median - 1) of the
+	// homogeneity test between the runs of a version
+	InstancePValue, RunSpread float64
+	// DriftPValue and Drift (relative change of the median between the first
+	// and the second half of a run) of the trend test within a run
+	DriftPValue, Drift float64
+	Bimodality         float64
+	Reasons            []string
+}
+
+func (f FlakyBenchmark) Flaky() bool {
+	return len(f.Reasons) != 0
+}
+
+// runKey identifies the trials of a run of an experiment.
+type runKey struct {
+	experiment, run int
+}
+
+type flakySamples struct {
+	// trials of both versions per run in execution order (suite, index)
+	runs map[runKey][2]microbenchmark.Results
+	// verdicts of all runs
+	verdicts map[string]int
+}
+
+// addExperiment groups the results of the experiment by function and run and
+// counts the verdicts of each run.
+func addExperiment(grouped map[string]*flakySamples, experiment int, results microbenchmark.Results, config Config) error {
+	byRun := make(map[int]microbenchmark.Results)
+	for _, r := range results {
+		if r.Version != 1 && r.Version != 2 {
+			return fmt.Errorf("invalid version %d of %s", r.Version, r.Function)
+		}
+		byRun[r.R] = append(byRun[r.R], r)
+		fn := r.Function.String()
+		if grouped[fn] == nil {
+			grouped[fn] = &flakySamples{runs: make(map[runKey][2]microbenchmark.Results), verdicts: make(map[string]int)}
+		}
+		key := runKey{experiment: experiment, run: r.R}
+		versions := grouped[fn].runs[key]
+		versions[r.Version-1] = append(versions[r.Version-1], r)
+		grouped[fn].runs[key] = versions
+	}
+	for _, runResults := range byRun {
+		analyzed, err := Analyze(runResults, config)
+		if err != nil {
+			return err
+		}
+		for _, a := range analyzed {
+			if a.N1 != 0 && a.N2 != 0 {
+				grouped[a.Function].verdicts[a.Classification]++
+			}
+		}
+	}
+	return nil
+}
+
+// DetectFlaky analyzes each run of each experiment (e.g. the results of one
+// location) separately and compares the verdicts and distributions across
+// runs to detect flaky benchmarks.
+func DetectFlaky(experiments []microbenchmark.Results, config FlakyConfig) ([]FlakyBenchmark, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	grouped := make(map[string]*flakySamples)
+	for e, results := range experiments {
+		if err := addExperiment(grouped, e, results, config.Analysis); err != nil {
+			return nil, err
+		}
+	}
+	functions := make([]string, 0, len(grouped))
+	for fn := range grouped {
+		functions = append(functions, fn)
+	}
+	sort.Strings(functions)
+	flaky := make([]FlakyBenchmark, 0, len(functions))
+	for _, fn := range functions {
+		flaky = append(flaky, grouped[fn].check(fn, len(experiments), config))
+	}
+	return flaky, nil
+}
+
+func (s *flakySamples) sortedRuns() []runKey {
+	keys := make([]runKey, 0, len(s.runs))
+	for key := range s.runs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].experiment != keys[j].experiment {
+			return keys[i].experiment < keys[j].experiment
+		}
+		return keys[i].run < keys[j].run
+	})
+	return keys
+}
+
+func metricValues(results microbenchmark.Results, metric func(r microbenchmark.Result) float64) []float64 {
+	ordered := make(microbenchmark.Results, len(results))
+	copy(ordered, results)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].S != ordered[j].S {
+			return ordered[i].S < ordered[j].S
+		}
+		return ordered[i].I < ordered[j].I
+	})
+	values := make([]float64, len(ordered))
+	for i, r := range ordered {
+		values[i] = metric(r)
+	}
+	return values
+}
+
+// versionRuns returns the values of each run of an experiment per version.
+func (s *flakySamples) versionRuns(metric func(r microbenchmark.Result) float64) map[int][2][][]float64 {
+	experiments := make(map[int][2][][]float64)
+	for _, key := range s.sortedRuns() {
+		runs := experiments[key.experiment]
+		for v, results := range s.runs[key] {
+			if len(results) != 0 {
+				runs[v] = append(runs[v], metricValues(results, metric))
+			}
+		}
+		experiments[key.experiment] = runs
+	}
+	return experiments
+}
+
+func (s *flakySamples) check(fn string, experiments int, config FlakyConfig) FlakyBenchmark {
+	f := FlakyBenchmark{
+		Function:       fn,
+		Verdicts:       s.verdicts,
+		Agreement:      verdictAgreement(s.verdicts),
+		InstancePValue: math.NaN(),
+		RunSpread:      math.NaN(),
+		DriftPValue:    math.NaN(),
+		Drift:          math.NaN(),
+		Bimodality:     math.NaN(),
+	}
+	if s.verdicts[Improved] != 0 && s.verdicts[Regressed] != 0 || f.Agreement < config.MinAgreement {
+		f.Reasons = append(f.Reasons, ReasonVerdicts)
+	}
+	bimodal, drifting, instanceDependent := false, false, false
+	versionRuns := s.versionRuns(metrics[config.Analysis.Metric])
+	for e := 0; e < experiments; e++ {
+		for _, runs := range versionRuns[e] {
+			bimodal = f.checkBimodality(runs, config) || bimodal
+			drifting = f.checkDrift(runs, config) || drifting
+			instanceDependent = f.checkInstances(runs, config) || instanceDependent
+		}
+	}
+	for _, reason := range []struct {
+		detected bool
+		name     string
+	}{{bimodal, ReasonBimodality}, {drifting, ReasonDrift}, {instanceDependent, ReasonInstance}} {
+		if reason.detected {
+			f.Reasons = append(f.Reasons, reason.name)
+		}
+	}
+	return f
+}
+
+func verdictAgreement(verdicts map[string]int) float64 {
+	total, majority := 0, 0
+	for _, count := range verdicts {
+		total += count
+		if count > majority {
+			majority = count
+		}
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+	return float64(majority) / float64(total)
+}
+
+// minNaN returns the smaller value, NaN is ignored.
+func minNaN(a, b float64) float64 {
+	if math.IsNaN(a) || b < a {
+		return b
+	}
+	return a
+}
+
+// maxNaN returns the larger value, NaN is ignored.
+func maxNaN(a, b float64) float64 {
+	if math.IsNaN(a) || b > a {
+		return b
+	}
+	return a
+}
+
+// checkBimodality computes the bimodality coefficient of the trials of all
+// runs normalized by their run median (to not mistake instance dependence
+// for bimodality). Runs with a zero median (e.g. no allocations) cannot be
+// normalized and are skipped.
+func (f *FlakyBenchmark) checkBimodality(runs [][]float64, config FlakyConfig) bool {
+	normalized := make([]float64, 0)
+	for _, values := range runs {
+		median := stats.Median(values)
+		if median == 0 {
+			continue
+		}
+		for _, v := range values {
+			normalized = append(normalized, v/median)
+		}
+	}
+	if len(normalized) < config.MinBimodalitySamples {
+		return false
+	}
+	bc := stats.BimodalityCoefficient(normalized)
+	f.Bimodality = maxNaN(f.Bimodality, bc)
+	return bc > config.MaxBimodality
+}
+
+// checkDrift tests each run for a monotonic trend. Runs whose first half has
+// a zero median have no relative drift and are skipped.
+func (f *FlakyBenchmark) checkDrift(runs [][]float64, config FlakyConfig) bool {
+	detected := false
+	for _, values := range runs {
+		half := len(values) / 2
+		if half == 0 {
+			continue
+		}
+		firstMedian := stats.Median(values[:half])
+		if firstMedian == 0 {
+			continue
+		}
+		_, pValue := stats.MannKendall(values)
+		drift := stats.Median(values[half:])/firstMedian - 1
+		f.DriftPValue = minNaN(f.DriftPValue, pValue)
+		if math.IsNaN(f.Drift) || math.Abs(drift) > math.Abs(f.Drift) {
+			f.Drift = drift
+		}
+		detected = detected || (pValue < config.SignificanceLevel && math.Abs(drift) > config.MinEffect)
+	}
+	return detected
+}
+
+// checkInstances tests whether the trials of all runs come from the same
+// distribution.
+func (f *FlakyBenchmark) checkInstances(runs [][]float64, config FlakyConfig) bool {
+	if len(runs) < 2 {
+		return false
+	}
+	_, pValue := stats.KruskalWallis(runs)
+	lowest, highest := math.NaN(), math.NaN()
+	for _, values := range runs {
+		median := stats.Median(values)
+		lowest, highest = minNaN(lowest, median), maxNaN(highest, median)
+	}
+	f.InstancePValue = minNaN(f.InstancePValue, pValue)
+	if lowest == 0 {
+		// no relative spread to the zero median of a run
+		return false
+	}
+	spread := highest/lowest - 1
+	f.RunSpread = maxNaN(f.RunSpread, spread)
+	return pValue < config.SignificanceLevel && spread > config.MinEffect
+}
+
+var FlakyTableHeader = []string{
+	"function", "improved", "regressed", "unchanged", "agreement",
+	"instance p-value", "run spread", "drift p-value", "drift", "bimodality", "flaky", "reasons",
+}
+
+func FlakyTableRows(flaky []FlakyBenchmark) [][]string {
+	rows := make([][]string, 0, len(flaky))
+	for _, f := range flaky {
+		rows = append(rows, []string{
+			f.Function, strconv.Itoa(f.Verdicts[Improved]), strconv.Itoa(f.Verdicts[Regressed]), strconv.Itoa(f.Verdicts[Unchanged]),
+			formatFloat(f.Agreement), formatFloat(f.InstancePValue), formatFloat(f.RunSpread),
+			formatFloat(f.DriftPValue), formatFloat(f.Drift), formatFloat(f.Bimodality),
+			strconv.FormatBool(f.Flaky()), strings.Join(f.Reasons, " "),
+		})
+	}
+	return rows
+}
+
+// WriteQuarantine writes the flaky benchmarks as quarantine list with one
+// function per line (see microbenchmark.ReadQuarantine). The first comment line contains an
+// equivalent exclude filter of the microbenchmark runner.
+func WriteQuarantine(w io.Writer, flaky []FlakyBenchmark) error {
+	quarantined := make([]string, 0)
+	for _, f := range flaky {
+		if f.Flaky() {
+			quarantined = append(quarantined, regexp.QuoteMeta(f.Function))
+		}
+	}
+	excludeFilter := "^$"
+	if len(quarantined) != 0 {
+		excludeFilter = "^(" + strings.Join(quarantined, "|") + ")$"
+	}
+	if _, err := fmt.Fprintf(w, "# exclude-filter: %s\n", excludeFilter); err != nil {
+		return err
+	}
+	for _, f := range flaky {
+		if !f.Flaky() {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s # %s\n", f.Function, strings.Join(f.Reasons, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyResults returns 3 runs with 15 trials per version of benchmarks with
+// different kinds of flakiness.
+func flakyResults() microbenchmark.Results {
+	rng := rand.New(rand.NewSource(1))
+	values := map[string]func(run, version, trial int) float64{
+		"Stable":        func(_, _, _ int) float64 { return 1 },
+		"Instance":      func(run, _, _ int) float64 { return 1 + 0.2*float64(run) },
+		"Drift":         func(_, _, trial int) float64 { return 1 + 0.02*float64(trial) },
+		"Bimodal":       func(_, _, trial int) float64 { return 1 + 0.5*float64(trial%2) },
+		"Contradictory": func(run, version, _ int) float64 { return 1 + float64(version-1)*0.5*float64(2-run) },
+	}
+	results := make(microbenchmark.Results, 0)
+	for _, name := range []string{"Stable", "Instance", "Drift", "Bimodal", "Contradictory"} {
+		value := values[name]
+		fn := microbenchmark.Function{PackageName: "pkg", Name: "Benchmark" + name}
+		for run := 1; run <= 3; run++ {
+			for trial := 0; trial < 15; trial++ {
+				for version := 1; version <= 2; version++ {
+					results = append(results, microbenchmark.Result{
+						Function: fn, R: run, S: trial/5 + 1, I: trial%5 + 1, Version: version,
+						Ops: value(run, version, trial) * (1 + rng.NormFloat64()*0.005),
+					})
+				}
+			}
+		}
+	}
+	return results
+}
+
+func TestDetectFlaky(t *testing.T) {
+	config := DefaultFlakyConfig()
+	config.Analysis.Iterations = 1000
+	flaky, err := DetectFlaky([]microbenchmark.Results{flakyResults()}, config)
+	require.NoError(t, err)
+	require.Len(t, flaky, 5)
+
+	reasons := make(map[string][]string)
+	for _, f := range flaky {
+		reasons[f.Function] = f.Reasons
+	}
+	require.Empty(t, reasons["pkg.BenchmarkStable"])
+	require.Contains(t, reasons["pkg.BenchmarkInstance"], ReasonInstance)
+	require.Contains(t, reasons["pkg.BenchmarkDrift"], ReasonDrift)
+	require.Contains(t, reasons["pkg.BenchmarkBimodal"], ReasonBimodality)
+	require.Contains(t, reasons["pkg.BenchmarkContradictory"], ReasonVerdicts)
+	require.NotContains(t, reasons["pkg.BenchmarkInstance"], ReasonVerdicts)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteQuarantine(buf, flaky))
+	quarantined, err := microbenchmark.ReadQuarantine(buf)
+	require.NoError(t, err)
+	require.Len(t, quarantined, 4)
+	require.False(t, quarantined["pkg.BenchmarkStable"])
+	require.True(t, quarantined["pkg.BenchmarkDrift"])
+}
+
+func TestDetectFlakyZeroValues(t *testing.T) {
+	results := flakyResults()
+	for i := range results {
+		// no allocations, except for the last suite of each run of
+		// BenchmarkStable (the first half of the trials has a zero median)
+		if results[i].Function.Name == "BenchmarkStable" && results[i].S > 2 {
+			results[i].Allocs = 1
+		}
+	}
+	config := DefaultFlakyConfig()
+	config.Analysis.Iterations = 100
+	config.Analysis.Metric = "allocs"
+	flaky, err := DetectFlaky([]microbenchmark.Results{results}, config)
+	require.NoError(t, err)
+	require.Len(t, flaky, 5)
+	for _, f := range flaky {
+		require.False(t, f.Flaky(), f.Function)
+		require.False(t, math.IsInf(f.Drift, 0), f.Function)
+		require.False(t, math.IsInf(f.RunSpread, 0), f.Function)
+	}
+}
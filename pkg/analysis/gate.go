@@ -0,0 +1,193 @@
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	GatePassed  = "passed"
+	GateFailed  = "failed"
+	GateSkipped = "skipped"
+)
+
+const (
+	CriterionCILower = "ci-lower" // significant regression beyond the threshold
+	CriterionRatio   = "ratio"    // median ratio beyond the threshold
+	CriterionCIUpper = "ci-upper" // possible regression beyond the threshold
+)
+
+func IsValidCriterion(criterion string) bool {
+	return criterion == CriterionCILower || criterion == CriterionRatio || criterion == CriterionCIUpper
+}
+
+// BenchmarkThreshold overrides the global maximum ratio for all functions
+// matching the pattern.
+type BenchmarkThreshold struct {
+	Pattern  *regexp.Regexp
+	MaxRatio float64
+}
+
+// ParseBenchmarkThreshold parses thresholds in the form <regexp>=<max ratio>,
+// e.g. "pkg.BenchmarkA=1.1" or "^service\..*$=1.02".
+func ParseBenchmarkThreshold(s string) (BenchmarkThreshold, error) {
+	idx := strings.LastIndex(s, "=")
+	if idx <= 0 {
+		return BenchmarkThreshold{}, fmt.Errorf("invalid benchmark threshold %s (expected <regexp>=<max ratio>)", s)
+	}
+	pattern, err := regexp.Compile(s[:idx])
+	if err != nil {
+		return BenchmarkThreshold{}, fmt.Errorf("invalid benchmark threshold pattern %s: %w", s[:idx], err)
+	}
+	maxRatio, err := strconv.ParseFloat(s[idx+1:], 64)
+	if err != nil {
+		return BenchmarkThreshold{}, fmt.Errorf("invalid benchmark threshold ratio %s: %w", s[idx+1:], err)
+	}
+	if !isValidMaxRatio(maxRatio) {
+		return BenchmarkThreshold{}, fmt.Errorf("invalid benchmark threshold ratio %s: must be positive", s[idx+1:])
+	}
+	return BenchmarkThreshold{Pattern: pattern, MaxRatio: maxRatio}, nil
+}
+
+func isValidMaxRatio(maxRatio float64) bool {
+	return maxRatio > 0 && !math.IsInf(maxRatio, 1)
+}
+
+// ReadBenchmarkThresholds reads a thresholds file with one threshold
+// (<regexp>=<max ratio>) per line. Empty lines and lines starting with # are
+// ignored.
+func ReadBenchmarkThresholds(r io.Reader) ([]BenchmarkThreshold, error) {
+	thresholds := make([]BenchmarkThreshold, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		threshold, err := ParseBenchmarkThreshold(line)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, scanner.Err()
+}
+
+type GateConfig struct {
+	Criterion string
+	// MaxRatio is the global threshold, e.g. 1.05 fails if the criterion exceeds a 5% slowdown
+	MaxRatio float64
+	// Thresholds are checked in order, the first matching threshold is used
+	Thresholds []BenchmarkThreshold
+}
+
+func (c GateConfig) Validate() error {
+	var confErr error
+	if !IsValidCriterion(c.Criterion) {
+		confErr = multierror.Append(confErr, fmt.Errorf("unsupported gate criterion: %s", c.Criterion))
+	}
+	if !isValidMaxRatio(c.MaxRatio) {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid max ratio %v: must be positive", c.MaxRatio))
+	}
+	for _, t := range c.Thresholds {
+		if !isValidMaxRatio(t.MaxRatio) {
+			confErr = multierror.Append(confErr, fmt.Errorf("invalid max ratio %v of benchmark threshold %s: must be positive", t.MaxRatio, t.Pattern))
+		}
+	}
+	return confErr
+}
+
+func (c GateConfig) maxRatio(function string) float64 {
+	for _, t := range c.Thresholds {
+		if t.Pattern.MatchString(function) {
+			return t.MaxRatio
+		}
+	}
+	return c.MaxRatio
+}
+
+// GateResult is the gate decision for a single benchmark function.
+type GateResult struct {
+	Result
+	MaxRatio float64
+	Value    float64 // value of the criterion
+	Status   string
+}
+
+func (c GateConfig) value(r Result) float64 {
+	switch c.Criterion {
+	case CriterionRatio:
+		return r.Ratio
+	case CriterionCIUpper:
+		return r.CIUpper
+	default:
+		return r.CILower
+	}
+}
+
+// Gate checks the analysis results against the thresholds. Functions without
+// samples in one version or without a ratio (zero in both versions) are
+// skipped. An infinite ratio (zero in v1 only) fails.
+func Gate(results []Result, config GateConfig) []GateResult {
+	gateResults := make([]GateResult, 0, len(results))
+	for _, r := range results {
+		gr := GateResult{
+			Result:   r,
+			MaxRatio: config.maxRatio(r.Function),
+			Value:    config.value(r),
+		}
+		switch {
+		case r.N1 == 0 || r.N2 == 0 || math.IsNaN(gr.Value):
+			gr.Status = GateSkipped
+		case gr.Value > gr.MaxRatio:
+			gr.Status = GateFailed
+		default:
+			gr.Status = GatePassed
+		}
+		gateResults = append(gateResults, gr)
+	}
+	return gateResults
+}
+
+// GateFailures returns the amount of failed functions.
+func GateFailures(results []GateResult) int {
+	failures := 0
+	for _, r := range results {
+		if r.Status == GateFailed {
+			failures++
+		}
+	}
+	return failures
+}
+
+func (r GateResult) Message(criterion string) string {
+	switch r.Status {
+	case GateSkipped:
+		return "no valid ratio (missing or zero results)"
+	case GateFailed:
+		return fmt.Sprintf("%s %s exceeds the maximum ratio %s (ratio %s, CI [%s, %s])", criterion,
+			formatFloat(r.Value), formatFloat(r.MaxRatio), formatFloat(r.Ratio), formatFloat(r.CILower), formatFloat(r.CIUpper))
+	default:
+		return fmt.Sprintf("%s %s within the maximum ratio %s", criterion, formatFloat(r.Value), formatFloat(r.MaxRatio))
+	}
+}
+
+var GateTableHeader = []string{"function", "ratio v2/v1", "ci lower", "ci upper", "max ratio", "classification", "status"}
+
+func GateTableRows(results []GateResult) [][]string {
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, []string{
+			r.Function, formatFloat(r.Ratio), formatFloat(r.CILower), formatFloat(r.CIUpper),
+			formatFloat(r.MaxRatio), r.Classification, r.Status,
+		})
+	}
+	return rows
+}
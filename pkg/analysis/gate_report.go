@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+// splitFunction splits "package.BenchmarkName" into package and name.
+func splitFunction(function string) (string, string) {
+	idx := strings.LastIndex(function, ".")
+	if idx < 0 {
+		return "", function
+	}
+	return function[:idx], function[idx+1:]
+}
+
+// WriteJUnit writes the gate results as JUnit XML report with one test case
+// per benchmark function.
+func WriteJUnit(w io.Writer, suiteName, criterion string, results []GateResult) error {
+	suite := junitTestSuite{
+		Name:      suiteName,
+		Tests:     len(results),
+		TestCases: make([]junitTestCase, 0, len(results)),
+	}
+	for _, r := range results {
+		pkg, name := splitFunction(r.Function)
+		tc := junitTestCase{
+			Name:      name,
+			ClassName: pkg,
+		}
+		message := r.Message(criterion)
+		switch r.Status {
+		case GateFailed:
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: message,
+				Type:    "PerformanceRegression",
+				Text:    fmt.Sprintf("n v1: %d, n v2: %d, median v1: %s, median v2: %s", r.N1, r.N2, formatFloat(r.MedianV1), formatFloat(r.MedianV2)),
+			}
+		case GateSkipped:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{Message: message}
+		default:
+			tc.SystemOut = message
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{TestSuites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+var markdownStatus = map[string]string{
+	GatePassed:  ":white_check_mark: passed",
+	GateFailed:  ":x: failed",
+	GateSkipped: ":grey_question: skipped",
+}
+
+// WriteMarkdown writes a summary of the gate results that can be posted as
+// pull request comment. Failed functions are listed first.
+func WriteMarkdown(w io.Writer, criterion string, results []GateResult) error {
+	failures := GateFailures(results)
+	var b strings.Builder
+	b.WriteString("## Benchmark gate\n\n")
+	if failures == 0 {
+		fmt.Fprintf(&b, ":white_check_mark: All %d benchmarks passed", len(results))
+	} else {
+		fmt.Fprintf(&b, ":x: %d of %d benchmarks failed", failures, len(results))
+	}
+	fmt.Fprintf(&b, " (criterion: `%s`).\n\n", criterion)
+	b.WriteString("| Benchmark | Ratio v2/v1 | CI | Max ratio | Classification | Status |\n")
+	b.WriteString("|---|---:|---|---:|---|---|\n")
+	for _, status := range []string{GateFailed, GatePassed, GateSkipped} {
+		for _, r := range results {
+			if r.Status != status {
+				continue
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | [%s, %s] | %s | %s | %s |\n",
+				r.Function, formatFloat(r.Ratio), formatFloat(r.CILower), formatFloat(r.CIUpper),
+				formatFloat(r.MaxRatio), r.Classification, markdownStatus[r.Status])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGate(t *testing.T) {
+	threshold, err := ParseBenchmarkThreshold(`^pkg\.BenchmarkB$=1.2`)
+	require.NoError(t, err)
+	_, err = ParseBenchmarkThreshold("pkg.BenchmarkB")
+	require.Error(t, err)
+	_, err = ParseBenchmarkThreshold("pkg.BenchmarkB=0")
+	require.Error(t, err)
+	_, err = ParseBenchmarkThreshold("pkg.BenchmarkB=-1.1")
+	require.Error(t, err)
+	require.Error(t, GateConfig{Criterion: CriterionCILower, MaxRatio: 0}.Validate())
+	require.Error(t, GateConfig{Criterion: CriterionCILower, MaxRatio: 1.05, Thresholds: []BenchmarkThreshold{{Pattern: threshold.Pattern, MaxRatio: -1}}}.Validate())
+
+	results := []Result{
+		{Function: "pkg.BenchmarkA", N1: 10, N2: 10, Ratio: 1.1, CILower: 1.06, CIUpper: 1.2},
+		{Function: "pkg.BenchmarkB", N1: 10, N2: 10, Ratio: 1.1, CILower: 1.06, CIUpper: 1.2},
+		{Function: "pkg.BenchmarkC", N1: 10, N2: 10, Ratio: 1.03, CILower: 0.99, CIUpper: 1.08},
+		{Function: "pkg.BenchmarkD", N1: 10, N2: 10, Ratio: math.NaN(), CILower: math.NaN(), CIUpper: math.NaN()},
+	}
+	gateResults := Gate(results, GateConfig{
+		Criterion:  CriterionCILower,
+		MaxRatio:   1.05,
+		Thresholds: []BenchmarkThreshold{threshold},
+	})
+	statuses := make([]string, 0, len(gateResults))
+	for _, r := range gateResults {
+		statuses = append(statuses, r.Status)
+	}
+	require.Equal(t, []string{GateFailed, GatePassed, GatePassed, GateSkipped}, statuses)
+	require.Equal(t, 1, GateFailures(gateResults))
+
+	// the upper bound also fails possible regressions
+	require.Equal(t, 3, GateFailures(Gate(results, GateConfig{Criterion: CriterionCIUpper, MaxRatio: 1.05})))
+
+	junit := &bytes.Buffer{}
+	require.NoError(t, WriteJUnit(junit, "gate", CriterionCILower, gateResults))
+	require.Contains(t, junit.String(), `<testsuite name="gate" tests="4" failures="1" skipped="1">`)
+	require.Contains(t, junit.String(), `<testcase name="BenchmarkA" classname="pkg">`)
+
+	markdown := &bytes.Buffer{}
+	require.NoError(t, WriteMarkdown(markdown, CriterionCILower, gateResults))
+	require.Contains(t, markdown.String(), "1 of 4 benchmarks failed")
+}
+
+func TestGateZeroAllocations(t *testing.T) {
+	results := make(microbenchmark.Results, 0)
+	for _, name := range []string{"BenchmarkNew", "BenchmarkNone", "BenchmarkRemoved", "BenchmarkMissing"} {
+		fn := microbenchmark.Function{PackageName: "pkg", Name: name}
+		for i := 0; i < 10; i++ {
+			results = append(results, microbenchmark.Result{Function: fn, Version: 1, Ops: 1, Allocs: map[string]float64{"BenchmarkRemoved": 3}[name]})
+			if name != "BenchmarkMissing" {
+				results = append(results, microbenchmark.Result{Function: fn, Version: 2, Ops: 1, Allocs: map[string]float64{"BenchmarkNew": 3}[name]})
+			}
+		}
+	}
+	config := DefaultConfig()
+	config.Metric = "allocs"
+	config.Iterations = 100
+	analysisResults, err := Analyze(results, config)
+	require.NoError(t, err)
+
+	statuses := make(map[string]string)
+	for _, r := range Gate(analysisResults, GateConfig{Criterion: CriterionCILower, MaxRatio: 1.05}) {
+		statuses[r.Function] = r.Status
+	}
+	require.Equal(t, map[string]string{
+		// 0 -> 3 allocs/op has an infinite ratio
+		"pkg.BenchmarkNew": GateFailed,
+		// 0 -> 0 allocs/op has no ratio
+		"pkg.BenchmarkNone":    GateSkipped,
+		"pkg.BenchmarkRemoved": GatePassed,
+		"pkg.BenchmarkMissing": GateSkipped,
+	}, statuses)
+}
@@ -0,0 +1,43 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application/latency"
+)
+
+// AnalyzeLatencies computes the median ratio of the v2/v1 latencies of each
+// endpoint (the Function of the results) together with its percentile
+// bootstrap confidence interval. The samples should be preprocessed (see
+// latency.Preprocess) to only compare the window in which both versions were
+// under load. The metric of the config is ignored.
+func AnalyzeLatencies(latencies latency.Samples, v1, v2 string, config Config) ([]Result, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	grouped := make(map[string]*samples)
+	for _, sample := range latencies {
+		if sample.Version != v1 && sample.Version != v2 {
+			continue
+		}
+		if grouped[sample.Endpoint] == nil {
+			grouped[sample.Endpoint] = &samples{}
+		}
+		if sample.Version == v1 {
+			grouped[sample.Endpoint].v1 = append(grouped[sample.Endpoint].v1, sample.Latency)
+		} else {
+			grouped[sample.Endpoint].v2 = append(grouped[sample.Endpoint].v2, sample.Latency)
+		}
+	}
+	if len(grouped) == 0 {
+		return nil, fmt.Errorf("no latency samples of the versions %s and %s found", v1, v2)
+	}
+	// Endpoints returns the endpoints sorted, hence the results are reproducible
+	endpoints := make([]string, 0, len(grouped))
+	for _, endpoint := range latencies.Endpoints() {
+		if grouped[endpoint] != nil {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return compareAll(endpoints, grouped, config), nil
+}
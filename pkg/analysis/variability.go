@@ -0,0 +1,288 @@
+package analysis
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/stats"
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	GroupByFunction = "function" // per function and version
+	GroupByRun      = "run"      // per function, version and run index
+	GroupBySuite    = "suite"    // per function, version, run index and suite run
+)
+
+var groupByLevels = []string{GroupByFunction, GroupByRun, GroupBySuite}
+
+type VariabilityConfig struct {
+	GroupBy         string
+	Metric          string
+	Iterations      int     // bootstrap iterations of the RCIW
+	ConfidenceLevel float64 // confidence level of the RCIW in percent
+	Seed            int64
+	// limits above which a benchmark is flagged (0 disables the limit)
+	MaxCV, MaxRMAD, MaxRCIW float64
+}
+
+func DefaultVariabilityConfig() VariabilityConfig {
+	return VariabilityConfig{
+		GroupBy:         GroupByFunction,
+		Metric:          "ops",
+		Iterations:      10000,
+		ConfidenceLevel: 99,
+		Seed:            42,
+		MaxCV:           0.1,
+		MaxRMAD:         0.1,
+		MaxRCIW:         0.1,
+	}
+}
+
+func (c VariabilityConfig) Validate() error {
+	var confErr error
+	validGroupBy := false
+	for _, level := range groupByLevels {
+		validGroupBy = validGroupBy || c.GroupBy == level
+	}
+	if !validGroupBy {
+		confErr = multierror.Append(confErr, fmt.Errorf("unsupported grouping %s (supported: %s)", c.GroupBy, strings.Join(groupByLevels, ", ")))
+	}
+	if !IsValidMetric(c.Metric) {
+		confErr = multierror.Append(confErr, fmt.Errorf("unsupported metric: %s", c.Metric))
+	}
+	if c.Iterations <= 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("bootstrap iterations must be positive"))
+	}
+	if c.ConfidenceLevel <= 0 || c.ConfidenceLevel >= 100 {
+		confErr = multierror.Append(confErr, fmt.Errorf("confidence level must be between 0 and 100"))
+	}
+	if c.MaxCV < 0 || c.MaxRMAD < 0 || c.MaxRCIW < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("variability limits must not be negative"))
+	}
+	return confErr
+}
+
+// Variability describes the variability of a group of results. Run and Suite
+// are 0 if the results are aggregated over all runs or suites.
+type Variability struct {
+	Function string
+	Version  int
+	Run      int
+	Suite    int
+	N        int
+	Median   float64
+	CV       float64
+	RMAD     float64
+	RCIW     float64
+	Exceeded []string // names of the exceeded limits
+}
+
+func (v Variability) Flagged() bool {
+	return len(v.Exceeded) != 0
+}
+
+type variabilityKey struct {
+	Function string
+	Version  int
+	Run      int
+	Suite    int
+}
+
+func (c VariabilityConfig) key(r microbenchmark.Result) variabilityKey {
+	key := variabilityKey{Function: r.Function.String(), Version: r.Version}
+	if c.GroupBy == GroupByRun || c.GroupBy == GroupBySuite {
+		key.Run = r.R
+	}
+	if c.GroupBy == GroupBySuite {
+		key.Suite = r.S
+	}
+	return key
+}
+
+// exceeded returns the names of the limits exceeded by v. NaN values (e.g. of
+// a single sample) always exceed an enabled limit.
+func (c VariabilityConfig) exceeded(v Variability) []string {
+	var exceeded []string
+	if c.MaxCV > 0 && !(v.CV <= c.MaxCV) {
+		exceeded = append(exceeded, "cv")
+	}
+	if c.MaxRMAD > 0 && !(v.RMAD <= c.MaxRMAD) {
+		exceeded = append(exceeded, "rmad")
+	}
+	if c.MaxRCIW > 0 && !(v.RCIW <= c.MaxRCIW) {
+		exceeded = append(exceeded, "rciw")
+	}
+	return exceeded
+}
+
+// ComputeVariability computes CV, RMAD and RCIW for every group of results.
+func ComputeVariability(results microbenchmark.Results, config VariabilityConfig) ([]Variability, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	metric := metrics[config.Metric]
+	grouped := make(map[variabilityKey][]float64)
+	for _, r := range results {
+		key := config.key(r)
+		grouped[key] = append(grouped[key], metric(r))
+	}
+	keys := make([]variabilityKey, 0, len(grouped))
+	for key := range grouped {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Function != b.Function {
+			return a.Function < b.Function
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		if a.Run != b.Run {
+			return a.Run < b.Run
+		}
+		return a.Suite < b.Suite
+	})
+
+	rng := rand.New(rand.NewSource(config.Seed))
+	variabilities := make([]Variability, 0, len(keys))
+	for _, key := range keys {
+		data := grouped[key]
+		v := Variability{
+			Function: key.Function,
+			Version:  key.Version,
+			Run:      key.Run,
+			Suite:    key.Suite,
+			N:        len(data),
+			Median:   stats.Median(data),
+			CV:       stats.CV(data),
+			RMAD:     stats.RMAD(data),
+			RCIW:     stats.RCIW(rng, data, config.Iterations, config.ConfidenceLevel),
+		}
+		v.Exceeded = config.exceeded(v)
+		variabilities = append(variabilities, v)
+	}
+	return variabilities, nil
+}
+
+// TrustedFunctions splits the functions into the ones that never exceeded a
+// variability limit and the ones that did.
+func TrustedFunctions(variabilities []Variability) (trusted, flagged []string) {
+	isFlagged := make(map[string]bool)
+	for _, v := range variabilities {
+		isFlagged[v.Function] = isFlagged[v.Function] || v.Flagged()
+	}
+	for fn, f := range isFlagged {
+		if f {
+			flagged = append(flagged, fn)
+		} else {
+			trusted = append(trusted, fn)
+		}
+	}
+	sort.Strings(trusted)
+	sort.Strings(flagged)
+	return trusted, flagged
+}
+
+// InstanceVariability summarizes the variability of all functions that were
+// executed on the same instance (run index).
+type InstanceVariability struct {
+	Run        int
+	Functions  int
+	Flagged    int
+	MedianCV   float64
+	MedianRMAD float64
+	MedianRCIW float64
+	// RelativeCV is the median CV of the instance divided by the median CV of
+	// all instances. It is 0 if the median CV of all instances is 0.
+	RelativeCV float64
+}
+
+// InstanceVariabilities aggregates run level variabilities per instance to
+// detect noisy instances.
+func InstanceVariabilities(variabilities []Variability) []InstanceVariability {
+	type instanceData struct {
+		cv, rmad, rciw []float64
+		flagged        int
+	}
+	instances := make(map[int]*instanceData)
+	for _, v := range variabilities {
+		if instances[v.Run] == nil {
+			instances[v.Run] = &instanceData{}
+		}
+		data := instances[v.Run]
+		data.cv = append(data.cv, v.CV)
+		data.rmad = append(data.rmad, v.RMAD)
+		data.rciw = append(data.rciw, v.RCIW)
+		if v.Flagged() {
+			data.flagged++
+		}
+	}
+	runs := make([]int, 0, len(instances))
+	for run := range instances {
+		runs = append(runs, run)
+	}
+	sort.Ints(runs)
+	res := make([]InstanceVariability, 0, len(runs))
+	medianCVs := make([]float64, 0, len(runs))
+	for _, run := range runs {
+		data := instances[run]
+		iv := InstanceVariability{
+			Run:        run,
+			Functions:  len(data.cv),
+			Flagged:    data.flagged,
+			MedianCV:   stats.Median(data.cv),
+			MedianRMAD: stats.Median(data.rmad),
+			MedianRCIW: stats.Median(data.rciw),
+		}
+		medianCVs = append(medianCVs, iv.MedianCV)
+		res = append(res, iv)
+	}
+	overallCV := stats.Median(medianCVs)
+	if overallCV == 0 {
+		return res
+	}
+	for i := range res {
+		res[i].RelativeCV = res[i].MedianCV / overallCV
+	}
+	return res
+}
+
+var VariabilityTableHeader = []string{"function", "version", "run", "suite", "n", "median", "cv", "rmad", "rciw", "flagged"}
+
+func formatIndex(i int) string {
+	if i == 0 {
+		return "all"
+	}
+	return strconv.Itoa(i)
+}
+
+func VariabilityTableRows(variabilities []Variability) [][]string {
+	rows := make([][]string, 0, len(variabilities))
+	for _, v := range variabilities {
+		rows = append(rows, []string{
+			v.Function, strconv.Itoa(v.Version), formatIndex(v.Run), formatIndex(v.Suite), strconv.Itoa(v.N),
+			formatFloat(v.Median), formatFloat(v.CV), formatFloat(v.RMAD), formatFloat(v.RCIW),
+			strings.Join(v.Exceeded, ","),
+		})
+	}
+	return rows
+}
+
+var InstanceTableHeader = []string{"run", "functions", "flagged", "median cv", "median rmad", "median rciw", "relative cv"}
+
+func InstanceTableRows(instances []InstanceVariability) [][]string {
+	rows := make([][]string, 0, len(instances))
+	for _, iv := range instances {
+		rows = append(rows, []string{
+			strconv.Itoa(iv.Run), strconv.Itoa(iv.Functions), strconv.Itoa(iv.Flagged),
+			formatFloat(iv.MedianCV), formatFloat(iv.MedianRMAD), formatFloat(iv.MedianRCIW), formatFloat(iv.RelativeCV),
+		})
+	}
+	return rows
+}
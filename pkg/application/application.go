@@ -7,8 +7,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 	"syscall"
+	"time"
 
 	"github.com/christophwitzko/masters-thesis/pkg/logger"
 	"github.com/christophwitzko/masters-thesis/pkg/merror"
@@ -16,68 +16,211 @@ import (
 
 type PidCallbackFunc func(pid int) error
 
-func Build(ctx context.Context, log *logger.Logger, buildPath, buildPackage, outputFile string) error {
-	if !strings.HasPrefix(buildPackage, "./") {
-		return fmt.Errorf("build package must be a relative path")
+// Build renders and runs the build steps one after another in the source path
+// of the version.
+func Build(ctx context.Context, log *logger.Logger, steps []Command, data TemplateData) error {
+	if data.ExecFile != "" {
+		if err := os.MkdirAll(filepath.Dir(data.ExecFile), 0o755); err != nil {
+			return err
+		}
 	}
-	args := []string{
-		"build",
-		"-o", outputFile,
-		buildPackage,
+	for _, step := range steps {
+		rendered, err := step.Render(data)
+		if err != nil {
+			return err
+		}
+		cmd := exec.CommandContext(ctx, rendered.Command, rendered.Args...)
+		cmd.Dir = rendered.Dir
+		cmd.Env = append(os.Environ(), rendered.Env...)
+		log.Infof("running in %s: %s", rendered.Dir, rendered)
+		if err := runWithPrefixedOutput(log, fmt.Sprintf("building %s", data.Name), cmd); err != nil {
+			return fmt.Errorf("build step %s failed: %w", rendered, err)
+		}
 	}
-	cmd := exec.CommandContext(ctx, "go", args...)
-	cmd.Dir = buildPath
-	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+	return nil
+}
+
+func runWithPrefixedOutput(log *logger.Logger, prefix string, cmd *exec.Cmd) error {
 	logPipeRead, logPipeWrite := io.Pipe()
 	cmd.Stdout = logPipeWrite
 	cmd.Stderr = logPipeWrite
 	defer logPipeWrite.Close()
-	go log.PrefixedReader(fmt.Sprintf("building %s", buildPath), logPipeRead)
-	log.Infof("running in %s: go %s", buildPath, strings.Join(args, " "))
+	go log.PrefixedReader(prefix, logPipeRead)
 	return cmd.Run()
 }
 
-func Run(ctx context.Context, log *logger.Logger, execFile string, env []string, pidCallback PidCallbackFunc) error {
-	cmd := exec.Command(execFile)
-	cmd.Dir = filepath.Dir(execFile)
-	cmd.Env = append(os.Environ(), env...)
+// RunConfig configures how the run command of a version is stopped and where
+// its output is captured.
+type RunConfig struct {
+	// GracePeriod is the time the process has to exit after SIGTERM before it
+	// is killed. Without grace period the process is killed immediately.
+	GracePeriod time.Duration
+	// OutputDir captures the full stdout and stderr of the version to
+	// <name>.stdout.log and <name>.stderr.log if set.
+	OutputDir string
+	// AppendOutput appends to the capture files instead of truncating them,
+	// e.g. for restarted versions.
+	AppendOutput bool
+	// Logs additionally receives stdout and stderr of the version if set.
+	Logs io.Writer
+}
+
+// ExitStatus describes how the process of a version exited.
+type ExitStatus struct {
+	Name string `json:"name"`
+	// ExitCode is -1 if the process was terminated by a signal.
+	ExitCode int    `json:"exitCode"`
+	Signal   string `json:"signal,omitempty"`
+	// Stopped reports whether the process was stopped by the runner.
+	Stopped bool      `json:"stopped"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+func (s *ExitStatus) String() string {
+	status := fmt.Sprintf("exit code %d", s.ExitCode)
+	if s.Signal != "" {
+		status = fmt.Sprintf("signal %s", s.Signal)
+	}
+	if s.Stopped {
+		status += " (stopped)"
+	}
+	return status
+}
+
+func newExitStatus(name string, state *os.ProcessState, stopped bool, err error) *ExitStatus {
+	status := &ExitStatus{Name: name, ExitCode: -1, Stopped: stopped, Time: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if state == nil {
+		return status
+	}
+	status.ExitCode = state.ExitCode()
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		status.Signal = ws.Signal().String()
+	}
+	return status
+}
+
+// OutputFiles returns the files the output of the version is captured to.
+func (c RunConfig) OutputFiles(name string) (stdoutFile, stderrFile string) {
+	return filepath.Join(c.OutputDir, name+".stdout.log"), filepath.Join(c.OutputDir, name+".stderr.log")
+}
+
+// Run renders and starts the run command of the version with the additional
+// environment variables. The process is stopped once the context is done.
+func Run(ctx context.Context, log *logger.Logger, command Command, data TemplateData, env []string, pidCallback PidCallbackFunc, runConf RunConfig) (*ExitStatus, error) {
+	rendered, err := command.Render(data)
+	if err != nil {
+		return nil, err
+	}
+	return runProcess(ctx, log, data.Name, rendered, env, pidCallback, runConf)
+}
+
+// openOutputFiles opens the capture files of the process if an output
+// directory is configured.
+func openOutputFiles(name string, runConf RunConfig) ([]*os.File, error) {
+	if runConf.OutputDir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(runConf.OutputDir, 0o755); err != nil {
+		return nil, err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if runConf.AppendOutput {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	stdoutFile, stderrFile := runConf.OutputFiles(name)
+	stdout, err := os.OpenFile(stdoutFile, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := os.OpenFile(stderrFile, flags, 0o644)
+	if err != nil {
+		_ = stdout.Close()
+		return nil, err
+	}
+	return []*os.File{stdout, stderr}, nil
+}
+
+// runProcess starts the rendered command and stops it once the context is
+// done. The output is logged with the name as prefix.
+func runProcess(ctx context.Context, log *logger.Logger, name string, rendered Command, env []string, pidCallback PidCallbackFunc, runConf RunConfig) (*ExitStatus, error) {
+	cmd := exec.Command(rendered.Command, rendered.Args...)
+	cmd.Dir = rendered.Dir
+	cmd.Env = append(append(os.Environ(), rendered.Env...), env...)
+	outputFiles, err := openOutputFiles(name, runConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture output of %s: %w", name, err)
+	}
 	logPipeRead, logPipeWrite := io.Pipe()
-	cmd.Stdout = logPipeWrite
-	cmd.Stderr = logPipeWrite
+	var logs io.Writer = logPipeWrite
+	if runConf.Logs != nil {
+		logs = io.MultiWriter(logPipeWrite, runConf.Logs)
+	}
+	cmd.Stdout = logs
+	cmd.Stderr = logs
+	if outputFiles != nil {
+		cmd.Stdout = io.MultiWriter(logs, outputFiles[0])
+		cmd.Stderr = io.MultiWriter(logs, outputFiles[1])
+	}
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		// prevent parent sending signals to child processes
 		Setpgid: true,
 	}
-	defer logPipeWrite.Close()
+	defer func() {
+		_ = logPipeWrite.Close()
+		for _, f := range outputFiles {
+			_ = f.Close()
+		}
+	}()
 
-	go log.PrefixedReader(fmt.Sprintf("|%s|", filepath.Base(execFile)), logPipeRead)
-	log.Infof("running %s with env=%v", execFile, env)
+	go log.PrefixedReader(fmt.Sprintf("|%s|", name), logPipeRead)
+	log.Infof("running %s with env=%v", rendered, env)
+	if err := cmd.Start(); err != nil {
+		return newExitStatus(name, nil, false, err), err
+	}
 	errCh := make(chan error, 1)
 	go func() {
-		defer close(errCh)
-		if err := cmd.Start(); err != nil {
-			errCh <- err
-			return
-		}
-		if pidCallback != nil {
-			if err := pidCallback(cmd.Process.Pid); err != nil {
-				errCh <- fmt.Errorf("failed to call pid callback: %w", err)
-				return
-			}
-		}
-		if err := cmd.Wait(); err != nil {
-			errCh <- err
-			return
-		}
+		errCh <- cmd.Wait()
 	}()
+	if pidCallback != nil {
+		if err := pidCallback(cmd.Process.Pid); err != nil {
+			err = fmt.Errorf("failed to call pid callback: %w", err)
+			waitErr := stopProcess(log, name, cmd, 0, errCh)
+			return newExitStatus(name, cmd.ProcessState, true, err), merror.MaybeMultiError(err, waitErr)
+		}
+	}
 
 	select {
 	case <-ctx.Done():
-		log.Warnf("killing %s", execFile)
-		killErr := cmd.Process.Signal(syscall.SIGKILL)
-		waitErr := <-errCh // should be a signal: killed error
-		return merror.MaybeMultiError(ctx.Err(), killErr, waitErr)
+		waitErr := stopProcess(log, name, cmd, runConf.GracePeriod, errCh)
+		return newExitStatus(name, cmd.ProcessState, true, waitErr), merror.MaybeMultiError(ctx.Err(), waitErr)
 	case err := <-errCh:
-		return err
+		return newExitStatus(name, cmd.ProcessState, false, err), err
+	}
+}
+
+// stopProcess sends SIGTERM to the process group and SIGKILL once the grace
+// period is over. It returns the error of the process.
+func stopProcess(log *logger.Logger, name string, cmd *exec.Cmd, gracePeriod time.Duration, errCh <-chan error) error {
+	if gracePeriod > 0 {
+		log.Warnf("stopping %s (grace period %s)", name, gracePeriod)
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil {
+			log.Warnf("failed to send SIGTERM to %s: %v", name, err)
+		}
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+		select {
+		case err := <-errCh:
+			return err
+		case <-timer.C:
+			log.Warnf("%s did not stop within %s", name, gracePeriod)
+		}
 	}
+	log.Warnf("killing %s", name)
+	killErr := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	waitErr := <-errCh // should be a signal: killed error
+	return merror.MaybeMultiError(waitErr, killErr)
 }
@@ -0,0 +1,47 @@
+package application
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunGracePeriod(t *testing.T) {
+	log := logger.New()
+	runConf := RunConfig{GracePeriod: 5 * time.Second, OutputDir: t.TempDir()}
+	script := `trap 'echo flushed; exit 0' TERM; echo started; echo oops >&2; while true; do sleep 0.1; done`
+	data := TemplateData{Version: Version{Name: "v1"}, SourcePath: t.TempDir()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Second, cancel)
+	status, err := Run(ctx, log, Command{Command: "sh", Args: []string{"-c", script}}, data, nil, nil, runConf)
+	require.ErrorIs(t, err, context.Canceled)
+	require.True(t, status.Stopped)
+	require.Equal(t, 0, status.ExitCode)
+
+	stdoutFile, stderrFile := runConf.OutputFiles("v1")
+	stdout, err := os.ReadFile(stdoutFile)
+	require.NoError(t, err)
+	require.Equal(t, "started\nflushed\n", string(stdout))
+	stderr, err := os.ReadFile(stderrFile)
+	require.NoError(t, err)
+	require.Contains(t, string(stderr), "oops\n")
+
+	// the process ignores SIGTERM and is killed after the grace period
+	runConf.GracePeriod = 500 * time.Millisecond
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(time.Second, cancel)
+	status, err = Run(ctx, log, Command{Command: "sh", Args: []string{"-c", `trap '' TERM; while true; do sleep 0.1; done`}}, data, nil, nil, runConf)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, "killed", status.Signal)
+	require.Equal(t, -1, status.ExitCode)
+
+	status, err = Run(context.Background(), log, Command{Command: "sh", Args: []string{"-c", "exit 3"}}, data, nil, nil, runConf)
+	require.Error(t, err)
+	require.False(t, status.Stopped)
+	require.Equal(t, 3, status.ExitCode)
+}
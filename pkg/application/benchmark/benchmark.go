@@ -1,6 +1,7 @@
 package benchmark
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -22,6 +23,8 @@ type Config struct {
 	outputURLHost string
 	outputURLPath string
 	Env           []string
+	// OutputObserver is optionally called for each output line of the benchmark tool
+	OutputObserver func(targetName, line string)
 }
 
 func (c *Config) Validate() error {
@@ -36,6 +39,11 @@ func (c *Config) Validate() error {
 	return err
 }
 
+// HasOutput reports whether a results output is configured.
+func (c *Config) HasOutput() bool {
+	return c.outputURLHost != ""
+}
+
 func (c *Config) GetOutputObjectName(fileName string) string {
 	return fmt.Sprintf("gs://%s%s", c.outputURLHost, filepath.Join(c.outputURLPath, fileName))
 }
@@ -85,6 +93,15 @@ func Run(ctx context.Context, log *logger.Logger, config *Config, targetInfo *Ta
 	return runWithArgs(ctx, log, config, targetInfo, args)
 }
 
+func readOutput(log *logger.Logger, logPrefix string, reader io.Reader, observer func(line string)) {
+	lineScanner := bufio.NewScanner(reader)
+	for lineScanner.Scan() {
+		line := lineScanner.Text()
+		log.Infof("%s %s", logPrefix, line)
+		observer(line)
+	}
+}
+
 func runWithArgs(ctx context.Context, log *logger.Logger, config *Config, targetInfo *TargetInfo, args []string) error {
 	log.Infof("[%s] running: %s %s", targetInfo.Name, config.Tool, strings.Join(args, " "))
 	cmd := exec.CommandContext(ctx, config.Tool, args...)
@@ -94,14 +111,18 @@ func runWithArgs(ctx context.Context, log *logger.Logger, config *Config, target
 	cmd.Stderr = logPipeWrite
 	defer logPipeWrite.Close()
 	logPrefix := fmt.Sprintf("[%s]", targetInfo.Name)
-	go log.PrefixedReader(logPrefix, logPipeRead)
+	go readOutput(log, logPrefix, logPipeRead, func(line string) {
+		if config.OutputObserver != nil {
+			config.OutputObserver(targetInfo.Name, line)
+		}
+	})
 	err := cmd.Run()
 	if err != nil {
 		return fmt.Errorf("%s run failed: %w", config.Tool, err)
 	}
 
 	log.Infof("[%s] %s run finished", targetInfo.Name, config.Tool)
-	if config.outputURLHost == "" {
+	if !config.HasOutput() {
 		log.Warnf("[%s] no results output configured, skipping upload", targetInfo.Name)
 		return nil
 	}
@@ -0,0 +1,182 @@
+package application
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+// Command is a command that is used to build or run a version of the
+// application. All fields are templates that are executed with the
+// TemplateData of the version, e.g. {{.ExecFile}}, {{.Port}} or {{.Name}}.
+type Command struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args,omitempty"`
+	Env     []string `yaml:"env,omitempty"`
+	// Dir is the working directory relative to the source path of the version.
+	Dir string `yaml:"dir,omitempty"`
+}
+
+func (c Command) String() string {
+	return strings.TrimSpace(c.Command + " " + strings.Join(c.Args, " "))
+}
+
+// BinDirectory is the directory in the source path of a version to which its
+// binary is built. It is ignored by the go tool and cannot collide with a
+// directory or file of the application named like the version.
+const BinDirectory = ".cbc-bin"
+
+// ExecFilePath returns the path of the binary of the version.
+func ExecFilePath(sourcePath, name string) string {
+	return filepath.Join(sourcePath, BinDirectory, name)
+}
+
+// TemplateData is available in the build and run command templates.
+type TemplateData struct {
+	Version
+	// SourcePath is the directory containing the source of the version.
+	SourcePath string
+	// ExecFile is the path of the binary built by the default build command.
+	ExecFile string
+	// Package is the package that is built by the default build command.
+	Package string
+	// Sidecars are the sidecars of the version by name, e.g.
+	// {{.Sidecars.postgres.Endpoint}}.
+	Sidecars map[string]SidecarInstance
+}
+
+func executeTemplate(tmplStr string, data any) (string, error) {
+	tmpl, err := template.New("command").Parse(tmplStr)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func executeTemplates(tmplStrs []string, data any) ([]string, error) {
+	res := make([]string, len(tmplStrs))
+	for i, tmplStr := range tmplStrs {
+		s, err := executeTemplate(tmplStr, data)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = s
+	}
+	return res, nil
+}
+
+// Render executes the templates of the command with the given data. The
+// working directory of the rendered command is an absolute path.
+func (c Command) Render(data TemplateData) (Command, error) {
+	return c.render(data, data.SourcePath)
+}
+
+// render executes the templates with data and resolves the working directory
+// relative to baseDir.
+func (c Command) render(data any, baseDir string) (Command, error) {
+	var err error
+	rendered := Command{}
+	if rendered.Command, err = executeTemplate(c.Command, data); err != nil {
+		return Command{}, fmt.Errorf("invalid command template %q: %w", c.Command, err)
+	}
+	if rendered.Args, err = executeTemplates(c.Args, data); err != nil {
+		return Command{}, fmt.Errorf("invalid args template of %s: %w", c.Command, err)
+	}
+	if rendered.Env, err = executeTemplates(c.Env, data); err != nil {
+		return Command{}, fmt.Errorf("invalid env template of %s: %w", c.Command, err)
+	}
+	dir, err := executeTemplate(c.Dir, data)
+	if err != nil {
+		return Command{}, fmt.Errorf("invalid dir template of %s: %w", c.Command, err)
+	}
+	rendered.Dir = filepath.Join(baseDir, dir)
+	return rendered, nil
+}
+
+// Commands configures how the versions of the application are built and run.
+// Empty fields fall back to the default go build and the built binary.
+type Commands struct {
+	Build    []Command `yaml:"build,omitempty"`
+	Run      *Command  `yaml:"run,omitempty"`
+	Sidecars []Sidecar `yaml:"sidecars,omitempty"`
+}
+
+// DefaultBuildCommand builds the package with go build and CGO disabled.
+var DefaultBuildCommand = Command{
+	Command: "go",
+	Args:    []string{"build", "-o", "{{.ExecFile}}", "{{.Package}}"},
+	Env:     []string{"CGO_ENABLED=0"},
+}
+
+// DefaultRunCommand runs the binary built by the default build command.
+var DefaultRunCommand = Command{
+	Command: "{{.ExecFile}}",
+}
+
+// BuildCommands returns the configured build steps or the default build.
+func (c *Commands) BuildCommands() []Command {
+	if len(c.Build) == 0 {
+		return []Command{DefaultBuildCommand}
+	}
+	return c.Build
+}
+
+// RunCommand returns the configured run command or the default one.
+func (c *Commands) RunCommand() Command {
+	if c.Run == nil {
+		return DefaultRunCommand
+	}
+	return *c.Run
+}
+
+// Validate checks the commands. The package is only required if the default
+// build is used.
+func (c *Commands) Validate(buildPackage string) error {
+	var err error
+	if len(c.Build) == 0 && !strings.HasPrefix(buildPackage, "./") {
+		err = multierror.Append(err, fmt.Errorf("build package must be a relative path"))
+	}
+	for i, step := range c.Build {
+		if step.Command == "" {
+			err = multierror.Append(err, fmt.Errorf("build step %d has no command", i+1))
+		}
+	}
+	if c.Run != nil && c.Run.Command == "" {
+		err = multierror.Append(err, fmt.Errorf("run command is empty"))
+	}
+	seen := make(map[string]bool)
+	for _, sidecar := range c.Sidecars {
+		if sidecarErr := sidecar.Validate(); sidecarErr != nil {
+			err = multierror.Append(err, sidecarErr)
+		}
+		if seen[sidecar.Name] {
+			err = multierror.Append(err, fmt.Errorf("duplicate sidecar name %q", sidecar.Name))
+		}
+		seen[sidecar.Name] = true
+	}
+	return err
+}
+
+// ReadCommands reads the commands from YAML.
+func ReadCommands(r io.Reader) (*Commands, error) {
+	c := &Commands{}
+	if err := yaml.NewDecoder(r).Decode(c); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read application commands: %w", err)
+	}
+	return c, nil
+}
+
+// Write writes the commands as YAML.
+func (c *Commands) Write(w io.Writer) error {
+	return yaml.NewEncoder(w).Encode(c)
+}
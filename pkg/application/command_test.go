@@ -0,0 +1,62 @@
+package application
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommands(t *testing.T) {
+	data := TemplateData{
+		Version:    Version{Name: "v2", Source: "main", Host: "0.0.0.0", Port: 3001},
+		SourcePath: "/tmp/.application/v2",
+		ExecFile:   ExecFilePath("/tmp/.application/v2", "v2"),
+		Package:    "./cmd/service",
+	}
+
+	defaults := &Commands{}
+	require.NoError(t, defaults.Validate("./cmd/service"))
+	require.Error(t, defaults.Validate("cmd/service"))
+	build, err := defaults.BuildCommands()[0].Render(data)
+	require.NoError(t, err)
+	require.Equal(t, Command{
+		Command: "go",
+		Args:    []string{"build", "-o", "/tmp/.application/v2/.cbc-bin/v2", "./cmd/service"},
+		Env:     []string{"CGO_ENABLED=0"},
+		Dir:     "/tmp/.application/v2",
+	}, build)
+
+	commands, err := ReadCommands(strings.NewReader(`
+build:
+  - command: make
+    args: [generate]
+  - command: go
+    args: [build, -tags, prod, -o, "{{.ExecFile}}", ./cmd/service]
+    env: [CGO_ENABLED=1]
+run:
+  command: ./bin/service
+  args: [--listen, "{{.Endpoint}}"]
+  env: ["VERSION={{.Name}}"]
+  dir: deploy
+`))
+	require.NoError(t, err)
+	require.NoError(t, commands.Validate(""))
+	require.Len(t, commands.BuildCommands(), 2)
+	run, err := commands.RunCommand().Render(data)
+	require.NoError(t, err)
+	require.Equal(t, []string{"--listen", "0.0.0.0:3001"}, run.Args)
+	require.Equal(t, []string{"VERSION=v2"}, run.Env)
+	require.Equal(t, "/tmp/.application/v2/deploy", run.Dir)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, commands.Write(buf))
+	readCommands, err := ReadCommands(buf)
+	require.NoError(t, err)
+	require.Equal(t, commands, readCommands)
+
+	_, err = Command{Command: "{{.Unknown}}"}.Render(data)
+	require.Error(t, err)
+	require.Error(t, (&Commands{Build: []Command{{}}}).Validate(""))
+}
@@ -0,0 +1,160 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
+)
+
+// Client is a client of the control API of the application runner.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the control API at baseURL. If httpClient is
+// nil, http.DefaultClient is used.
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		errRes := &errorResponse{}
+		if err := json.NewDecoder(res.Body).Decode(errRes); err != nil || errRes.Error == "" {
+			return fmt.Errorf("%s %s: %s", method, path, res.Status)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, errRes.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func versionPath(name, action string) string {
+	p := "/versions/" + url.PathEscape(name)
+	if action != "" {
+		p += "/" + action
+	}
+	return p
+}
+
+// Status returns the status of all versions.
+func (c *Client) Status(ctx context.Context) ([]VersionStatus, error) {
+	var statuses []VersionStatus
+	return statuses, c.do(ctx, http.MethodGet, "/versions", nil, &statuses)
+}
+
+// VersionStatus returns the status of a version.
+func (c *Client) VersionStatus(ctx context.Context, name string) (*VersionStatus, error) {
+	status := &VersionStatus{}
+	return status, c.do(ctx, http.MethodGet, versionPath(name, ""), nil, status)
+}
+
+// Restart restarts a version.
+func (c *Client) Restart(ctx context.Context, name string) (*VersionStatus, error) {
+	status := &VersionStatus{}
+	return status, c.do(ctx, http.MethodPost, versionPath(name, "restart"), nil, status)
+}
+
+// Switch builds the source path or git reference and restarts the version
+// with it. The call returns once the build finished.
+func (c *Client) Switch(ctx context.Context, name, source string) (*VersionStatus, error) {
+	status := &VersionStatus{}
+	return status, c.do(ctx, http.MethodPost, versionPath(name, "switch"), switchRequest{Source: source}, status)
+}
+
+// Logs returns up to n of the most recent output lines of a version. If n is
+// not positive all buffered lines are returned.
+func (c *Client) Logs(ctx context.Context, name string, n int) ([]string, error) {
+	p := versionPath(name, "logs")
+	if n > 0 {
+		p += "?n=" + strconv.Itoa(n)
+	}
+	var lines []string
+	return lines, c.do(ctx, http.MethodGet, p, nil, &lines)
+}
+
+// CgroupStats takes a snapshot of the cgroup stats of a version.
+func (c *Client) CgroupStats(ctx context.Context, name string) (*cgroups.StatsSample, error) {
+	sample := &cgroups.StatsSample{}
+	return sample, c.do(ctx, http.MethodPost, versionPath(name, "cgroup-stats"), nil, sample)
+}
+
+// Shutdown stops all versions and the application runner. The call does not
+// wait until the versions exited.
+func (c *Client) Shutdown(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/shutdown", nil, nil)
+}
+
+// WaitReady polls the status until all versions are ready or the context is
+// done.
+func (c *Client) WaitReady(ctx context.Context, interval time.Duration) ([]VersionStatus, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		statuses, err := c.Status(ctx)
+		if err == nil && allReady(statuses) {
+			return statuses, nil
+		}
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return nil, fmt.Errorf("versions not ready: %w", err)
+			}
+			return nil, fmt.Errorf("versions not ready: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func allReady(statuses []VersionStatus) bool {
+	if len(statuses) == 0 {
+		return false
+	}
+	for _, status := range statuses {
+		if !status.Ready {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
+)
+
+// ErrUnknownVersion is returned for versions that are not run by the runner.
+var ErrUnknownVersion = errors.New("unknown version")
+
+// ErrNoCgroups is returned for cgroup snapshots if the versions do not run in
+// cgroups.
+var ErrNoCgroups = errors.New("versions do not run in cgroups")
+
+const (
+	StateBuilding = "building"
+	StateRunning  = "running"
+	StateExited   = "exited"
+	StateStopped  = "stopped"
+)
+
+// VersionStatus is the state of a version of the application runner.
+type VersionStatus struct {
+	Name     string `json:"name"`
+	Source   string `json:"source"`
+	Endpoint string `json:"endpoint"`
+	State    string `json:"state"`
+	// Ready reports whether the version is running and accepts connections.
+	Ready     bool                    `json:"ready"`
+	Restarts  int                     `json:"restarts"`
+	StartedAt time.Time               `json:"startedAt"`
+	LastExit  *application.ExitStatus `json:"lastExit,omitempty"`
+	Error     string                  `json:"error,omitempty"`
+}
+
+// Runner is the application runner that is controlled by the API.
+type Runner interface {
+	Status() []VersionStatus
+	// Restart stops the version and starts it again.
+	Restart(name string) error
+	// Switch builds the source path or git reference and restarts the
+	// version with the new build.
+	Switch(ctx context.Context, name, source string) error
+	// Logs returns up to n of the most recent output lines of the version.
+	Logs(name string, n int) ([]string, error)
+	CgroupStats(name string) (*cgroups.StatsSample, error)
+	// Shutdown stops all versions and the runner.
+	Shutdown()
+}
+
+type switchRequest struct {
+	Source string `json:"source"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type handler struct {
+	runner Runner
+	token  string
+}
+
+// NewHandler returns the HTTP/JSON API of the runner. All requests must be
+// authenticated with the token as bearer token.
+//
+//	GET  /versions                    status of all versions
+//	GET  /versions/{name}             status of a version
+//	POST /versions/{name}/restart     restart a version
+//	POST /versions/{name}/switch      switch a version to {"source": "..."}
+//	GET  /versions/{name}/logs?n=100  recent output lines of a version
+//	POST /versions/{name}/cgroup-stats  snapshot of the cgroup stats
+//	POST /shutdown                    stop all versions and the runner
+func NewHandler(runner Runner, token string) http.Handler {
+	return &handler{runner: runner, token: token}
+}
+
+func (h *handler) authorized(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "shutdown":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		h.runner.Shutdown()
+		writeJSON(w, http.StatusAccepted, h.runner.Status())
+	case path == "versions":
+		if allowMethod(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, h.runner.Status())
+		}
+	case strings.HasPrefix(path, "versions/"):
+		name, action, _ := strings.Cut(strings.TrimPrefix(path, "versions/"), "/")
+		h.serveVersion(w, r, name, action)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+	}
+}
+
+func (h *handler) serveVersion(w http.ResponseWriter, r *http.Request, name, action string) {
+	switch action {
+	case "":
+		if allowMethod(w, r, http.MethodGet) {
+			h.writeVersionStatus(w, http.StatusOK, name, nil)
+		}
+	case "restart":
+		if allowMethod(w, r, http.MethodPost) {
+			h.writeVersionStatus(w, http.StatusAccepted, name, h.runner.Restart(name))
+		}
+	case "switch":
+		if allowMethod(w, r, http.MethodPost) {
+			h.serveSwitch(w, r, name)
+		}
+	case "logs":
+		if allowMethod(w, r, http.MethodGet) {
+			h.serveLogs(w, r, name)
+		}
+	case "cgroup-stats":
+		if allowMethod(w, r, http.MethodPost) {
+			sample, err := h.runner.CgroupStats(name)
+			writeResult(w, sample, err)
+		}
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown action: %s", action))
+	}
+}
+
+func (h *handler) serveSwitch(w http.ResponseWriter, r *http.Request, name string) {
+	req := &switchRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Source == "" {
+		writeError(w, http.StatusBadRequest, errors.New(`expected {"source": "..."}`))
+		return
+	}
+	h.writeVersionStatus(w, http.StatusOK, name, h.runner.Switch(r.Context(), name, req.Source))
+}
+
+func (h *handler) serveLogs(w http.ResponseWriter, r *http.Request, name string) {
+	n := 0
+	if nStr := r.URL.Query().Get("n"); nStr != "" {
+		var err error
+		n, err = strconv.Atoi(nStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid number of lines: %s", nStr))
+			return
+		}
+	}
+	lines, err := h.runner.Logs(name, n)
+	writeResult(w, lines, err)
+}
+
+// writeVersionStatus writes the status of the version if err is nil.
+func (h *handler) writeVersionStatus(w http.ResponseWriter, code int, name string, err error) {
+	if err != nil {
+		writeError(w, errorCode(err), err)
+		return
+	}
+	for _, status := range h.runner.Status() {
+		if status.Name == name {
+			writeJSON(w, code, status)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrUnknownVersion, name))
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownVersion):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNoCgroups):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeResult(w http.ResponseWriter, v any, err error) {
+	if err != nil {
+		writeError(w, errorCode(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,109 @@
+package control
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
+	"github.com/stretchr/testify/require"
+)
+
+type testRunner struct {
+	statuses []VersionStatus
+	shutdown bool
+}
+
+func (r *testRunner) Status() []VersionStatus {
+	return r.statuses
+}
+
+func (r *testRunner) version(name string) (*VersionStatus, error) {
+	for i := range r.statuses {
+		if r.statuses[i].Name == name {
+			return &r.statuses[i], nil
+		}
+	}
+	return nil, ErrUnknownVersion
+}
+
+func (r *testRunner) Restart(name string) error {
+	status, err := r.version(name)
+	if err != nil {
+		return err
+	}
+	status.Restarts++
+	status.Ready = true
+	return nil
+}
+
+func (r *testRunner) Switch(_ context.Context, name, source string) error {
+	status, err := r.version(name)
+	if err != nil {
+		return err
+	}
+	status.Source = source
+	return r.Restart(name)
+}
+
+func (r *testRunner) Logs(name string, n int) ([]string, error) {
+	if _, err := r.version(name); err != nil {
+		return nil, err
+	}
+	return []string{"a", "b", "c"}[3-n:], nil
+}
+
+func (r *testRunner) CgroupStats(string) (*cgroups.StatsSample, error) {
+	return nil, ErrNoCgroups
+}
+
+func (r *testRunner) Shutdown() {
+	r.shutdown = true
+}
+
+func TestControl(t *testing.T) {
+	runner := &testRunner{statuses: []VersionStatus{
+		{Name: "v1", Source: "main", State: StateRunning, Ready: true},
+		{Name: "v2", Source: "dev", State: StateExited},
+	}}
+	server := httptest.NewServer(NewHandler(runner, "secret"))
+	defer server.Close()
+	ctx := context.Background()
+
+	_, err := NewClient(server.URL, "wrong", nil).Status(ctx)
+	require.ErrorContains(t, err, "unauthorized")
+
+	client := NewClient(server.URL, "secret", server.Client())
+	statuses, err := client.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.False(t, allReady(statuses))
+
+	status, err := client.Switch(ctx, "v2", "v2.0.0")
+	require.NoError(t, err)
+	require.Equal(t, "v2.0.0", status.Source)
+	require.Equal(t, 1, status.Restarts)
+	statuses, err = client.WaitReady(ctx, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, allReady(statuses))
+
+	_, err = client.Restart(ctx, "v3")
+	require.ErrorContains(t, err, "unknown version")
+
+	lines, err := client.Logs(ctx, "v1", 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c"}, lines)
+
+	_, err = client.CgroupStats(ctx, "v1")
+	require.ErrorContains(t, err, ErrNoCgroups.Error())
+
+	res, err := server.Client().Get(server.URL + "/shutdown")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	require.NoError(t, client.Shutdown(ctx))
+	require.True(t, runner.shutdown)
+}
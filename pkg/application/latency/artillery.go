@@ -0,0 +1,82 @@
+package latency
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// ArtilleryResultsFile is the name of the combined csv file that is uploaded
+// by the application benchmark runner if artillery is used.
+const ArtilleryResultsFile = "combined-results.csv"
+
+var artilleryColumns = []string{"version", "period", "method", "path", "request_time_median", "request_count"}
+
+// ReadArtilleryCSV reads the combined artillery results of all versions. Each
+// row contains the median latency of an endpoint within one reporting period.
+func ReadArtilleryCSV(r io.Reader) (Samples, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("failed to read header: %w", io.EOF)
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range artilleryColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column: %s", name)
+		}
+	}
+	samples := make(Samples, 0, len(records)-1)
+	for _, record := range records[1:] {
+		sample, err := parseArtilleryRecord(columns, record)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	sortSamples(samples)
+	return samples, nil
+}
+
+func parseArtilleryRecord(columns map[string]int, record []string) (Sample, error) {
+	period, err := parseArtilleryPeriod(record[columns["period"]])
+	if err != nil {
+		return Sample{}, err
+	}
+	count, err := strconv.ParseFloat(record[columns["request_count"]], 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("invalid request count: %w", err)
+	}
+	median, err := strconv.ParseFloat(record[columns["request_time_median"]], 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("invalid request time: %w", err)
+	}
+	return Sample{
+		Version:   record[columns["version"]],
+		Endpoint:  record[columns["method"]] + " " + record[columns["path"]],
+		Timestamp: period,
+		Count:     int(count),
+		Latency:   median,
+	}, nil
+}
+
+// parseArtilleryPeriod returns the start of an artillery reporting period as
+// unix timestamp in seconds. Artillery reports the period in milliseconds,
+// depending on the version either as string or as number (e.g. 1.68e+12).
+func parseArtilleryPeriod(s string) (int64, error) {
+	period, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid period: %w", err)
+	}
+	if period > 1e11 {
+		period /= 1000
+	}
+	return int64(math.Floor(period)), nil
+}
@@ -0,0 +1,335 @@
+package latency
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application/timeslice"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/christophwitzko/masters-thesis/pkg/stats"
+)
+
+// Sample is the median latency of all requests to an endpoint of a version
+// within one time slot (one second for k6 results, one reporting period for
+// artillery results).
+type Sample struct {
+	Version   string
+	Endpoint  string
+	Timestamp int64 // unix timestamp in seconds
+	Count     int
+	Latency   float64 // median latency in milliseconds
+}
+
+type Samples []Sample
+
+// Versions returns the sorted names of all versions.
+func (s Samples) Versions() []string {
+	return s.distinct(func(sample Sample) string { return sample.Version })
+}
+
+// Endpoints returns the sorted names of all endpoints.
+func (s Samples) Endpoints() []string {
+	return s.distinct(func(sample Sample) string { return sample.Endpoint })
+}
+
+func (s Samples) distinct(fn func(sample Sample) string) []string {
+	seen := make(map[string]bool)
+	values := make([]string, 0)
+	for _, sample := range s {
+		value := fn(sample)
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (s Samples) Filter(fn func(sample Sample) bool) Samples {
+	filtered := make(Samples, 0)
+	for _, sample := range s {
+		if fn(sample) {
+			filtered = append(filtered, sample)
+		}
+	}
+	return filtered
+}
+
+// Window returns the time range in which all versions were under load
+// simultaneously. ok is false if there is no such time range.
+func (s Samples) Window() (start, end int64, ok bool) {
+	first := make(map[string]int64)
+	last := make(map[string]int64)
+	for _, sample := range s {
+		if t, found := first[sample.Version]; !found || sample.Timestamp < t {
+			first[sample.Version] = sample.Timestamp
+		}
+		if t, found := last[sample.Version]; !found || sample.Timestamp > t {
+			last[sample.Version] = sample.Timestamp
+		}
+	}
+	if len(first) == 0 {
+		return 0, 0, false
+	}
+	initialized := false
+	for version := range first {
+		if !initialized || first[version] > start {
+			start = first[version]
+		}
+		if !initialized || last[version] < end {
+			end = last[version]
+		}
+		initialized = true
+	}
+	return start, end, start <= end
+}
+
+type sampleKey struct {
+	Version   string
+	Endpoint  string
+	Timestamp int64
+}
+
+// k6Columns contains the indices of the relevant columns of a k6 csv output.
+type k6Columns struct {
+	metricName, timestamp, metricValue, name, method int
+}
+
+func parseK6Header(header []string) (k6Columns, error) {
+	indices := make(map[string]int)
+	for i, name := range header {
+		indices[name] = i
+	}
+	for _, name := range []string{"metric_name", "timestamp", "metric_value", "name"} {
+		if _, ok := indices[name]; !ok {
+			return k6Columns{}, fmt.Errorf("missing column: %s", name)
+		}
+	}
+	columns := k6Columns{
+		metricName:  indices["metric_name"],
+		timestamp:   indices["timestamp"],
+		metricValue: indices["metric_value"],
+		name:        indices["name"],
+		method:      -1,
+	}
+	if i, ok := indices["method"]; ok {
+		columns.method = i
+	}
+	return columns, nil
+}
+
+// parse returns the endpoint, timestamp and latency of a http_req_duration
+// record. ok is false for all other metrics.
+func (c k6Columns) parse(record []string) (key sampleKey, value float64, ok bool, err error) {
+	if record[c.metricName] != "http_req_duration" {
+		return key, 0, false, nil
+	}
+	key.Timestamp, err = strconv.ParseInt(record[c.timestamp], 10, 64)
+	if err != nil {
+		return key, 0, false, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	value, err = strconv.ParseFloat(record[c.metricValue], 64)
+	if err != nil {
+		return key, 0, false, fmt.Errorf("invalid metric value: %w", err)
+	}
+	key.Endpoint = record[c.name]
+	if c.method >= 0 && record[c.method] != "" {
+		key.Endpoint = record[c.method] + " " + key.Endpoint
+	}
+	return key, value, true, nil
+}
+
+// ReadK6CSV reads the csv output of k6 and aggregates the http_req_duration
+// metric per endpoint and second.
+func ReadK6CSV(r io.Reader, version string) (Samples, error) {
+	return readK6CSV(r, func(int64) (string, bool) {
+		return version, true
+	})
+}
+
+// ReadTimeSlicedK6CSV reads the csv output of k6 for the time slicing router
+// and assigns each second to the version of its slice. Seconds that overlap a
+// switch between two slices or contain requests that were frozen past the
+// drain timeout are skipped.
+func ReadTimeSlicedK6CSV(r io.Reader, schedule timeslice.Schedule) (Samples, error) {
+	return readK6CSV(r, schedule.VersionAt)
+}
+
+func readK6CSV(r io.Reader, versionAt func(timestamp int64) (string, bool)) (Samples, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns, err := parseK6Header(header)
+	if err != nil {
+		return nil, err
+	}
+
+	latencies := make(map[sampleKey][]float64)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) != len(header) {
+			continue
+		}
+		key, value, ok, err := columns.parse(record)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if key.Version, ok = versionAt(key.Timestamp); ok {
+			latencies[key] = append(latencies[key], value)
+		}
+	}
+
+	samples := make(Samples, 0, len(latencies))
+	for key, values := range latencies {
+		samples = append(samples, Sample{
+			Version:   key.Version,
+			Endpoint:  key.Endpoint,
+			Timestamp: key.Timestamp,
+			Count:     len(values),
+			Latency:   stats.Median(values),
+		})
+	}
+	sortSamples(samples)
+	return samples, nil
+}
+
+func sortSamples(samples Samples) {
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i], samples[j]
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		return a.Timestamp < b.Timestamp
+	})
+}
+
+// resultFile describes an application benchmark output file. Version is only
+// set for k6 outputs, artillery results contain the versions as column.
+type resultFile struct {
+	Version     string
+	Compression string
+	Artillery   bool
+}
+
+// LivenessFile is the name of the liveness events that are uploaded next to
+// the results by the application benchmark runner.
+const LivenessFile = "liveness.csv"
+
+// parseResultFile detects k6 outputs (e.g. v1.csv.gz -> v1) and combined
+// artillery results (combined-results.csv).
+func parseResultFile(name string) (resultFile, bool) {
+	compression, base := output.CompressionFromPath(path.Base(name))
+	if base == ArtilleryResultsFile {
+		return resultFile{Compression: compression, Artillery: true}, true
+	}
+	if base == LivenessFile || base == timeslice.ScheduleFile || !strings.HasSuffix(base, ".csv") {
+		return resultFile{}, false
+	}
+	return resultFile{Version: strings.TrimSuffix(base, ".csv"), Compression: compression}, true
+}
+
+// readSchedule reads the time slicing schedule if it is one of the files.
+func readSchedule(ctx context.Context, source output.Source, names []string) (timeslice.Schedule, error) {
+	for _, name := range names {
+		compression, base := output.CompressionFromPath(path.Base(name))
+		if base != timeslice.ScheduleFile {
+			continue
+		}
+		reader, err := source.Open(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		decompressedReader, err := output.NewDecompressedReader(compression, reader)
+		if err != nil {
+			_ = reader.Close()
+			return nil, err
+		}
+		defer decompressedReader.Close()
+		schedule, err := timeslice.ReadScheduleCSV(decompressedReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return schedule, nil
+	}
+	return nil, nil
+}
+
+// ReadResults reads all k6 csv outputs (e.g. v1.csv.gz and v2.csv.gz) and
+// combined artillery results stored at the given location (file, directory
+// or bucket prefix). If the location contains a time slicing schedule, the
+// k6 outputs are split into the versions of the slices.
+func ReadResults(ctx context.Context, location string) (Samples, error) {
+	source, err := output.NewSource(location)
+	if err != nil {
+		return nil, err
+	}
+	names, err := source.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	schedule, err := readSchedule(ctx, source, names)
+	if err != nil {
+		return nil, err
+	}
+	samples := make(Samples, 0)
+	for _, name := range names {
+		file, ok := parseResultFile(name)
+		if !ok {
+			continue
+		}
+		fileSamples, err := readFile(ctx, source, name, file.Compression, func(r io.Reader) (Samples, error) {
+			switch {
+			case file.Artillery:
+				return ReadArtilleryCSV(r)
+			case schedule != nil:
+				return ReadTimeSlicedK6CSV(r, schedule)
+			default:
+				return ReadK6CSV(r, file.Version)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		samples = append(samples, fileSamples...)
+	}
+	sortSamples(samples)
+	return samples, nil
+}
+
+func readFile(ctx context.Context, source output.Source, name, compression string, readFn func(r io.Reader) (Samples, error)) (Samples, error) {
+	reader, err := source.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	decompressedReader, err := output.NewDecompressedReader(compression, reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	defer decompressedReader.Close()
+	return readFn(decompressedReader)
+}
@@ -0,0 +1,110 @@
+package latency
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application/timeslice"
+	"github.com/stretchr/testify/require"
+)
+
+const k6CSV = `metric_name,timestamp,metric_value,check,error,error_code,expected_response,group,method,name,proto,scenario,service,status,subproto,tls_version,url,extra_tags,metadata
+http_reqs,1680000000,1.000000,,,,true,,GET,/a,HTTP/1.1,default,,200,,,/a,,
+http_req_duration,1680000000,2.000000,,,,true,,GET,/a,HTTP/1.1,default,,200,,,/a,,
+http_req_duration,1680000000,4.000000,,,,true,,GET,/a,HTTP/1.1,default,,200,,,/a,,
+http_req_duration,1680000000,9.000000,,,,true,,POST,/b,HTTP/1.1,default,,200,,,/b,,
+http_req_duration,1680000001,3.000000,,,,true,,GET,/a,HTTP/1.1,default,,200,,,/a,,
+`
+
+func TestReadK6CSV(t *testing.T) {
+	samples, err := ReadK6CSV(strings.NewReader(k6CSV), "v1")
+	require.NoError(t, err)
+	require.Equal(t, Samples{
+		{Version: "v1", Endpoint: "GET /a", Timestamp: 1680000000, Count: 2, Latency: 3},
+		{Version: "v1", Endpoint: "GET /a", Timestamp: 1680000001, Count: 1, Latency: 3},
+		{Version: "v1", Endpoint: "POST /b", Timestamp: 1680000000, Count: 1, Latency: 9},
+	}, samples)
+	require.Equal(t, []string{"GET /a", "POST /b"}, samples.Endpoints())
+
+	_, err = ReadK6CSV(strings.NewReader("metric_name,timestamp\n"), "v1")
+	require.Error(t, err)
+}
+
+func TestReadTimeSlicedK6CSV(t *testing.T) {
+	start := time.Unix(1680000000, 0)
+	schedule := timeslice.Schedule{
+		{Version: "v2", Start: start.Add(-time.Second), End: start.Add(time.Second)},
+		{Version: "v1", Start: start.Add(1100 * time.Millisecond), End: start.Add(5 * time.Second)},
+	}
+	samples, err := ReadTimeSlicedK6CSV(strings.NewReader(k6CSV), schedule)
+	require.NoError(t, err)
+	require.Equal(t, Samples{
+		{Version: "v2", Endpoint: "GET /a", Timestamp: 1680000000, Count: 2, Latency: 3},
+		{Version: "v2", Endpoint: "POST /b", Timestamp: 1680000000, Count: 1, Latency: 9},
+	}, samples)
+}
+
+func TestWindow(t *testing.T) {
+	samples := Samples{
+		{Version: "v1", Timestamp: 10},
+		{Version: "v1", Timestamp: 20},
+		{Version: "v2", Timestamp: 12},
+		{Version: "v2", Timestamp: 25},
+	}
+	start, end, ok := samples.Window()
+	require.True(t, ok)
+	require.Equal(t, int64(12), start)
+	require.Equal(t, int64(20), end)
+
+	_, _, ok = Samples{{Version: "v1", Timestamp: 10}, {Version: "v2", Timestamp: 11}}.Window()
+	require.False(t, ok)
+}
+
+const artilleryCSV = `version,index,period,width,scenario,method,path,request_time_median,request_count
+v1,0,1680000000000,9999,default,GET,/a,2.5,10
+v2,0,1.68000001e+12,9999,default,GET,/a,4,12
+`
+
+func TestReadArtilleryCSV(t *testing.T) {
+	samples, err := ReadArtilleryCSV(strings.NewReader(artilleryCSV))
+	require.NoError(t, err)
+	require.Equal(t, Samples{
+		{Version: "v1", Endpoint: "GET /a", Timestamp: 1680000000, Count: 10, Latency: 2.5},
+		{Version: "v2", Endpoint: "GET /a", Timestamp: 1680000010, Count: 12, Latency: 4},
+	}, samples)
+}
+
+func TestPreprocess(t *testing.T) {
+	samples := make(Samples, 0)
+	for ts := int64(100); ts < 200; ts++ {
+		samples = append(samples, Sample{Version: "v1", Endpoint: "GET /a", Timestamp: ts})
+		samples = append(samples, Sample{Version: "v2", Endpoint: "GET /a", Timestamp: ts + 10})
+	}
+	preprocessed, err := Preprocess(samples, PreprocessConfig{Warmup: 5 * time.Second, Cooldown: 10 * time.Second})
+	require.NoError(t, err)
+	require.Equal(t, int64(100), preprocessed.Origin)
+	require.Equal(t, int64(115), preprocessed.Start)
+	require.Equal(t, int64(189), preprocessed.End)
+	require.Len(t, preprocessed.Samples, 2*75)
+	for _, sample := range preprocessed.Samples {
+		require.GreaterOrEqual(t, sample.Timestamp, int64(15))
+		require.LessOrEqual(t, sample.Timestamp, int64(89))
+	}
+
+	_, err = Preprocess(samples, PreprocessConfig{Warmup: time.Minute, Cooldown: time.Minute})
+	require.Error(t, err)
+}
+
+func TestParseResultFile(t *testing.T) {
+	file, ok := parseResultFile("ab/v1.csv.gz")
+	require.True(t, ok)
+	require.Equal(t, resultFile{Version: "v1", Compression: "gzip"}, file)
+	file, ok = parseResultFile("ab/" + ArtilleryResultsFile)
+	require.True(t, ok)
+	require.True(t, file.Artillery)
+	_, ok = parseResultFile("ab/" + LivenessFile)
+	require.False(t, ok)
+	_, ok = parseResultFile("ab/" + timeslice.ScheduleFile)
+	require.False(t, ok)
+}
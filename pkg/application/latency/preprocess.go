@@ -0,0 +1,90 @@
+package latency
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+type PreprocessConfig struct {
+	Warmup   time.Duration // removed from the start of the common window
+	Cooldown time.Duration // removed from the end of the common window
+}
+
+func (c PreprocessConfig) Validate() error {
+	var confErr error
+	if c.Warmup < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("warmup must not be negative"))
+	}
+	if c.Cooldown < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("cooldown must not be negative"))
+	}
+	return confErr
+}
+
+// Preprocessed contains the samples of the time window in which all versions
+// were under load simultaneously.
+type Preprocessed struct {
+	Origin int64 // unix timestamp of the first sample of all versions
+	// Start and End are the unix timestamps (inclusive) of the common window
+	// after removing the warmup and cooldown.
+	Start, End int64
+	// Samples within the window, the timestamps are relative to Origin,
+	// hence the timelines of all versions are aligned.
+	Samples Samples
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// Preprocess aligns the timelines of all versions, slices the samples to the
+// common window and removes the warmup and cooldown phases.
+func Preprocess(samples Samples, config PreprocessConfig) (*Preprocessed, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	start, end, ok := samples.Window()
+	if !ok {
+		return nil, fmt.Errorf("the versions were never under load simultaneously")
+	}
+	origin := start
+	for _, sample := range samples {
+		if sample.Timestamp < origin {
+			origin = sample.Timestamp
+		}
+	}
+	trimmedStart := start + seconds(config.Warmup)
+	trimmedEnd := end - seconds(config.Cooldown)
+	if trimmedStart > trimmedEnd {
+		return nil, fmt.Errorf("warmup and cooldown exceed the common window of %ds", end-start)
+	}
+	res := &Preprocessed{Origin: origin, Start: trimmedStart, End: trimmedEnd, Samples: make(Samples, 0)}
+	for _, sample := range samples {
+		if sample.Timestamp < trimmedStart || sample.Timestamp > trimmedEnd {
+			continue
+		}
+		sample.Timestamp -= origin
+		res.Samples = append(res.Samples, sample)
+	}
+	return res, nil
+}
+
+var TableHeader = []string{"version", "endpoint", "time", "count", "median latency ms"}
+
+func TableRows(samples Samples) [][]string {
+	rows := make([][]string, 0, len(samples))
+	for _, sample := range samples {
+		rows = append(rows, []string{
+			sample.Version,
+			sample.Endpoint,
+			strconv.FormatInt(sample.Timestamp, 10),
+			strconv.Itoa(sample.Count),
+			strconv.FormatFloat(sample.Latency, 'f', -1, 64),
+		})
+	}
+	return rows
+}
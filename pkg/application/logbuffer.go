@@ -0,0 +1,66 @@
+package application
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LogBuffer keeps the most recent output lines of a process.
+type LogBuffer struct {
+	mu      sync.Mutex
+	size    int
+	lines   []string
+	next    int
+	full    bool
+	partial []byte
+}
+
+// NewLogBuffer returns a buffer for the last size lines.
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{size: size, lines: make([]string, size)}
+}
+
+// Write adds the complete lines of p to the buffer. An incomplete last line is
+// kept until it is completed by the next write.
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b.add(string(data[:i]))
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (b *LogBuffer) add(line string) {
+	if b.size == 0 {
+		return
+	}
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % b.size
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Lines returns up to n of the most recent lines, oldest first. If n is not
+// positive all buffered lines are returned.
+func (b *LogBuffer) Lines(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lines := make([]string, 0, b.size)
+	if b.full {
+		lines = append(lines, b.lines[b.next:]...)
+	}
+	lines = append(lines, b.lines[:b.next]...)
+	if n > 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
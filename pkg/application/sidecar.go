@@ -0,0 +1,172 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/christophwitzko/masters-thesis/pkg/probe"
+	"github.com/hashicorp/go-multierror"
+)
+
+// DefaultSidecarReadyTimeout is the time a sidecar has to pass its readiness
+// probe if no ready timeout is configured.
+const DefaultSidecarReadyTimeout = time.Minute
+
+// Sidecar is a dependency (e.g. a database or a mocked downstream service)
+// that is started for each version before the application and stopped after
+// it. The command templates are executed with SidecarTemplateData.
+type Sidecar struct {
+	Name    string `yaml:"name"`
+	Command `yaml:",inline" mapstructure:",squash"`
+	// Probe checks if the sidecar is ready. Without a path only the port is
+	// checked.
+	Probe        probe.Config  `yaml:"probe,omitempty"`
+	ReadyTimeout time.Duration `yaml:"readyTimeout,omitempty"`
+	// AppEnv are environment variable templates that are rendered with the
+	// SidecarTemplateData and passed to the application, e.g.
+	// DATABASE_URL=postgres://{{.Endpoint}}/app.
+	AppEnv []string `yaml:"appEnv,omitempty"`
+	// Cgroup adds the sidecar to the cgroup of its version.
+	Cgroup bool `yaml:"cgroup,omitempty"`
+}
+
+func (s Sidecar) readyTimeout() time.Duration {
+	if s.ReadyTimeout <= 0 {
+		return DefaultSidecarReadyTimeout
+	}
+	return s.ReadyTimeout
+}
+
+func (s Sidecar) Validate() error {
+	var err error
+	if !versionNameRe.MatchString(s.Name) {
+		err = multierror.Append(err, fmt.Errorf("invalid sidecar name %q", s.Name))
+	}
+	if s.Command.Command == "" {
+		err = multierror.Append(err, fmt.Errorf("sidecar %s has no command", s.Name))
+	}
+	if probeErr := s.Probe.WithDefaults().Validate(); probeErr != nil {
+		err = multierror.Append(err, fmt.Errorf("invalid probe of sidecar %s: %w", s.Name, probeErr))
+	}
+	return err
+}
+
+// SidecarInstance is the sidecar of a specific version.
+type SidecarInstance struct {
+	Name string
+	Host string
+	Port int
+	// DataDir is a directory dedicated to this instance of the sidecar.
+	DataDir string
+}
+
+// Endpoint returns the address the sidecar is listening on.
+func (i SidecarInstance) Endpoint() string {
+	return net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+}
+
+// SidecarTemplateData is available in the command and app env templates of a
+// sidecar, e.g. {{.Port}}, {{.DataDir}} or {{.Version.Name}}.
+type SidecarTemplateData struct {
+	SidecarInstance
+	Version TemplateData
+}
+
+func (s Sidecar) templateData(data TemplateData) SidecarTemplateData {
+	return SidecarTemplateData{SidecarInstance: data.Sidecars[s.Name], Version: data}
+}
+
+// sidecarProcess is a started sidecar.
+type sidecarProcess struct {
+	cancel context.CancelFunc
+	errCh  chan error
+}
+
+// StartSidecars starts the sidecars of the version one after another and waits
+// until each of them is ready. It returns the environment variables for the
+// application and a function that stops all started sidecars. cgroupCallback
+// is called for sidecars that should be added to the cgroup of the version.
+func StartSidecars(ctx context.Context, log *logger.Logger, sidecars []Sidecar, data TemplateData, cgroupCallback PidCallbackFunc) ([]string, func() error, error) {
+	processes := make([]*sidecarProcess, 0, len(sidecars))
+	stop := func() error {
+		var err error
+		// stop the sidecars in reverse order of their start
+		for i := len(processes) - 1; i >= 0; i-- {
+			processes[i].cancel()
+			err = merror.MaybeMultiError(err, ignoreCanceled(<-processes[i].errCh))
+		}
+		return err
+	}
+	appEnv := make([]string, 0)
+	for _, sidecar := range sidecars {
+		process, env, err := startSidecar(ctx, log, sidecar, data, cgroupCallback)
+		if process != nil {
+			processes = append(processes, process)
+		}
+		if err != nil {
+			return nil, stop, merror.MaybeMultiError(err, stop())
+		}
+		appEnv = append(appEnv, env...)
+	}
+	return appEnv, stop, nil
+}
+
+// ignoreCanceled drops the error of a sidecar that was killed because it was
+// stopped.
+func ignoreCanceled(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func startSidecar(ctx context.Context, log *logger.Logger, sidecar Sidecar, data TemplateData, cgroupCallback PidCallbackFunc) (*sidecarProcess, []string, error) {
+	sidecarData := sidecar.templateData(data)
+	rendered, err := sidecar.Command.render(sidecarData, sidecarData.DataDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sidecar %s: %w", sidecar.Name, err)
+	}
+	appEnv, err := executeTemplates(sidecar.AppEnv, sidecarData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid app env template of sidecar %s: %w", sidecar.Name, err)
+	}
+	var pidCallback PidCallbackFunc
+	if sidecar.Cgroup {
+		pidCallback = cgroupCallback
+	}
+
+	name := fmt.Sprintf("%s/%s", data.Name, sidecar.Name)
+	// the sidecar is only killed by stop, so that it outlives the application
+	sidecarCtx, cancel := context.WithCancel(context.Background())
+	process := &sidecarProcess{cancel: cancel, errCh: make(chan error, 1)}
+	go func() {
+		_, err := runProcess(sidecarCtx, log, name, rendered, nil, pidCallback, RunConfig{})
+		process.errCh <- err
+	}()
+
+	log.Infof("|%s| waiting for sidecar to be ready on %s", name, sidecarData.Endpoint())
+	readyCtx, cancelReady := context.WithTimeout(ctx, sidecar.readyTimeout())
+	defer cancelReady()
+	readyErrCh := make(chan error, 1)
+	go func() {
+		readyErrCh <- sidecar.Probe.WithDefaults().WaitReady(readyCtx, sidecarData.Endpoint())
+	}()
+	select {
+	case err := <-readyErrCh:
+		if err != nil {
+			return process, nil, fmt.Errorf("sidecar %s is not ready: %w", name, err)
+		}
+	case err := <-process.errCh:
+		// the sidecar exited before it was ready, make the error available to stop
+		process.errCh <- err
+		return process, nil, fmt.Errorf("sidecar %s exited before it was ready: %v", name, err)
+	}
+	log.Infof("|%s| sidecar is ready", name)
+	return process, appEnv, nil
+}
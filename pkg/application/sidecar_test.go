@@ -0,0 +1,54 @@
+package application
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSidecars(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+
+	data := TemplateData{
+		Version:    Version{Name: "v1", Host: "127.0.0.1", Port: 3000},
+		SourcePath: t.TempDir(),
+		Sidecars: map[string]SidecarInstance{
+			"db":     {Name: "db", Host: host, Port: port, DataDir: t.TempDir()},
+			"broken": {Name: "broken", Host: host, Port: port, DataDir: t.TempDir()},
+		},
+	}
+	sidecar := Sidecar{
+		Name:    "db",
+		Command: Command{Command: "sleep", Args: []string{"30"}},
+		AppEnv:  []string{"DB_ADDR={{.Endpoint}}", "DB_VERSION={{.Version.Name}}"},
+	}
+	require.NoError(t, sidecar.Validate())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	log := logger.New()
+	appEnv, stop, err := StartSidecars(ctx, log, []Sidecar{sidecar}, data, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"DB_ADDR=" + server.Listener.Addr().String(), "DB_VERSION=v1"}, appEnv)
+	require.NoError(t, stop())
+
+	broken := Sidecar{Name: "broken", Command: Command{Command: "false"}, ReadyTimeout: 5 * time.Second}
+	broken.Probe.Path = "/missing"
+	broken.Probe.ExpectedStatus = http.StatusNotFound
+	_, _, err = StartSidecars(ctx, log, []Sidecar{sidecar, broken}, data, nil)
+	require.ErrorContains(t, err, "v1/broken exited before it was ready")
+
+	require.Error(t, Sidecar{Name: "../db", Command: Command{Command: "db"}}.Validate())
+}
@@ -0,0 +1,269 @@
+package timeslice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+)
+
+// VersionHeader is the response header that contains the version that
+// answered the request.
+const VersionHeader = "X-Timeslice-Version"
+
+var errStopped = errors.New("time slicing stopped")
+
+// Freezer stops and resumes all processes of a version.
+type Freezer interface {
+	Freeze(name string) error
+	Thaw(name string) error
+}
+
+// Router alternates the versions in randomized time slices. Only the version
+// of the current slice is thawed and receives requests, all other versions
+// are frozen. Requests that arrive while the versions are switched wait for
+// the next slice.
+type Router struct {
+	log      *logger.Logger
+	conf     *Config
+	freezer  Freezer
+	seed     int64
+	versions []string
+	proxies  map[string]http.Handler
+
+	mu    sync.Mutex
+	slice *Slice
+	index int
+	// inFlight counts the requests of each slice, requests of a frozen slice
+	// finish once its version is thawed again
+	inFlight map[*Slice]int
+	// drained is closed once the requests of the draining slice finished
+	draining *Slice
+	drained  chan struct{}
+	// released is closed once the next slice started
+	released chan struct{}
+	stopped  bool
+	schedule []*Slice
+}
+
+// NewRouter returns a router for the versions. The versions are frozen and
+// thawed by their name.
+func NewRouter(log *logger.Logger, conf *Config, freezer Freezer, versions []application.Version) (*Router, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	if len(versions) < 2 {
+		return nil, errors.New("time slicing requires at least two versions")
+	}
+	r := &Router{
+		log:      log,
+		conf:     conf,
+		freezer:  freezer,
+		seed:     conf.Seed,
+		versions: make([]string, len(versions)),
+		proxies:  make(map[string]http.Handler, len(versions)),
+		inFlight: make(map[*Slice]int),
+		released: make(chan struct{}),
+	}
+	if r.seed == 0 {
+		r.seed = time.Now().UnixNano()
+	}
+	for i, v := range versions {
+		target, err := url.Parse("http://" + v.Endpoint())
+		if err != nil {
+			return nil, err
+		}
+		r.versions[i] = v.Name
+		r.proxies[v.Name] = httputil.NewSingleHostReverseProxy(target)
+	}
+	return r, nil
+}
+
+// Seed returns the seed of the random schedule.
+func (r *Router) Seed() int64 {
+	return r.seed
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	slice, err := r.acquire(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer r.release(slice)
+	w.Header().Set(VersionHeader, slice.Version)
+	r.proxies[slice.Version].ServeHTTP(w, req)
+}
+
+// acquire waits for a slice and counts the request as in-flight.
+func (r *Router) acquire(ctx context.Context) (*Slice, error) {
+	for {
+		r.mu.Lock()
+		if r.stopped {
+			r.mu.Unlock()
+			return nil, errStopped
+		}
+		if r.slice != nil {
+			slice := r.slice
+			r.inFlight[slice]++
+			slice.Requests++
+			r.mu.Unlock()
+			return slice, nil
+		}
+		released := r.released
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// release counts the request of the slice as finished. If the slice was
+// already recorded with undrained requests, the time the last of them
+// finished is recorded.
+func (r *Router) release(slice *Slice) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight[slice]--
+	if r.inFlight[slice] > 0 {
+		return
+	}
+	delete(r.inFlight, slice)
+	if slice == r.draining {
+		close(r.drained)
+		r.draining, r.drained = nil, nil
+	}
+	if slice.Undrained > 0 {
+		slice.Drained = time.Now()
+	}
+}
+
+// hold stops routing new requests, waits for the in-flight requests of the
+// current slice and records it. It returns the version of the slice.
+func (r *Router) hold() string {
+	r.mu.Lock()
+	current := r.slice
+	if current == nil {
+		r.mu.Unlock()
+		return ""
+	}
+	r.slice = nil
+	r.released = make(chan struct{})
+	drained := make(chan struct{})
+	if r.inFlight[current] == 0 {
+		close(drained)
+	} else {
+		r.draining, r.drained = current, drained
+	}
+	r.mu.Unlock()
+
+	timer := time.NewTimer(r.conf.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+	case <-timer.C:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.draining == current {
+		r.draining, r.drained = nil, nil
+	}
+	current.End = time.Now()
+	current.Undrained = r.inFlight[current]
+	if current.Undrained > 0 {
+		r.log.Warnf("[timeslice] %d requests to %s did not finish within %s", current.Undrained, current.Version, r.conf.DrainTimeout)
+	}
+	r.schedule = append(r.schedule, current)
+	return current.Version
+}
+
+// switchTo freezes the version of the current slice and starts a slice of the
+// next version.
+func (r *Router) switchTo(version string, round int) error {
+	previous := r.hold()
+	if previous != version {
+		if previous != "" {
+			if err := r.freezer.Freeze(previous); err != nil {
+				return fmt.Errorf("failed to freeze %s: %w", previous, err)
+			}
+		}
+		if err := r.freezer.Thaw(version); err != nil {
+			return fmt.Errorf("failed to thaw %s: %w", version, err)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slice = &Slice{
+		Index:   r.index,
+		Round:   round,
+		Version: version,
+		Start:   time.Now(),
+	}
+	r.index++
+	close(r.released)
+	return nil
+}
+
+// Run freezes all versions and alternates the slices until the context is
+// done. Afterwards all versions are thawed and new requests are rejected.
+func (r *Router) Run(ctx context.Context) error {
+	for _, version := range r.versions {
+		if err := r.freezer.Freeze(version); err != nil {
+			return merror.MaybeMultiError(fmt.Errorf("failed to freeze %s: %w", version, err), r.stop())
+		}
+	}
+	p := newPlanner(r.conf, r.versions, r.seed)
+	for {
+		version, round, duration := p.next()
+		if err := r.switchTo(version, round); err != nil {
+			return merror.MaybeMultiError(err, r.stop())
+		}
+		r.log.Infof("[timeslice] %s thawed for %s (round %d)", version, duration.Round(time.Millisecond), round)
+		timer := time.NewTimer(duration)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return r.stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// stop thaws all versions, so that they can be stopped, records the current
+// slice and rejects new requests.
+func (r *Router) stop() error {
+	var err error
+	for _, version := range r.versions {
+		if thawErr := r.freezer.Thaw(version); thawErr != nil {
+			err = merror.MaybeMultiError(err, fmt.Errorf("failed to thaw %s: %w", version, thawErr))
+		}
+	}
+	r.hold()
+	r.mu.Lock()
+	r.stopped = true
+	close(r.released)
+	r.mu.Unlock()
+	return err
+}
+
+// Schedule returns the recorded slices.
+func (r *Router) Schedule() Schedule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule := make(Schedule, len(r.schedule))
+	for i, slice := range r.schedule {
+		schedule[i] = *slice
+	}
+	return schedule
+}
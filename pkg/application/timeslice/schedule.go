@@ -0,0 +1,229 @@
+package timeslice
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ScheduleFile is the name of the recorded schedule that is uploaded next to
+// the application benchmark results.
+const ScheduleFile = "timeslice-schedule.csv"
+
+// Config configures the randomized time slices.
+type Config struct {
+	// MinSlice and MaxSlice bound the random duration of a slice.
+	MinSlice time.Duration `yaml:"minSlice,omitempty"`
+	MaxSlice time.Duration `yaml:"maxSlice,omitempty"`
+	// Seed of the random schedule (0 uses a random seed).
+	Seed int64 `yaml:"seed,omitempty"`
+	// DrainTimeout is the time the in-flight requests of a version have to
+	// finish before it is frozen.
+	DrainTimeout time.Duration `yaml:"drainTimeout,omitempty"`
+}
+
+// DefaultConfig returns the default time slices of 10-30s.
+func DefaultConfig() *Config {
+	return &Config{
+		MinSlice:     10 * time.Second,
+		MaxSlice:     30 * time.Second,
+		DrainTimeout: 5 * time.Second,
+	}
+}
+
+func (c *Config) Validate() error {
+	var confErr error
+	if c.MinSlice <= 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid min slice: %s", c.MinSlice))
+	}
+	if c.MaxSlice < c.MinSlice {
+		confErr = multierror.Append(confErr, fmt.Errorf("max slice %s is less than min slice %s", c.MaxSlice, c.MinSlice))
+	}
+	if c.DrainTimeout < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid drain timeout: %s", c.DrainTimeout))
+	}
+	return confErr
+}
+
+// planner draws the slices. Each round runs every version once in random
+// order for a random duration.
+type planner struct {
+	rand     *rand.Rand
+	conf     *Config
+	versions []string
+	round    int
+	order    []int
+}
+
+func newPlanner(conf *Config, versions []string, seed int64) *planner {
+	return &planner{
+		rand:     rand.New(rand.NewSource(seed)),
+		conf:     conf,
+		versions: versions,
+	}
+}
+
+func (p *planner) next() (version string, round int, duration time.Duration) {
+	if len(p.order) == 0 {
+		p.round++
+		p.order = p.rand.Perm(len(p.versions))
+	}
+	version = p.versions[p.order[0]]
+	p.order = p.order[1:]
+	duration = p.conf.MinSlice
+	if p.conf.MaxSlice > p.conf.MinSlice {
+		duration += time.Duration(p.rand.Int63n(int64(p.conf.MaxSlice - p.conf.MinSlice)))
+	}
+	return version, p.round, duration
+}
+
+// Slice is a time range in which only the version was thawed and received
+// requests.
+type Slice struct {
+	Index   int
+	Round   int
+	Version string
+	Start   time.Time
+	End     time.Time
+	// Requests is the number of requests routed to the version.
+	Requests int64
+	// Undrained is the number of requests that did not finish within the
+	// drain timeout before the version was frozen.
+	Undrained int
+	// Drained is the time the undrained requests finished after the version
+	// was thawed again (zero if there were none or they never finished).
+	Drained time.Time
+}
+
+// Schedule is the recorded sequence of slices.
+type Schedule []Slice
+
+// VersionAt returns the version of the slice that contains the whole second
+// starting at the unix timestamp. Seconds that overlap a switch between two
+// slices have no version, neither do the seconds in which undrained requests
+// of a previous slice of the version finished.
+func (s Schedule) VersionAt(timestamp int64) (string, bool) {
+	start := time.Unix(timestamp, 0)
+	end := start.Add(time.Second)
+	for i, slice := range s {
+		if !start.Before(slice.Start) && !end.After(slice.End) {
+			if s.undrainedUntil(i).After(start) {
+				return "", false
+			}
+			return slice.Version, true
+		}
+	}
+	return "", false
+}
+
+// undrainedUntil returns the time until which undrained requests of previous
+// slices of the same version finished. The samples of these requests are
+// recorded in the slice i, but their latency includes the time the version
+// was frozen. If they never finished, the whole slice is affected.
+func (s Schedule) undrainedUntil(i int) time.Time {
+	var until time.Time
+	for _, previous := range s[:i] {
+		if previous.Version != s[i].Version || previous.Undrained == 0 {
+			continue
+		}
+		drained := previous.Drained
+		if drained.IsZero() {
+			drained = s[i].End
+		}
+		if drained.After(until) {
+			until = drained
+		}
+	}
+	return until
+}
+
+var scheduleHeader = []string{"index", "round", "version", "start", "end", "requests", "undrained", "drained"}
+
+// WriteCSV writes the schedule as CSV.
+func (s Schedule) WriteCSV(w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(scheduleHeader); err != nil {
+		return err
+	}
+	for _, slice := range s {
+		record := []string{
+			strconv.Itoa(slice.Index),
+			strconv.Itoa(slice.Round),
+			slice.Version,
+			formatTime(slice.Start),
+			formatTime(slice.End),
+			strconv.FormatInt(slice.Requests, 10),
+			strconv.Itoa(slice.Undrained),
+			formatTime(slice.Drained),
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// ReadScheduleCSV reads a schedule written by WriteCSV.
+func ReadScheduleCSV(r io.Reader) (Schedule, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = len(scheduleHeader)
+	if _, err := csvReader.Read(); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	schedule := make(Schedule, 0)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return schedule, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		slice, err := parseSlice(record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slice %s: %w", record[0], err)
+		}
+		schedule = append(schedule, slice)
+	}
+}
+
+func parseSlice(record []string) (slice Slice, err error) {
+	slice.Version = record[2]
+	if slice.Index, err = strconv.Atoi(record[0]); err != nil {
+		return slice, err
+	}
+	if slice.Round, err = strconv.Atoi(record[1]); err != nil {
+		return slice, err
+	}
+	if slice.Start, err = time.Parse(time.RFC3339Nano, record[3]); err != nil {
+		return slice, err
+	}
+	if slice.End, err = time.Parse(time.RFC3339Nano, record[4]); err != nil {
+		return slice, err
+	}
+	if slice.Requests, err = strconv.ParseInt(record[5], 10, 64); err != nil {
+		return slice, err
+	}
+	if slice.Undrained, err = strconv.Atoi(record[6]); err != nil {
+		return slice, err
+	}
+	if record[7] != "" {
+		slice.Drained, err = time.Parse(time.RFC3339Nano, record[7])
+	}
+	return slice, err
+}
+
+// formatTime formats the time as RFC 3339 in UTC, the zero time is empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,218 @@
+package timeslice
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanner(t *testing.T) {
+	conf := &Config{MinSlice: time.Second, MaxSlice: 2 * time.Second}
+	p := newPlanner(conf, []string{"v1", "v2", "v3"}, 42)
+	for round := 1; round <= 10; round++ {
+		seen := make(map[string]bool)
+		for i := 0; i < 3; i++ {
+			version, r, duration := p.next()
+			require.Equal(t, round, r)
+			require.False(t, seen[version])
+			seen[version] = true
+			require.GreaterOrEqual(t, duration, conf.MinSlice)
+			require.Less(t, duration, conf.MaxSlice)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	start := time.Unix(100, 500_000_000).UTC()
+	schedule := Schedule{
+		{Index: 0, Round: 1, Version: "v2", Start: start, End: start.Add(3 * time.Second), Requests: 10},
+		{Index: 1, Round: 1, Version: "v1", Start: start.Add(3100 * time.Millisecond), End: start.Add(6 * time.Second), Requests: 9, Undrained: 1, Drained: start.Add(7200 * time.Millisecond)},
+		// the undrained request of v1 finished within the second 107
+		{Index: 2, Round: 2, Version: "v1", Start: start.Add(6100 * time.Millisecond), End: start.Add(10 * time.Second), Requests: 8, Undrained: 1},
+		// the undrained request of v1 never finished
+		{Index: 3, Round: 2, Version: "v2", Start: start.Add(10100 * time.Millisecond), End: start.Add(13 * time.Second), Requests: 7},
+		{Index: 4, Round: 3, Version: "v1", Start: start.Add(13100 * time.Millisecond), End: start.Add(16 * time.Second), Requests: 6},
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, schedule.WriteCSV(buf))
+	readSchedule, err := ReadScheduleCSV(buf)
+	require.NoError(t, err)
+	require.Equal(t, schedule, readSchedule)
+
+	expectedVersions := map[int64]string{
+		100: "", 101: "v2", 102: "v2", 103: "", 104: "v1", 105: "v1",
+		106: "", 107: "", 108: "v1", 109: "v1", 110: "", 111: "v2", 112: "v2",
+		113: "", 114: "", 115: "",
+	}
+	for timestamp, expected := range expectedVersions {
+		version, ok := schedule.VersionAt(timestamp)
+		require.Equal(t, expected != "", ok, timestamp)
+		require.Equal(t, expected, version, timestamp)
+	}
+}
+
+type testFreezer struct {
+	mu     sync.Mutex
+	frozen map[string]bool
+}
+
+func (f *testFreezer) isFrozen(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.frozen[name]
+}
+
+func (f *testFreezer) Freeze(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frozen[name] = true
+	return nil
+}
+
+func (f *testFreezer) Thaw(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frozen[name] = false
+	return nil
+}
+
+// newTestVersion starts a server for the version that answers with its name.
+func newTestVersion(t *testing.T, name string, handler func(w http.ResponseWriter, r *http.Request)) application.Version {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r)
+		_, _ = io.WriteString(w, name)
+	}))
+	t.Cleanup(server.Close)
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+	return application.Version{Name: name, Host: host, Port: port}
+}
+
+func TestRouter(t *testing.T) {
+	freezer := &testFreezer{frozen: make(map[string]bool)}
+	versions := make([]application.Version, 0)
+	for _, name := range []string{"v1", "v2"} {
+		name := name
+		versions = append(versions, newTestVersion(t, name, func(w http.ResponseWriter, _ *http.Request) {
+			if freezer.isFrozen(name) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+	}
+
+	conf := &Config{MinSlice: 20 * time.Millisecond, MaxSlice: 40 * time.Millisecond, Seed: 1, DrainTimeout: time.Second}
+	router, err := NewRouter(logger.New(), conf, freezer, versions)
+	require.NoError(t, err)
+	routerServer := httptest.NewServer(router)
+	defer routerServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErrCh := make(chan error, 1)
+	go func() {
+		runErrCh <- router.Run(ctx)
+	}()
+	answered := make(map[string]int)
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		res, err := routerServer.Client().Get(routerServer.URL)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, string(body), res.Header.Get(VersionHeader))
+		answered[string(body)]++
+	}
+	cancel()
+	require.NoError(t, <-runErrCh)
+	require.False(t, freezer.isFrozen("v1"))
+	require.False(t, freezer.isFrozen("v2"))
+
+	res, err := routerServer.Client().Get(routerServer.URL)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+	schedule := router.Schedule()
+	require.Greater(t, len(schedule), 2)
+	requests := make(map[string]int)
+	for i, slice := range schedule {
+		require.Equal(t, i, slice.Index)
+		require.True(t, slice.End.After(slice.Start))
+		require.Zero(t, slice.Undrained)
+		if i > 0 {
+			require.False(t, slice.Start.Before(schedule[i-1].End))
+		}
+		requests[slice.Version] += int(slice.Requests)
+	}
+	require.Equal(t, answered, requests)
+}
+
+func TestRouterUndrained(t *testing.T) {
+	freezer := &testFreezer{frozen: make(map[string]bool)}
+	versions := make([]application.Version, 0)
+	for _, name := range []string{"v1", "v2"} {
+		versions = append(versions, newTestVersion(t, name, func(_ http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				time.Sleep(300 * time.Millisecond)
+			}
+		}))
+	}
+
+	conf := &Config{MinSlice: 20 * time.Millisecond, MaxSlice: 40 * time.Millisecond, Seed: 1, DrainTimeout: 50 * time.Millisecond}
+	router, err := NewRouter(logger.New(), conf, freezer, versions)
+	require.NoError(t, err)
+	routerServer := httptest.NewServer(router)
+	defer routerServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErrCh := make(chan error, 1)
+	go func() {
+		runErrCh <- router.Run(ctx)
+	}()
+	slowErrCh := make(chan error, 1)
+	go func() {
+		res, err := routerServer.Client().Get(routerServer.URL + "/slow")
+		if err == nil {
+			err = res.Body.Close()
+		}
+		slowErrCh <- err
+	}()
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		res, err := routerServer.Client().Get(routerServer.URL)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	}
+	require.NoError(t, <-slowErrCh)
+	cancel()
+	require.NoError(t, <-runErrCh)
+
+	// only the slice of the slow request is undrained, the following slices
+	// do not wait for it
+	undrained := 0
+	for _, slice := range router.Schedule() {
+		if slice.Undrained == 0 {
+			require.True(t, slice.Drained.IsZero())
+			continue
+		}
+		undrained++
+		require.Equal(t, 1, slice.Undrained)
+		require.True(t, slice.Drained.After(slice.End))
+	}
+	require.Equal(t, 1, undrained)
+}
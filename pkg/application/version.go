@@ -0,0 +1,179 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ManifestPrefix marks the line of the application runner output that
+// contains the manifest.
+const ManifestPrefix = "application-manifest: "
+
+var versionNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Version is a labelled version of the application.
+type Version struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+}
+
+// Endpoint returns the address the version is listening on.
+func (v Version) Endpoint() string {
+	return net.JoinHostPort(v.Host, strconv.Itoa(v.Port))
+}
+
+// ParseVersions parses versions of the form label=source, where source is a
+// source path or git reference.
+func ParseVersions(values []string) ([]Version, error) {
+	versions := make([]Version, 0, len(values))
+	seen := make(map[string]bool)
+	for _, value := range values {
+		name, source, found := strings.Cut(value, "=")
+		if !found || source == "" {
+			return nil, fmt.Errorf("invalid version %q: expected label=source", value)
+		}
+		if !versionNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid version label %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate version label %q", name)
+		}
+		seen[name] = true
+		versions = append(versions, Version{Name: name, Source: source})
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no versions provided")
+	}
+	return versions, nil
+}
+
+// PortRange is an inclusive range of ports.
+type PortRange struct {
+	First, Last int
+}
+
+// ParsePortRange parses a port range of the form first-last or a single port.
+func ParsePortRange(s string) (PortRange, error) {
+	firstStr, lastStr, found := strings.Cut(s, "-")
+	if !found {
+		lastStr = firstStr
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(firstStr))
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(lastStr))
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+	if first < 1 || last > 65535 || first > last {
+		return PortRange{}, fmt.Errorf("invalid port range %q", s)
+	}
+	return PortRange{First: first, Last: last}, nil
+}
+
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// PortAllocator hands out the ports of a range that are not in use one after
+// another.
+type PortAllocator struct {
+	portRange PortRange
+	host      string
+	inUse     func(endpoint string) bool
+	next      int
+}
+
+// Allocator returns a port allocator for the host. inUse is optional and
+// reports whether an endpoint is already taken.
+func (r PortRange) Allocator(host string, inUse func(endpoint string) bool) *PortAllocator {
+	return &PortAllocator{portRange: r, host: host, inUse: inUse, next: r.First}
+}
+
+// Next returns the next free port of the range.
+func (a *PortAllocator) Next() (int, error) {
+	for a.next <= a.portRange.Last && a.inUse != nil && a.inUse(net.JoinHostPort(a.host, strconv.Itoa(a.next))) {
+		a.next++
+	}
+	if a.next > a.portRange.Last {
+		return 0, fmt.Errorf("port range %s has no free port left", a.portRange)
+	}
+	a.next++
+	return a.next - 1, nil
+}
+
+// AllocateVersions assigns each version the host and the next free port.
+func (a *PortAllocator) AllocateVersions(versions []Version) error {
+	for i := range versions {
+		port, err := a.Next()
+		if err != nil {
+			return fmt.Errorf("failed to allocate port for version %s: %w", versions[i].Name, err)
+		}
+		versions[i].Host = a.host
+		versions[i].Port = port
+	}
+	return nil
+}
+
+// Manifest describes the versions started by the application runner.
+type Manifest struct {
+	Versions []Version `json:"versions"`
+	// Router is set if the versions run in time slices. It forwards the
+	// requests to the version of the current slice.
+	Router *Version `json:"router,omitempty"`
+}
+
+// Targets returns the versions as application benchmark targets
+// (label=host:port). If the versions run in time slices, the router is the
+// only target. If host is not empty, it replaces the bind address of the
+// versions.
+func (m *Manifest) Targets(host string) []string {
+	versions := m.Versions
+	if m.Router != nil {
+		versions = []Version{*m.Router}
+	}
+	targets := make([]string, 0, len(versions))
+	for _, v := range versions {
+		if host != "" {
+			v.Host = host
+		}
+		targets = append(targets, fmt.Sprintf("%s=%s", v.Name, v.Endpoint()))
+	}
+	return targets
+}
+
+// Write writes the manifest as JSON.
+func (m *Manifest) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(m)
+}
+
+// Line returns the manifest as a single prefixed line.
+func (m *Manifest) Line() (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return ManifestPrefix + string(data), nil
+}
+
+// ParseManifestLine parses a line created by Line. It reports false if the
+// line does not contain a manifest.
+func ParseManifestLine(line string) (*Manifest, bool, error) {
+	idx := strings.Index(line, ManifestPrefix)
+	if idx < 0 {
+		return nil, false, nil
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal([]byte(line[idx+len(ManifestPrefix):]), m); err != nil {
+		return nil, true, fmt.Errorf("failed to parse application manifest: %w", err)
+	}
+	return m, true, nil
+}
@@ -0,0 +1,48 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersions(t *testing.T) {
+	versions, err := ParseVersions([]string{"v1=main", "v2=perf-issue", "v3=./local"})
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.Equal(t, Version{Name: "v3", Source: "./local"}, versions[2])
+
+	_, err = ParseVersions([]string{"v1=main", "v1=other"})
+	require.Error(t, err)
+	_, err = ParseVersions([]string{"v1"})
+	require.Error(t, err)
+	_, err = ParseVersions([]string{"../v1=main"})
+	require.Error(t, err)
+
+	portRange, err := ParsePortRange("3000-3002")
+	require.NoError(t, err)
+	err = portRange.Allocator("0.0.0.0", func(endpoint string) bool {
+		return endpoint == "0.0.0.0:3001"
+	}).AllocateVersions(versions)
+	require.Error(t, err)
+	require.NoError(t, portRange.Allocator("0.0.0.0", nil).AllocateVersions(versions))
+	require.Equal(t, 3002, versions[2].Port)
+
+	_, err = ParsePortRange("3010-3000")
+	require.Error(t, err)
+
+	manifest := &Manifest{Versions: versions}
+	line, err := manifest.Line()
+	require.NoError(t, err)
+	parsed, found, err := ParseManifestLine(line)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, manifest, parsed)
+	require.Equal(t, []string{"v1=10.0.0.2:3000", "v2=10.0.0.2:3001", "v3=10.0.0.2:3002"}, parsed.Targets("10.0.0.2"))
+	parsed.Router = &Version{Name: "timeslice", Host: "0.0.0.0", Port: 3003}
+	require.Equal(t, []string{"timeslice=10.0.0.2:3003"}, parsed.Targets("10.0.0.2"))
+
+	_, found, err = ParseManifestLine("some log line")
+	require.NoError(t, err)
+	require.False(t, found)
+}
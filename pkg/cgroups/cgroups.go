@@ -11,7 +11,13 @@ var (
 	defaultCgroupName = "/app-runner"
 )
 
-func Setup() error {
+// Setup creates a child cgroup for each of the given names with the resources
+// of its profile. The controllers required by the profiles must be available
+// on the host.
+func Setup(config *ResourceConfig, names ...string) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
 	m, err := cgroups.LoadManager(defaultMountPoint, defaultCgroupName)
 	// return no error if group does not exist
 	if err != nil {
@@ -22,30 +28,65 @@ func Setup() error {
 		return fmt.Errorf("failed to delete already existing cgroup: %w", err)
 	}
 
-	m, err = cgroups.NewManager(defaultMountPoint, defaultCgroupName, &cgroups.Resources{CPU: &cgroups.CPU{}})
+	available, err := m.RootControllers()
 	if err != nil {
-		return fmt.Errorf("failed to create cgroup manager: %w", err)
+		return fmt.Errorf("failed to read available cgroup controllers: %w", err)
 	}
-
-	// allow each version 150% CPU usage
-	quota := int64(150000)
-	period := uint64(100000)
-	resources := &cgroups.Resources{
-		CPU: &cgroups.CPU{
-			Max: cgroups.NewCPUMax(&quota, &period),
-		},
+	childResources := make([]*cgroups.Resources, len(names))
+	parentResources := &cgroups.Resources{}
+	for i, name := range names {
+		profile := config.ForVersion(name)
+		childResources[i] = profile.resources()
+		if err := checkHost(available, profile, childResources[i]); err != nil {
+			return fmt.Errorf("invalid resources of %s: %w", name, err)
+		}
+		if childResources[i].Memory == nil && hasController(available, "memory") {
+			// enable the memory controller without limits, so that the memory
+			// stats and events of the version are available
+			childResources[i].Memory = &cgroups.Memory{}
+		}
+		enableControllers(parentResources, childResources[i])
 	}
-	_, err = m.NewChild("v1", resources)
+
+	m, err = cgroups.NewManager(defaultMountPoint, defaultCgroupName, parentResources)
 	if err != nil {
-		return fmt.Errorf("failed to create cgroup child group for v1: %w", err)
+		return fmt.Errorf("failed to create cgroup manager: %w", err)
 	}
-	_, err = m.NewChild("v2", resources)
-	if err != nil {
-		return fmt.Errorf("failed to create cgroup child group for v2: %w", err)
+	for i, name := range names {
+		_, err = m.NewChild(name, childResources[i])
+		if err != nil {
+			return fmt.Errorf("failed to create cgroup child group for %s: %w", name, err)
+		}
 	}
 	return nil
 }
 
+func hasController(available []string, controller string) bool {
+	for _, c := range available {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// enableControllers adds an empty resource to parent for each controller used
+// by child, so that the controllers are enabled for the children.
+func enableControllers(parent, child *cgroups.Resources) {
+	if child.CPU != nil && parent.CPU == nil {
+		parent.CPU = &cgroups.CPU{}
+	}
+	if child.Memory != nil && parent.Memory == nil {
+		parent.Memory = &cgroups.Memory{}
+	}
+	if child.IO != nil && parent.IO == nil {
+		parent.IO = &cgroups.IO{}
+	}
+	if child.Pids != nil && parent.Pids == nil {
+		parent.Pids = &cgroups.Pids{}
+	}
+}
+
 func AddProcess(name string, pid int) error {
 	m, err := cgroups.LoadManager(defaultMountPoint, defaultCgroupName+"/"+name)
 	if err != nil {
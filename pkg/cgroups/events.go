@@ -0,0 +1,154 @@
+package cgroups
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/internal/cgroups"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+)
+
+// MemoryEvent is a change of the memory event counters (memory.events) of a
+// version. The counters are cumulative, the kind is the most severe counter
+// that changed.
+type MemoryEvent struct {
+	Version string
+	Time    time.Time
+	Kind    string
+	High    uint64
+	Max     uint64
+	OOM     uint64
+	OOMKill uint64
+}
+
+const (
+	MemoryEventHigh    = "high"
+	MemoryEventMax     = "max"
+	MemoryEventOOM     = "oom"
+	MemoryEventOOMKill = "oom_kill"
+)
+
+// IsOOM reports whether the version ran out of memory.
+func (e MemoryEvent) IsOOM() bool {
+	return e.Kind == MemoryEventOOM || e.Kind == MemoryEventOOMKill
+}
+
+func (e MemoryEvent) String() string {
+	return fmt.Sprintf("%s memory event (high=%d max=%d oom=%d oom_kill=%d)", e.Kind, e.High, e.Max, e.OOM, e.OOMKill)
+}
+
+// newMemoryEvent returns the memory event of the change from last to current
+// or false if none of the relevant counters changed.
+func newMemoryEvent(name string, last, current cgroups.Event) (MemoryEvent, bool) {
+	e := MemoryEvent{
+		Version: name,
+		Time:    time.Now(),
+		High:    current.High,
+		Max:     current.Max,
+		OOM:     current.OOM,
+		OOMKill: current.OOMKill,
+	}
+	switch {
+	case current.OOMKill > last.OOMKill:
+		e.Kind = MemoryEventOOMKill
+	case current.OOM > last.OOM:
+		e.Kind = MemoryEventOOM
+	case current.Max > last.Max:
+		e.Kind = MemoryEventMax
+	case current.High > last.High:
+		e.Kind = MemoryEventHigh
+	default:
+		return e, false
+	}
+	return e, true
+}
+
+// WatchMemoryEvents calls onEvent for each memory event of the versions until
+// the context is done. It requires the memory controller.
+func WatchMemoryEvents(ctx context.Context, names []string, onEvent func(MemoryEvent)) error {
+	errCh := make(chan error, len(names))
+	for _, name := range names {
+		m, err := cgroups.LoadManager(defaultMountPoint, defaultCgroupName+"/"+name)
+		if err != nil {
+			return err
+		}
+		go func(name string, m *cgroups.Manager) {
+			errCh <- watchMemoryEvents(ctx, name, m, onEvent)
+		}(name, m)
+	}
+	var err error
+	for range names {
+		err = merror.MaybeMultiError(err, <-errCh)
+	}
+	return err
+}
+
+func watchMemoryEvents(ctx context.Context, name string, m *cgroups.Manager, onEvent func(MemoryEvent)) error {
+	eventCh, errCh := m.EventChan()
+	last := cgroups.Event{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-errCh:
+			if !ok {
+				// the cgroup is empty or was deleted
+				return nil
+			}
+			return fmt.Errorf("failed to watch memory events of %s: %w", name, err)
+		case current := <-eventCh:
+			if e, changed := newMemoryEvent(name, last, current); changed {
+				onEvent(e)
+			}
+			last = current
+		}
+	}
+}
+
+// MemoryEventRecorder collects the memory events of several versions.
+type MemoryEventRecorder struct {
+	mu     sync.Mutex
+	events []MemoryEvent
+}
+
+func (r *MemoryEventRecorder) Record(e MemoryEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// Events returns a copy of the recorded events.
+func (r *MemoryEventRecorder) Events() []MemoryEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]MemoryEvent(nil), r.events...)
+}
+
+// WriteCSV writes the recorded events as CSV.
+func (r *MemoryEventRecorder) WriteCSV(w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write([]string{"version", "time", "kind", "high", "max", "oom", "oom_kill"}); err != nil {
+		return err
+	}
+	for _, e := range r.Events() {
+		record := []string{
+			e.Version,
+			e.Time.UTC().Format(time.RFC3339Nano),
+			e.Kind,
+			strconv.FormatUint(e.High, 10),
+			strconv.FormatUint(e.Max, 10),
+			strconv.FormatUint(e.OOM, 10),
+			strconv.FormatUint(e.OOMKill, 10),
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,35 @@
+package cgroups
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/christophwitzko/masters-thesis/internal/cgroups"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryEvents(t *testing.T) {
+	_, changed := newMemoryEvent("v1", cgroups.Event{High: 2}, cgroups.Event{High: 2, Low: 1})
+	require.False(t, changed)
+
+	e, changed := newMemoryEvent("v1", cgroups.Event{High: 2}, cgroups.Event{High: 3})
+	require.True(t, changed)
+	require.Equal(t, MemoryEventHigh, e.Kind)
+	require.False(t, e.IsOOM())
+
+	e, changed = newMemoryEvent("v1", cgroups.Event{High: 3}, cgroups.Event{High: 4, Max: 1, OOM: 1, OOMKill: 1})
+	require.True(t, changed)
+	require.Equal(t, MemoryEventOOMKill, e.Kind)
+	require.True(t, e.IsOOM())
+
+	recorder := &MemoryEventRecorder{}
+	recorder.Record(e)
+	buf := &bytes.Buffer{}
+	require.NoError(t, recorder.WriteCSV(buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, "version,time,kind,high,max,oom,oom_kill", lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "v1,"))
+	require.True(t, strings.HasSuffix(lines[1], ",oom_kill,4,1,1,1"))
+}
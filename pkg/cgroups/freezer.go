@@ -0,0 +1,23 @@
+package cgroups
+
+import (
+	"github.com/christophwitzko/masters-thesis/internal/cgroups"
+)
+
+// Freeze stops all processes of the version until it is thawed.
+func Freeze(name string) error {
+	m, err := cgroups.LoadManager(defaultMountPoint, defaultCgroupName+"/"+name)
+	if err != nil {
+		return err
+	}
+	return m.Freeze()
+}
+
+// Thaw resumes the processes of a frozen version.
+func Thaw(name string) error {
+	m, err := cgroups.LoadManager(defaultMountPoint, defaultCgroupName+"/"+name)
+	if err != nil {
+		return err
+	}
+	return m.Thaw()
+}
@@ -0,0 +1,272 @@
+package cgroups
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/internal/cgroups"
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+// cpuPeriod is the period of the CPU quota in microseconds.
+const cpuPeriod = uint64(100000)
+
+// Profile limits the resources of a version. Zero values are not limited.
+type Profile struct {
+	// CPUs is the CPU quota in number of CPUs, e.g. 1.5 (cpu.max).
+	CPUs float64 `yaml:"cpus,omitempty"`
+	// CPUWeight is the relative share of CPU time from 1 to 10000 (cpu.weight).
+	CPUWeight uint64 `yaml:"cpuWeight,omitempty"`
+	// Cpuset are the cores the version is pinned to, e.g. 0-1,3 (cpuset.cpus).
+	Cpuset string `yaml:"cpuset,omitempty"`
+	// MemoryMax is the hard memory limit, e.g. 512M (memory.max).
+	MemoryMax string `yaml:"memoryMax,omitempty"`
+	// MemoryHigh is the memory throttling limit, e.g. 384M (memory.high).
+	MemoryHigh string `yaml:"memoryHigh,omitempty"`
+	// IOWeight is the relative share of IO from 1 to 1000 (io.bfq.weight). It
+	// requires a block device with the BFQ I/O scheduler.
+	IOWeight uint16 `yaml:"ioWeight,omitempty"`
+	// PidsMax is the maximum number of processes (pids.max).
+	PidsMax int64 `yaml:"pidsMax,omitempty"`
+}
+
+// override returns the profile with all set fields of o.
+func (p Profile) override(o Profile) Profile {
+	if o.CPUs != 0 {
+		p.CPUs = o.CPUs
+	}
+	if o.CPUWeight != 0 {
+		p.CPUWeight = o.CPUWeight
+	}
+	if o.Cpuset != "" {
+		p.Cpuset = o.Cpuset
+	}
+	if o.MemoryMax != "" {
+		p.MemoryMax = o.MemoryMax
+	}
+	if o.MemoryHigh != "" {
+		p.MemoryHigh = o.MemoryHigh
+	}
+	if o.IOWeight != 0 {
+		p.IOWeight = o.IOWeight
+	}
+	if o.PidsMax != 0 {
+		p.PidsMax = o.PidsMax
+	}
+	return p
+}
+
+func (p Profile) Validate() error {
+	var err error
+	if p.CPUs < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid cpus: %g", p.CPUs))
+	}
+	if p.CPUWeight > 10000 {
+		err = multierror.Append(err, fmt.Errorf("cpu weight must be between 1 and 10000: %d", p.CPUWeight))
+	}
+	if p.Cpuset != "" {
+		if _, cpusetErr := ParseCpuset(p.Cpuset); cpusetErr != nil {
+			err = multierror.Append(err, cpusetErr)
+		}
+	}
+	for name, size := range map[string]string{"memory max": p.MemoryMax, "memory high": p.MemoryHigh} {
+		if _, sizeErr := parseBytes(size); sizeErr != nil {
+			err = multierror.Append(err, fmt.Errorf("invalid %s: %w", name, sizeErr))
+		}
+	}
+	if p.IOWeight > 1000 {
+		err = multierror.Append(err, fmt.Errorf("io weight must be between 1 and 1000: %d", p.IOWeight))
+	}
+	if p.PidsMax < 0 {
+		err = multierror.Append(err, fmt.Errorf("invalid pids max: %d", p.PidsMax))
+	}
+	return err
+}
+
+// resources converts the profile into the cgroup resources. The profile must
+// be valid.
+func (p Profile) resources() *cgroups.Resources {
+	resources := &cgroups.Resources{}
+	if p.CPUs != 0 || p.CPUWeight != 0 || p.Cpuset != "" {
+		resources.CPU = &cgroups.CPU{Cpus: p.Cpuset}
+		if p.CPUs != 0 {
+			quota := int64(p.CPUs * float64(cpuPeriod))
+			period := cpuPeriod
+			resources.CPU.Max = cgroups.NewCPUMax(&quota, &period)
+		}
+		if p.CPUWeight != 0 {
+			weight := p.CPUWeight
+			resources.CPU.Weight = &weight
+		}
+	}
+	memoryMax, _ := parseBytes(p.MemoryMax)
+	memoryHigh, _ := parseBytes(p.MemoryHigh)
+	if memoryMax != nil || memoryHigh != nil {
+		resources.Memory = &cgroups.Memory{Max: memoryMax, High: memoryHigh}
+	}
+	if p.IOWeight != 0 {
+		resources.IO = &cgroups.IO{BFQ: cgroups.BFQ{Weight: p.IOWeight}}
+	}
+	if p.PidsMax != 0 {
+		resources.Pids = &cgroups.Pids{Max: p.PidsMax}
+	}
+	return resources
+}
+
+// ResourceConfig is the resource profile of all versions with optional
+// overrides per version label.
+type ResourceConfig struct {
+	Profile  `yaml:",inline" mapstructure:",squash"`
+	Versions map[string]Profile `yaml:"versions,omitempty"`
+}
+
+// DefaultResourceConfig allows each version 150% CPU usage.
+func DefaultResourceConfig() *ResourceConfig {
+	return &ResourceConfig{Profile: Profile{CPUs: 1.5}}
+}
+
+// ForVersion returns the profile of the version.
+func (c *ResourceConfig) ForVersion(name string) Profile {
+	return c.Profile.override(c.Versions[name])
+}
+
+func (c *ResourceConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	var err error
+	if profileErr := c.Profile.Validate(); profileErr != nil {
+		err = multierror.Append(err, profileErr)
+	}
+	for name, profile := range c.Versions {
+		if profileErr := profile.Validate(); profileErr != nil {
+			err = multierror.Append(err, fmt.Errorf("invalid resources of %s: %w", name, profileErr))
+		}
+	}
+	return err
+}
+
+// ReadResourceConfig reads the resource config from YAML.
+func ReadResourceConfig(r io.Reader) (*ResourceConfig, error) {
+	c := &ResourceConfig{}
+	if err := yaml.NewDecoder(r).Decode(c); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read resource config: %w", err)
+	}
+	return c, c.Validate()
+}
+
+// Write writes the resource config as YAML.
+func (c *ResourceConfig) Write(w io.Writer) error {
+	return yaml.NewEncoder(w).Encode(c)
+}
+
+// ParseCpuset parses a list of cores like 0-1,3 and returns the cores.
+func ParseCpuset(cpuset string) ([]int, error) {
+	cores := make([]int, 0)
+	for _, part := range strings.Split(cpuset, ",") {
+		firstStr, lastStr, found := strings.Cut(strings.TrimSpace(part), "-")
+		if !found {
+			lastStr = firstStr
+		}
+		first, err := strconv.Atoi(firstStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpuset %q: %w", cpuset, err)
+		}
+		last, err := strconv.Atoi(lastStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpuset %q: %w", cpuset, err)
+		}
+		if first < 0 || first > last {
+			return nil, fmt.Errorf("invalid cpuset %q", cpuset)
+		}
+		for core := first; core <= last; core++ {
+			cores = append(cores, core)
+		}
+	}
+	return cores, nil
+}
+
+var byteUnits = map[string]int64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// parseBytes parses a size like 512M or 1G (binary units). An empty size
+// returns nil.
+func parseBytes(size string) (*int64, error) {
+	if size == "" {
+		return nil, nil
+	}
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	unit := ""
+	if len(s) > 0 && (s[len(s)-1] < '0' || s[len(s)-1] > '9') {
+		unit = s[len(s)-1:]
+		s = s[:len(s)-1]
+	}
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return nil, fmt.Errorf("unknown unit of size %q", size)
+	}
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || value <= 0 {
+		return nil, fmt.Errorf("invalid size %q", size)
+	}
+	bytes := value * multiplier
+	return &bytes, nil
+}
+
+// blockSchedulers matches the I/O scheduler files of the block devices.
+var blockSchedulers = "/sys/block/*/queue/scheduler"
+
+// hasBFQDevice reports whether a block device uses the BFQ I/O scheduler.
+// Only then the io controller provides io.bfq.weight.
+func hasBFQDevice() bool {
+	files, _ := filepath.Glob(blockSchedulers)
+	for _, file := range files {
+		scheduler, err := os.ReadFile(file)
+		if err == nil && strings.Contains(string(scheduler), "[bfq]") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost checks that the controllers required by the resources are
+// available and that the pinned cores exist.
+func checkHost(available []string, profile Profile, resources *cgroups.Resources) error {
+	availableControllers := make(map[string]bool, len(available))
+	for _, c := range available {
+		availableControllers[c] = true
+	}
+	var err error
+	for _, c := range resources.EnabledControllers() {
+		if !availableControllers[c] {
+			err = multierror.Append(err, fmt.Errorf("cgroup controller %s is not available (available: %s)", c, strings.Join(available, " ")))
+		}
+	}
+	if profile.Cpuset != "" {
+		cores, _ := ParseCpuset(profile.Cpuset)
+		for _, core := range cores {
+			if core >= runtime.NumCPU() {
+				err = multierror.Append(err, fmt.Errorf("core %d of cpuset %s does not exist (%d cores)", core, profile.Cpuset, runtime.NumCPU()))
+			}
+		}
+	}
+	if profile.CPUs > float64(runtime.NumCPU()) {
+		err = multierror.Append(err, fmt.Errorf("cpus %g exceed the %d cores of the host", profile.CPUs, runtime.NumCPU()))
+	}
+	if profile.IOWeight != 0 && !hasBFQDevice() {
+		err = multierror.Append(err, fmt.Errorf("io weight requires a block device with the bfq I/O scheduler (e.g. echo bfq > /sys/block/sda/queue/scheduler)"))
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package cgroups
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceConfig(t *testing.T) {
+	c, err := ReadResourceConfig(strings.NewReader(`
+cpus: 1.5
+memoryMax: 1G
+pidsMax: 100
+versions:
+  v2:
+    cpus: 0.5
+    cpuset: 0-1,3
+    memoryHigh: 512Mi
+`))
+	require.NoError(t, err)
+
+	v1 := c.ForVersion("v1")
+	require.Equal(t, Profile{CPUs: 1.5, MemoryMax: "1G", PidsMax: 100}, v1)
+	resources := v1.resources()
+	require.Equal(t, "150000 100000", string(resources.CPU.Max))
+	require.Equal(t, int64(1<<30), *resources.Memory.Max)
+	require.Nil(t, resources.Memory.High)
+	require.Nil(t, resources.IO)
+
+	resources = c.ForVersion("v2").resources()
+	require.Equal(t, "50000 100000", string(resources.CPU.Max))
+	require.Equal(t, "0-1,3", resources.CPU.Cpus)
+	require.Equal(t, int64(512<<20), *resources.Memory.High)
+	require.Equal(t, int64(100), resources.Pids.Max)
+
+	err = checkHost([]string{"cpu", "cpuset"}, c.ForVersion("v2"), resources)
+	require.ErrorContains(t, err, "cgroup controller memory is not available")
+
+	// io.bfq.weight is only available with the bfq I/O scheduler
+	dir := t.TempDir()
+	blockSchedulers = filepath.Join(dir, "*", "scheduler")
+	defer func() { blockSchedulers = "/sys/block/*/queue/scheduler" }()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sda"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sda", "scheduler"), []byte("[mq-deadline] bfq none\n"), 0o644))
+	ioProfile := Profile{IOWeight: 100}
+	err = checkHost([]string{"io"}, ioProfile, ioProfile.resources())
+	require.ErrorContains(t, err, "bfq I/O scheduler")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sda", "scheduler"), []byte("mq-deadline [bfq] none\n"), 0o644))
+	require.NoError(t, checkHost([]string{"io"}, ioProfile, ioProfile.resources()))
+
+	cores, err := ParseCpuset("0-2,5")
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 5}, cores)
+
+	_, err = ReadResourceConfig(strings.NewReader("memoryMax: 1X\nversions:\n  v1:\n    cpuset: 2-1\n"))
+	require.ErrorContains(t, err, "unknown unit")
+	require.ErrorContains(t, err, "invalid cpuset")
+}
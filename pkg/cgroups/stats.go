@@ -0,0 +1,171 @@
+package cgroups
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/internal/cgroups"
+	"github.com/christophwitzko/masters-thesis/internal/cgroups/stats"
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+)
+
+// StatsSample is a snapshot of the cgroup stats of a version. All counters
+// are cumulative since the cgroup was created.
+type StatsSample struct {
+	Version       string    `json:"version"`
+	Time          time.Time `json:"time"`
+	CPUUsageUsec  uint64    `json:"cpuUsageUsec"`
+	CPUUserUsec   uint64    `json:"cpuUserUsec"`
+	CPUSystemUsec uint64    `json:"cpuSystemUsec"`
+	NrPeriods     uint64    `json:"nrPeriods"`
+	NrThrottled   uint64    `json:"nrThrottled"`
+	ThrottledUsec uint64    `json:"throttledUsec"`
+	MemoryUsage   uint64    `json:"memoryUsage"`
+	MemoryLimit   uint64    `json:"memoryLimit"`
+	MemoryAnon    uint64    `json:"memoryAnon"`
+	MemoryFile    uint64    `json:"memoryFile"`
+	MemoryHigh    uint64    `json:"memoryHighEvents"`
+	MemoryMax     uint64    `json:"memoryMaxEvents"`
+	OOMKill       uint64    `json:"oomKillEvents"`
+	IOReadBytes   uint64    `json:"ioReadBytes"`
+	IOWriteBytes  uint64    `json:"ioWriteBytes"`
+	IOReads       uint64    `json:"ioReads"`
+	IOWrites      uint64    `json:"ioWrites"`
+	PidsCurrent   uint64    `json:"pidsCurrent"`
+}
+
+var statsHeader = []string{
+	"version", "time",
+	"cpu_usage_usec", "cpu_user_usec", "cpu_system_usec", "nr_periods", "nr_throttled", "throttled_usec",
+	"memory_usage", "memory_limit", "memory_anon", "memory_file", "memory_high_events", "memory_max_events", "oom_kill_events",
+	"io_read_bytes", "io_write_bytes", "io_reads", "io_writes",
+	"pids_current",
+}
+
+func newStatsSample(name string, t time.Time, metrics *stats.Metrics) *StatsSample {
+	s := &StatsSample{Version: name, Time: t}
+	if cpu := metrics.CPU; cpu != nil {
+		s.CPUUsageUsec, s.CPUUserUsec, s.CPUSystemUsec = cpu.UsageUsec, cpu.UserUsec, cpu.SystemUsec
+		s.NrPeriods, s.NrThrottled, s.ThrottledUsec = cpu.NrPeriods, cpu.NrThrottled, cpu.ThrottledUsec
+	}
+	if memory := metrics.Memory; memory != nil {
+		s.MemoryUsage, s.MemoryLimit = memory.Usage, memory.UsageLimit
+		s.MemoryAnon, s.MemoryFile = memory.Anon, memory.File
+	}
+	if events := metrics.MemoryEvents; events != nil {
+		s.MemoryHigh, s.MemoryMax, s.OOMKill = events.High, events.Max, events.OomKill
+	}
+	if metrics.Io != nil {
+		for _, entry := range metrics.Io.Usage {
+			s.IOReadBytes += entry.Rbytes
+			s.IOWriteBytes += entry.Wbytes
+			s.IOReads += entry.Rios
+			s.IOWrites += entry.Wios
+		}
+	}
+	if metrics.Pids != nil {
+		s.PidsCurrent = metrics.Pids.Current
+	}
+	return s
+}
+
+func (s *StatsSample) record() []string {
+	values := []uint64{
+		s.CPUUsageUsec, s.CPUUserUsec, s.CPUSystemUsec, s.NrPeriods, s.NrThrottled, s.ThrottledUsec,
+		s.MemoryUsage, s.MemoryLimit, s.MemoryAnon, s.MemoryFile, s.MemoryHigh, s.MemoryMax, s.OOMKill,
+		s.IOReadBytes, s.IOWriteBytes, s.IOReads, s.IOWrites,
+		s.PidsCurrent,
+	}
+	record := make([]string, 0, len(statsHeader))
+	record = append(record, s.Version, s.Time.UTC().Format(time.RFC3339Nano))
+	for _, v := range values {
+		record = append(record, strconv.FormatUint(v, 10))
+	}
+	return record
+}
+
+// ReadStats reads the current stats of the cgroup of the version.
+func ReadStats(name string) (*StatsSample, error) {
+	m, err := cgroups.LoadManager(defaultMountPoint, defaultCgroupName+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := m.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cgroup stats of %s: %w", name, err)
+	}
+	return newStatsSample(name, time.Now(), metrics), nil
+}
+
+// StatsWriter writes stats samples as CSV (with header) or JSON lines.
+type StatsWriter struct {
+	w          io.WriteCloser
+	csvWriter  *csv.Writer
+	jsonWriter *json.Encoder
+}
+
+func NewStatsWriter(w io.WriteCloser, format string) (*StatsWriter, error) {
+	switch format {
+	case "csv":
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(statsHeader); err != nil {
+			return nil, err
+		}
+		return &StatsWriter{w: w, csvWriter: csvWriter}, nil
+	case "json":
+		return &StatsWriter{w: w, jsonWriter: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported stats format: %s", format)
+	}
+}
+
+func (sw *StatsWriter) Write(s *StatsSample) error {
+	if sw.jsonWriter != nil {
+		return sw.jsonWriter.Encode(s)
+	}
+	if err := sw.csvWriter.Write(s.record()); err != nil {
+		return err
+	}
+	// flush every sample so that the time series survives a crash
+	sw.csvWriter.Flush()
+	return sw.csvWriter.Error()
+}
+
+func (sw *StatsWriter) Close() error {
+	return sw.w.Close()
+}
+
+// SampleStats reads the stats of the versions every interval and writes them
+// to all writers until the context is done. Failed reads are logged, failed
+// writes stop the sampling.
+func SampleStats(ctx context.Context, log *logger.Logger, names []string, interval time.Duration, writers []*StatsWriter) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		for _, name := range names {
+			sample, err := ReadStats(name)
+			if err != nil {
+				log.Warnf("|%s| %v", name, err)
+				continue
+			}
+			var writeErr error
+			for _, w := range writers {
+				writeErr = merror.MaybeMultiError(writeErr, w.Write(sample))
+			}
+			if writeErr != nil {
+				return fmt.Errorf("failed to write cgroup stats: %w", writeErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package cgroups
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/internal/cgroups/stats"
+	"github.com/stretchr/testify/require"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestStatsWriter(t *testing.T) {
+	sample := newStatsSample("v1", time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), &stats.Metrics{
+		CPU:          &stats.CPUStat{UsageUsec: 100, NrThrottled: 2, ThrottledUsec: 30},
+		Memory:       &stats.MemoryStat{Usage: 1024, UsageLimit: 2048},
+		MemoryEvents: &stats.MemoryEvents{High: 4, OomKill: 1},
+		Io:           &stats.IOStat{Usage: []*stats.IOEntry{{Rbytes: 10, Wios: 1}, {Rbytes: 5, Wios: 2}}},
+	})
+	require.Equal(t, uint64(15), sample.IOReadBytes)
+	require.Equal(t, uint64(3), sample.IOWrites)
+
+	buf := &bytes.Buffer{}
+	w, err := NewStatsWriter(nopWriteCloser{buf}, "csv")
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sample))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, strings.Join(statsHeader, ","), lines[0])
+	require.Equal(t, "v1,2023-01-02T03:04:05Z,100,0,0,0,2,30,1024,2048,0,0,4,0,1,15,0,0,3,0", lines[1])
+
+	buf.Reset()
+	w, err = NewStatsWriter(nopWriteCloser{buf}, "json")
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sample))
+	decoded := &StatsSample{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), decoded))
+	require.Equal(t, sample, decoded)
+
+	_, err = NewStatsWriter(nopWriteCloser{buf}, "xml")
+	require.Error(t, err)
+}
@@ -47,12 +47,30 @@ func MustGetInt(cmd *cobra.Command, name string) int {
 	return val
 }
 
+func MustGetInt64(cmd *cobra.Command, name string) int64 {
+	val, err := cmd.Flags().GetInt64(name)
+	Must(err)
+	return val
+}
+
+func MustGetFloat64(cmd *cobra.Command, name string) float64 {
+	val, err := cmd.Flags().GetFloat64(name)
+	Must(err)
+	return val
+}
+
 func MustGetStringArray(cmd *cobra.Command, name string) []string {
 	val, err := cmd.Flags().GetStringArray(name)
 	Must(err)
 	return val
 }
 
+func MustGetFloat64Slice(cmd *cobra.Command, name string) []float64 {
+	val, err := cmd.Flags().GetFloat64Slice(name)
+	Must(err)
+	return val
+}
+
 func MustGetDuration(cmd *cobra.Command, name string) time.Duration {
 	val, err := cmd.Flags().GetDuration(name)
 	Must(err)
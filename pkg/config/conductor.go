@@ -3,9 +3,13 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/application/timeslice"
+	"github.com/christophwitzko/masters-thesis/pkg/cgroups"
 	"github.com/christophwitzko/masters-thesis/pkg/cli"
 	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
@@ -25,6 +29,10 @@ type ConductorMicrobenchmarkConfig struct {
 	Functions     []string
 	Outputs       []string `yaml:"outputs"`
 	Env           []string
+	MetricsPort   int `yaml:"metricsPort"`
+	// QuarantineFile is a local quarantine list (see flaky --quarantine) that
+	// is copied to the instances to exclude the flaky functions.
+	QuarantineFile string `yaml:"quarantineFile"`
 }
 
 func (c *ConductorMicrobenchmarkConfig) Validate() error {
@@ -44,6 +52,9 @@ func (c *ConductorMicrobenchmarkConfig) Validate() error {
 	if len(c.Functions) != 0 && (c.IncludeFilter != "" || c.ExcludeFilter != "") {
 		confErr = multierror.Append(confErr, fmt.Errorf("cannot use functions and include/exclude filters"))
 	}
+	if c.MetricsPort < 0 || c.MetricsPort > 65535 {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid microbenchmark metrics port: %d", c.MetricsPort))
+	}
 	return confErr
 }
 
@@ -52,13 +63,53 @@ type ConductorApplicationConfig struct {
 	InstanceType string `yaml:"instanceType"`
 	Repository   string
 	V1, V2       string
+	Versions     []string
+	PortRange    string `yaml:"portRange"`
 	Package      string
-	LogFilter    string `yaml:"logFilter"`
+	Build        []application.Command `yaml:"build,omitempty"`
+	Run          *application.Command  `yaml:"run,omitempty"`
+	Sidecars     []application.Sidecar `yaml:"sidecars,omitempty"`
+	LogFilter    string                `yaml:"logFilter"`
 	Env          []string
 	LimitCPU     bool `yaml:"limitCPU"`
-	Benchmark    *ConductorApplicationBenchmarkConfig
+	// Resources are the cgroup resource profiles of the versions.
+	Resources *cgroups.ResourceConfig `yaml:"resources,omitempty"`
+	// CgroupStats are the outputs of the cgroup stats time series.
+	CgroupStats         []string      `yaml:"cgroupStats,omitempty"`
+	CgroupStatsInterval time.Duration `yaml:"cgroupStatsInterval,omitempty"`
+	// MemoryEvents is the output of the memory events of the versions.
+	MemoryEvents     string `yaml:"memoryEvents,omitempty"`
+	FailOnOOM        bool   `yaml:"failOnOOM,omitempty"`
+	FailOnMemoryHigh int    `yaml:"failOnMemoryHigh,omitempty"`
+	// GracePeriod is the time the versions have to exit after SIGTERM.
+	GracePeriod time.Duration `yaml:"gracePeriod,omitempty"`
+	// ControlPort is the localhost port of the control API of the
+	// application runner (0 disables it).
+	ControlPort int `yaml:"controlPort,omitempty"`
+	// TimeSlicing runs the versions in alternating time slices instead of
+	// concurrently if set.
+	TimeSlicing *timeslice.Config `yaml:"timeSlicing,omitempty"`
+	// Phases are consecutive benchmark runs against the same versions. Without
+	// phases the benchmark runs once.
+	Phases    []ConductorApplicationPhase `yaml:"phases,omitempty"`
+	Benchmark *ConductorApplicationBenchmarkConfig
+}
+
+// ConductorApplicationPhase is a load phase of the application benchmark.
+// Its results are uploaded to the phase name below the benchmark output.
+type ConductorApplicationPhase struct {
+	Name string
+	// Env are additional environment variables of the benchmark.
+	Env []string
+	// Restart are the versions that are restarted before the phase.
+	Restart []string
+	// Switch maps versions to the sources they are switched to before the
+	// phase.
+	Switch map[string]string
 }
 
+var phaseNameRe = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
 func (c *ConductorApplicationConfig) Validate() error {
 	var confErr error
 	if c.InstanceType == "" {
@@ -67,18 +118,151 @@ func (c *ConductorApplicationConfig) Validate() error {
 	if c.Repository == "" {
 		confErr = multierror.Append(confErr, fmt.Errorf("missing application repository"))
 	}
-	if c.V1 == "" {
+	if len(c.Versions) == 0 && c.V1 == "" {
 		confErr = multierror.Append(confErr, fmt.Errorf("missing application v1"))
 	}
-	if c.V2 == "" {
+	if len(c.Versions) == 0 && c.V2 == "" {
 		confErr = multierror.Append(confErr, fmt.Errorf("missing application v2"))
 	}
+	if _, err := application.ParseVersions(c.VersionRefs()); err != nil {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application versions: %w", err))
+	}
+	if _, err := application.ParsePortRange(c.PortRange); err != nil {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application port range: %w", err))
+	}
+	if err := c.Commands().Validate(c.Package); err != nil {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application commands: %w", err))
+	}
+	if err := c.validateRunner(); err != nil {
+		confErr = multierror.Append(confErr, err)
+	}
 	if err := c.Benchmark.Validate(); err != nil {
 		confErr = multierror.Append(confErr, err)
 	}
 	return confErr
 }
 
+// validateRunner validates the cgroup, monitoring, control, time slicing and
+// shutdown settings of the application runner.
+func (c *ConductorApplicationConfig) validateRunner() error {
+	var confErr error
+	if c.GracePeriod < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application grace period: %s", c.GracePeriod))
+	}
+	if err := c.Resources.Validate(); err != nil {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application resources: %w", err))
+	}
+	if c.ControlPort < 0 || c.ControlPort > 65535 {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application control port: %d", c.ControlPort))
+	}
+	if err := c.validateMonitoring(); err != nil {
+		confErr = multierror.Append(confErr, err)
+	}
+	if err := c.validateTimeSlicing(); err != nil {
+		confErr = multierror.Append(confErr, err)
+	}
+	if err := c.validatePhases(); err != nil {
+		confErr = multierror.Append(confErr, err)
+	}
+	return confErr
+}
+
+// validateMonitoring validates the cgroup stats and memory events settings.
+func (c *ConductorApplicationConfig) validateMonitoring() error {
+	var confErr error
+	monitored := len(c.CgroupStats) != 0 || c.MemoryEvents != "" || c.FailOnOOM || c.FailOnMemoryHigh != 0
+	if monitored && !c.UseCgroups() {
+		confErr = multierror.Append(confErr, fmt.Errorf("application cgroup stats and memory events require limitCPU or resources"))
+	}
+	if c.FailOnMemoryHigh < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application memory high threshold: %d", c.FailOnMemoryHigh))
+	}
+	if c.CgroupStatsInterval < 0 {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application cgroup stats interval: %s", c.CgroupStatsInterval))
+	}
+	return confErr
+}
+
+// validateTimeSlicing validates the time slices. The versions are frozen via
+// cgroups and only the k6 results can be split into the slices.
+func (c *ConductorApplicationConfig) validateTimeSlicing() error {
+	if c.TimeSlicing == nil {
+		return nil
+	}
+	var confErr error
+	if err := c.TimeSlicing.Validate(); err != nil {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application time slicing: %w", err))
+	}
+	if !c.UseCgroups() {
+		confErr = multierror.Append(confErr, fmt.Errorf("application time slicing requires limitCPU or resources"))
+	}
+	if c.Benchmark != nil && c.Benchmark.Tool != "k6" {
+		confErr = multierror.Append(confErr, fmt.Errorf("application time slicing requires the k6 benchmark tool"))
+	}
+	return confErr
+}
+
+// validatePhases validates the phase names and the versions that are
+// restarted or switched via the control API between the phases.
+func (c *ConductorApplicationConfig) validatePhases() error {
+	var confErr error
+	versions := make(map[string]bool)
+	if parsed, err := application.ParseVersions(c.VersionRefs()); err == nil {
+		for _, v := range parsed {
+			versions[v.Name] = true
+		}
+	}
+	names := make(map[string]bool)
+	for _, phase := range c.Phases {
+		if !phaseNameRe.MatchString(phase.Name) || names[phase.Name] {
+			confErr = multierror.Append(confErr, fmt.Errorf("invalid or duplicate application phase name: %q", phase.Name))
+		}
+		names[phase.Name] = true
+		controlled := phase.Restart
+		for name := range phase.Switch {
+			controlled = append(controlled, name)
+		}
+		if len(controlled) != 0 && (c.ControlPort == 0 || c.TimeSlicing != nil) {
+			confErr = multierror.Append(confErr, fmt.Errorf("application phase %s restarts or switches versions, which requires the control port and no time slicing", phase.Name))
+		}
+		for _, name := range controlled {
+			if !versions[name] {
+				confErr = multierror.Append(confErr, fmt.Errorf("unknown version %s of application phase %s", name, phase.Name))
+			}
+		}
+	}
+	return confErr
+}
+
+// UseCgroups reports whether the versions run in cgroups.
+func (c *ConductorApplicationConfig) UseCgroups() bool {
+	return c.LimitCPU || c.Resources != nil
+}
+
+// Commands returns the custom build steps and run command of the application.
+func (c *ConductorApplicationConfig) Commands() *application.Commands {
+	return &application.Commands{Build: c.Build, Run: c.Run, Sidecars: c.Sidecars}
+}
+
+// HasCustomCommands reports whether the application is not built with the
+// default go build, not run as the built binary or has sidecars.
+func (c *ConductorApplicationConfig) HasCustomCommands() bool {
+	return len(c.Build) != 0 || c.Run != nil || len(c.Sidecars) != 0
+}
+
+// VersionRefs returns all versions of the application as label=reference,
+// starting with v1 and v2 if they are set.
+func (c *ConductorApplicationConfig) VersionRefs() []string {
+	refs := make([]string, 0, len(c.Versions)+2)
+	if c.V1 != "" {
+		refs = append(refs, "v1="+c.V1)
+	}
+	if c.V2 != "" {
+		refs = append(refs, "v2="+c.V2)
+	}
+	return append(refs, c.Versions...)
+}
+
 type ConductorApplicationBenchmarkConfig struct {
 	InstanceType string `yaml:"instanceType"`
 	Tool         string
@@ -86,6 +270,11 @@ type ConductorApplicationBenchmarkConfig struct {
 	Reference    string
 	Env          []string
 	Output       string
+	MetricsPort  int           `yaml:"metricsPort"`
+	ProbePath    string        `yaml:"probePath"`
+	ProbeStatus  int           `yaml:"probeStatus"`
+	ProbeTimeout time.Duration `yaml:"probeTimeout"`
+	Liveness     string
 }
 
 func (c *ConductorApplicationBenchmarkConfig) Validate() error {
@@ -96,6 +285,17 @@ func (c *ConductorApplicationBenchmarkConfig) Validate() error {
 	if c.Reference == "" {
 		confErr = multierror.Append(confErr, fmt.Errorf("missing application benchmark reference"))
 	}
+	if c.MetricsPort < 0 || c.MetricsPort > 65535 {
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application benchmark metrics port: %d", c.MetricsPort))
+	}
+	if c.ProbePath != "" && !strings.HasPrefix(c.ProbePath, "/") {
+		confErr = multierror.Append(confErr, fmt.Errorf("application benchmark probe path must start with /: %s", c.ProbePath))
+	}
+	switch c.Liveness {
+	case "", "none", "flag", "abort":
+	default:
+		confErr = multierror.Append(confErr, fmt.Errorf("invalid application benchmark liveness action: %s", c.Liveness))
+	}
 	return confErr
 }
 
@@ -164,29 +364,40 @@ func NewConductorConfig(cmd *cobra.Command) (*ConductorConfig, error) {
 		GoVersion:           viper.GetString("goVersion"),
 		Timeout:             viper.GetDuration("timeout"),
 		Microbenchmark: &ConductorMicrobenchmarkConfig{
-			Name:          viper.GetString("microbenchmark.name"),
-			InstanceType:  microbenchmarkInstanceType,
-			Repository:    viper.GetString("microbenchmark.repository"),
-			Runs:          viper.GetInt("microbenchmark.runs"),
-			SuiteRuns:     viper.GetInt("microbenchmark.suiteRuns"),
-			V1:            viper.GetString("microbenchmark.v1"),
-			V2:            viper.GetString("microbenchmark.v2"),
-			ExcludeFilter: viper.GetString("microbenchmark.excludeFilter"),
-			IncludeFilter: viper.GetString("microbenchmark.includeFilter"),
-			Functions:     viper.GetStringSlice("microbenchmark.functions"),
-			Outputs:       viper.GetStringSlice("microbenchmark.outputs"),
-			Env:           viper.GetStringSlice("microbenchmark.env"),
+			Name:           viper.GetString("microbenchmark.name"),
+			InstanceType:   microbenchmarkInstanceType,
+			Repository:     viper.GetString("microbenchmark.repository"),
+			Runs:           viper.GetInt("microbenchmark.runs"),
+			SuiteRuns:      viper.GetInt("microbenchmark.suiteRuns"),
+			V1:             viper.GetString("microbenchmark.v1"),
+			V2:             viper.GetString("microbenchmark.v2"),
+			ExcludeFilter:  viper.GetString("microbenchmark.excludeFilter"),
+			IncludeFilter:  viper.GetString("microbenchmark.includeFilter"),
+			Functions:      viper.GetStringSlice("microbenchmark.functions"),
+			Outputs:        viper.GetStringSlice("microbenchmark.outputs"),
+			Env:            viper.GetStringSlice("microbenchmark.env"),
+			MetricsPort:    viper.GetInt("microbenchmark.metricsPort"),
+			QuarantineFile: viper.GetString("microbenchmark.quarantineFile"),
 		},
 		Application: &ConductorApplicationConfig{
-			Name:         viper.GetString("application.name"),
-			InstanceType: applicationInstanceType,
-			Repository:   viper.GetString("application.repository"),
-			V1:           viper.GetString("application.v1"),
-			V2:           viper.GetString("application.v2"),
-			Package:      viper.GetString("application.package"),
-			LogFilter:    viper.GetString("application.logFilter"),
-			Env:          viper.GetStringSlice("application.env"),
-			LimitCPU:     viper.GetBool("application.limitCPU"),
+			Name:                viper.GetString("application.name"),
+			InstanceType:        applicationInstanceType,
+			Repository:          viper.GetString("application.repository"),
+			V1:                  viper.GetString("application.v1"),
+			V2:                  viper.GetString("application.v2"),
+			Versions:            viper.GetStringSlice("application.versions"),
+			PortRange:           viper.GetString("application.portRange"),
+			Package:             viper.GetString("application.package"),
+			LogFilter:           viper.GetString("application.logFilter"),
+			Env:                 viper.GetStringSlice("application.env"),
+			LimitCPU:            viper.GetBool("application.limitCPU"),
+			CgroupStats:         viper.GetStringSlice("application.cgroupStats"),
+			CgroupStatsInterval: viper.GetDuration("application.cgroupStatsInterval"),
+			MemoryEvents:        viper.GetString("application.memoryEvents"),
+			FailOnOOM:           viper.GetBool("application.failOnOOM"),
+			FailOnMemoryHigh:    viper.GetInt("application.failOnMemoryHigh"),
+			GracePeriod:         viper.GetDuration("application.gracePeriod"),
+			ControlPort:         viper.GetInt("application.controlPort"),
 			Benchmark: &ConductorApplicationBenchmarkConfig{
 				InstanceType: applicationBenchmarkInstanceType,
 				Tool:         viper.GetString("application.benchmark.tool"),
@@ -194,10 +405,19 @@ func NewConductorConfig(cmd *cobra.Command) (*ConductorConfig, error) {
 				Reference:    viper.GetString("application.benchmark.reference"),
 				Env:          viper.GetStringSlice("application.benchmark.env"),
 				Output:       viper.GetString("application.benchmark.output"),
+				MetricsPort:  viper.GetInt("application.benchmark.metricsPort"),
+				ProbePath:    viper.GetString("application.benchmark.probePath"),
+				ProbeStatus:  viper.GetInt("application.benchmark.probeStatus"),
+				ProbeTimeout: viper.GetDuration("application.benchmark.probeTimeout"),
+				Liveness:     viper.GetString("application.benchmark.liveness"),
 			},
 		},
 	}
 
+	if err := unmarshalApplicationConfig(c.Application); err != nil {
+		return nil, err
+	}
+
 	if err := c.Validate(); err != nil {
 		return nil, err
 	}
@@ -223,6 +443,33 @@ func NewConductorConfig(cmd *cobra.Command) (*ConductorConfig, error) {
 	return c, nil
 }
 
+// unmarshalApplicationConfig reads the nested application settings that are
+// only configurable in the config file.
+func unmarshalApplicationConfig(c *ConductorApplicationConfig) error {
+	if err := viper.UnmarshalKey("application.build", &c.Build); err != nil {
+		return fmt.Errorf("invalid application build steps: %w", err)
+	}
+	if err := viper.UnmarshalKey("application.run", &c.Run); err != nil {
+		return fmt.Errorf("invalid application run command: %w", err)
+	}
+	if err := viper.UnmarshalKey("application.sidecars", &c.Sidecars); err != nil {
+		return fmt.Errorf("invalid application sidecars: %w", err)
+	}
+	if err := viper.UnmarshalKey("application.resources", &c.Resources); err != nil {
+		return fmt.Errorf("invalid application resources: %w", err)
+	}
+	if err := viper.UnmarshalKey("application.phases", &c.Phases); err != nil {
+		return fmt.Errorf("invalid application phases: %w", err)
+	}
+	if viper.IsSet("application.timeSlicing") {
+		c.TimeSlicing = timeslice.DefaultConfig()
+		if err := viper.UnmarshalKey("application.timeSlicing", c.TimeSlicing); err != nil {
+			return fmt.Errorf("invalid application time slicing: %w", err)
+		}
+	}
+	return nil
+}
+
 func ConductorSetupFlagsAndViper(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringP("config", "c", "", "config file")
 
@@ -246,6 +493,8 @@ func ConductorSetupFlagsAndViper(cmd *cobra.Command) {
 	cmd.PersistentFlags().String("application-repository", "", "repository of the application")
 	cmd.PersistentFlags().String("application-v1", "", "v1 of the application to run")
 	cmd.PersistentFlags().String("application-v2", "", "v2 of the application to run")
+	cmd.PersistentFlags().StringArray("application-version", []string{}, "additional labelled version of the application to run (label=reference)")
+	cmd.PersistentFlags().String("application-port-range", "3000-3010", "range of ports assigned to the application versions")
 	cmd.PersistentFlags().String("application-package", "./", "package that should be build and run")
 	cmd.PersistentFlags().String("application-log-filter", "", "filter application logs")
 	cmd.PersistentFlags().String("application-benchmark-config", "", "application benchmark config")
@@ -257,9 +506,23 @@ func ConductorSetupFlagsAndViper(cmd *cobra.Command) {
 	cmd.PersistentFlags().String("application-instance-type", "", "application instance type")
 	cmd.PersistentFlags().String("application-benchmark-instance-type", "", "application benchmark instance type")
 	cmd.PersistentFlags().Bool("application-limit-cpu", false, "limit application cpu")
+	cmd.PersistentFlags().StringArray("application-cgroup-stats", []string{}, "outputs of the cgroup stats time series of the versions")
+	cmd.PersistentFlags().Duration("application-cgroup-stats-interval", 0, "interval between two cgroup stats samples (default 1s)")
+	cmd.PersistentFlags().String("application-memory-events", "", "output of the memory events of the versions")
+	cmd.PersistentFlags().Bool("application-fail-on-oom", false, "stop the application if a version runs out of memory")
+	cmd.PersistentFlags().Int("application-fail-on-memory-high", 0, "stop the application if a version exceeds memory.high this many times")
+	cmd.PersistentFlags().Duration("application-grace-period", 10*time.Second, "time the versions have to exit after SIGTERM before they are killed")
+	cmd.PersistentFlags().Int("application-control-port", 7000, "localhost port of the application runner control API (0 disables it)")
 	cmd.PersistentFlags().String("application-benchmark-tool", "artillery", "application benchmark tool")
 	cmd.PersistentFlags().StringArray("application-benchmark-env", []string{}, "application benchmark environment variables")
 	cmd.PersistentFlags().StringArray("microbenchmark-env", []string{}, "microbenchmark environment variables")
+	cmd.PersistentFlags().Int("microbenchmark-metrics-port", 0, "port of the microbenchmark runner metrics endpoint (0 disables it)")
+	cmd.PersistentFlags().String("microbenchmark-quarantine-file", "", "quarantine list of flaky functions that are excluded on all instances (see flaky --quarantine)")
+	cmd.PersistentFlags().Int("application-benchmark-metrics-port", 0, "port of the application benchmark runner metrics endpoint (0 disables it)")
+	cmd.PersistentFlags().String("application-benchmark-probe-path", "", "HTTP path of the readiness and liveness probe of the application (empty checks only the TCP port)")
+	cmd.PersistentFlags().Int("application-benchmark-probe-status", 0, "expected HTTP status of the application probe (0 uses the runner default)")
+	cmd.PersistentFlags().Duration("application-benchmark-probe-timeout", 0, "timeout of a single application probe (0 uses the runner default)")
+	cmd.PersistentFlags().String("application-benchmark-liveness", "", "action if an application stops answering during the benchmark [none, flag or abort]")
 
 	cli.Must(viper.BindPFlag("project", cmd.PersistentFlags().Lookup("project")))
 	cli.Must(viper.BindPFlag("region", cmd.PersistentFlags().Lookup("region")))
@@ -281,6 +544,8 @@ func ConductorSetupFlagsAndViper(cmd *cobra.Command) {
 	cli.Must(viper.BindPFlag("application.repository", cmd.PersistentFlags().Lookup("application-repository")))
 	cli.Must(viper.BindPFlag("application.v1", cmd.PersistentFlags().Lookup("application-v1")))
 	cli.Must(viper.BindPFlag("application.v2", cmd.PersistentFlags().Lookup("application-v2")))
+	cli.Must(viper.BindPFlag("application.versions", cmd.PersistentFlags().Lookup("application-version")))
+	cli.Must(viper.BindPFlag("application.portRange", cmd.PersistentFlags().Lookup("application-port-range")))
 	cli.Must(viper.BindPFlag("application.package", cmd.PersistentFlags().Lookup("application-package")))
 	cli.Must(viper.BindPFlag("application.logFilter", cmd.PersistentFlags().Lookup("application-log-filter")))
 	cli.Must(viper.BindPFlag("application.benchmark.config", cmd.PersistentFlags().Lookup("application-benchmark-config")))
@@ -292,7 +557,21 @@ func ConductorSetupFlagsAndViper(cmd *cobra.Command) {
 	cli.Must(viper.BindPFlag("application.instanceType", cmd.PersistentFlags().Lookup("application-instance-type")))
 	cli.Must(viper.BindPFlag("application.benchmark.instanceType", cmd.PersistentFlags().Lookup("application-benchmark-instance-type")))
 	cli.Must(viper.BindPFlag("application.limitCPU", cmd.PersistentFlags().Lookup("application-limit-cpu")))
+	cli.Must(viper.BindPFlag("application.cgroupStats", cmd.PersistentFlags().Lookup("application-cgroup-stats")))
+	cli.Must(viper.BindPFlag("application.cgroupStatsInterval", cmd.PersistentFlags().Lookup("application-cgroup-stats-interval")))
+	cli.Must(viper.BindPFlag("application.memoryEvents", cmd.PersistentFlags().Lookup("application-memory-events")))
+	cli.Must(viper.BindPFlag("application.failOnOOM", cmd.PersistentFlags().Lookup("application-fail-on-oom")))
+	cli.Must(viper.BindPFlag("application.failOnMemoryHigh", cmd.PersistentFlags().Lookup("application-fail-on-memory-high")))
+	cli.Must(viper.BindPFlag("application.gracePeriod", cmd.PersistentFlags().Lookup("application-grace-period")))
+	cli.Must(viper.BindPFlag("application.controlPort", cmd.PersistentFlags().Lookup("application-control-port")))
 	cli.Must(viper.BindPFlag("application.benchmark.tool", cmd.PersistentFlags().Lookup("application-benchmark-tool")))
 	cli.Must(viper.BindPFlag("application.benchmark.env", cmd.PersistentFlags().Lookup("application-benchmark-env")))
 	cli.Must(viper.BindPFlag("microbenchmark.env", cmd.PersistentFlags().Lookup("microbenchmark-env")))
+	cli.Must(viper.BindPFlag("microbenchmark.metricsPort", cmd.PersistentFlags().Lookup("microbenchmark-metrics-port")))
+	cli.Must(viper.BindPFlag("microbenchmark.quarantineFile", cmd.PersistentFlags().Lookup("microbenchmark-quarantine-file")))
+	cli.Must(viper.BindPFlag("application.benchmark.metricsPort", cmd.PersistentFlags().Lookup("application-benchmark-metrics-port")))
+	cli.Must(viper.BindPFlag("application.benchmark.probePath", cmd.PersistentFlags().Lookup("application-benchmark-probe-path")))
+	cli.Must(viper.BindPFlag("application.benchmark.probeStatus", cmd.PersistentFlags().Lookup("application-benchmark-probe-status")))
+	cli.Must(viper.BindPFlag("application.benchmark.probeTimeout", cmd.PersistentFlags().Lookup("application-benchmark-probe-timeout")))
+	cli.Must(viper.BindPFlag("application.benchmark.liveness", cmd.PersistentFlags().Lookup("application-benchmark-liveness")))
 }
@@ -49,7 +49,7 @@ func (a *actionInstallBinary) Run(ctx context.Context, instance gcloud.Instance)
 		return nil
 	}
 
-	err = instance.CopyFile(ctx, a.binary.GetReader(), fmt.Sprintf("/tmp/%s", a.binaryName))
+	err = instance.CopyFile(ctx, a.binary.GetReader(), fmt.Sprintf("/tmp/%s", a.binaryName), "0755")
 	if err != nil {
 		return err
 	}
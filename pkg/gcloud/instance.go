@@ -26,7 +26,8 @@ type Instance interface {
 	RunWithLogger(ctx context.Context, logger LoggerFunc, cmd string) error
 	Run(ctx context.Context, cmd string) (stdout, stderr string, err error)
 	Reconnect(ctx context.Context) error
-	CopyFile(ctx context.Context, data *bytes.Reader, file string) error
+	CopyFile(ctx context.Context, data *bytes.Reader, file, permission string) error
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
 	ExecuteActions(ctx context.Context, actions ...Action) error
 	Close() error
 }
@@ -179,12 +180,22 @@ func (i *instance) Run(ctx context.Context, cmd string) (string, string, error)
 	return stdout.String(), stderr.String(), err
 }
 
-// CopyFile copies a file from a bytes.Reader to a remote instance
-func (i *instance) CopyFile(ctx context.Context, data *bytes.Reader, file string) error {
+// CopyFile copies a file from a bytes.Reader to a remote instance. The
+// permission (e.g. 0644) only applies to new files.
+func (i *instance) CopyFile(ctx context.Context, data *bytes.Reader, file, permission string) error {
 	if err := i.ensureSSHClient(ctx); err != nil {
 		return err
 	}
-	return i.sshClient.CopyFile(ctx, data, file, "0755")
+	return i.sshClient.CopyFile(ctx, data, file, permission)
+}
+
+// DialContext connects to addr from the instance, e.g. to reach ports that are
+// only bound to localhost. The connection is tunneled through SSH.
+func (i *instance) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if err := i.ensureSSHClient(ctx); err != nil {
+		return nil, err
+	}
+	return i.sshClient.Dial(ctx, network, addr)
 }
 
 // ExecuteActions executes a list of actions on the instance
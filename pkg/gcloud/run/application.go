@@ -1,11 +1,19 @@
 package run
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/christophwitzko/masters-thesis/pkg/application"
+	"github.com/christophwitzko/masters-thesis/pkg/application/control"
 	"github.com/christophwitzko/masters-thesis/pkg/assets"
 	"github.com/christophwitzko/masters-thesis/pkg/config"
 	"github.com/christophwitzko/masters-thesis/pkg/gcloud"
@@ -13,25 +21,169 @@ import (
 	"github.com/christophwitzko/masters-thesis/pkg/logger"
 )
 
-func getAppRunnerCmd(appConf *config.ConductorApplicationConfig) string {
+// appCommandsFile is the location of the custom build and run commands on the
+// application instance.
+const appCommandsFile = "/tmp/application-commands.yaml"
+
+// appResourcesFile is the location of the cgroup resource profiles on the
+// application instance.
+const appResourcesFile = "/tmp/application-resources.yaml"
+
+// appControlTokenFile is the location of the control API token on the
+// application instance.
+const appControlTokenFile = "/tmp/application-control-token"
+
+func getAppRunnerCmd(appConf *config.ConductorApplicationConfig) (string, error) {
 	cmd := []string{
 		"application-runner",
-		fmt.Sprintf("--git-repository='%s' --v1='%s' --v2='%s'", appConf.Repository, appConf.V1, appConf.V2),
+		fmt.Sprintf("--git-repository='%s'", appConf.Repository),
 		fmt.Sprintf("--application-package %s", appConf.Package),
 		"--bind 0.0.0.0",
+		fmt.Sprintf("--port-range %s", appConf.PortRange),
+		fmt.Sprintf("--grace-period %s", appConf.GracePeriod),
+	}
+	if appConf.Benchmark.Output != "" {
+		// upload the captured output and exit status of the versions with the
+		// benchmark results
+		resultsOutput, err := applyAppBenchOutputTemplate(appConf, appConf.Benchmark.Output)
+		if err != nil {
+			return "", err
+		}
+		cmd = append(cmd, fmt.Sprintf("--results-output='%s'", resultsOutput))
+	}
+	for _, version := range appConf.VersionRefs() {
+		cmd = append(cmd, fmt.Sprintf("--version='%s'", version))
+	}
+	if appConf.HasCustomCommands() {
+		cmd = append(cmd, fmt.Sprintf("--commands-file %s", appCommandsFile))
 	}
 	for _, env := range appConf.Env {
 		cmd = append(cmd, fmt.Sprintf("--env='%s'", env))
 	}
-	if appConf.LimitCPU {
+	if appConf.Resources != nil {
+		cmd = append(cmd, fmt.Sprintf("--resources-file %s", appResourcesFile))
+	} else if appConf.LimitCPU {
 		cmd = append(cmd, "--limit-cpu")
+	}
+	for _, output := range appConf.CgroupStats {
+		cmd = append(cmd, fmt.Sprintf("--cgroup-stats-output='%s'", output))
+	}
+	if appConf.CgroupStatsInterval > 0 {
+		cmd = append(cmd, fmt.Sprintf("--cgroup-stats-interval %s", appConf.CgroupStatsInterval))
+	}
+	cmd = append(cmd, getMemoryEventArgs(appConf)...)
+	cmd = append(cmd, getTimeSliceArgs(appConf)...)
+	if appConf.ControlPort != 0 {
+		cmd = append(cmd,
+			fmt.Sprintf("--control-address 127.0.0.1:%d", appConf.ControlPort),
+			fmt.Sprintf("--control-token-file %s", appControlTokenFile),
+		)
+	}
+	if appConf.UseCgroups() {
 		cmd = append([]string{"sudo"}, cmd...)
 	}
-	return strings.Join(cmd, " ")
+	return strings.Join(cmd, " "), nil
+}
+
+func getMemoryEventArgs(appConf *config.ConductorApplicationConfig) []string {
+	args := make([]string, 0)
+	if appConf.MemoryEvents != "" {
+		args = append(args, fmt.Sprintf("--memory-events-output='%s'", appConf.MemoryEvents))
+	}
+	if appConf.FailOnOOM {
+		args = append(args, "--fail-on-oom")
+	}
+	if appConf.FailOnMemoryHigh > 0 {
+		args = append(args, fmt.Sprintf("--fail-on-memory-high %d", appConf.FailOnMemoryHigh))
+	}
+	return args
+}
+
+func getTimeSliceArgs(appConf *config.ConductorApplicationConfig) []string {
+	conf := appConf.TimeSlicing
+	if conf == nil {
+		return nil
+	}
+	return []string{
+		"--time-slicing",
+		fmt.Sprintf("--slice-min %s", conf.MinSlice),
+		fmt.Sprintf("--slice-max %s", conf.MaxSlice),
+		fmt.Sprintf("--slice-seed %d", conf.Seed),
+		fmt.Sprintf("--slice-drain-timeout %s", conf.DrainTimeout),
+	}
+}
+
+// copyYAML writes a config file of the application runner to the instance.
+func copyYAML(ctx context.Context, instance gcloud.Instance, write func(io.Writer) error, dst string) error {
+	buf := &bytes.Buffer{}
+	if err := write(buf); err != nil {
+		return err
+	}
+	if err := instance.CopyFile(ctx, bytes.NewReader(buf.Bytes()), dst, "0644"); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", dst, err)
+	}
+	return nil
+}
+
+// copyRunnerFiles copies the custom commands and resource profiles to the
+// instance if they are configured.
+func copyRunnerFiles(ctx context.Context, log *logger.Logger, runnerName string, instance gcloud.Instance, appConf *config.ConductorApplicationConfig) error {
+	if appConf.HasCustomCommands() {
+		log.Infof("[%s] copying build and run commands and sidecars...", runnerName)
+		if err := copyYAML(ctx, instance, appConf.Commands().Write, appCommandsFile); err != nil {
+			return err
+		}
+	}
+	if appConf.Resources != nil {
+		log.Infof("[%s] copying resource profiles...", runnerName)
+		if err := copyYAML(ctx, instance, appConf.Resources.Write, appResourcesFile); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func Application(ctx context.Context, log *logger.Logger, service gcloud.Service, internalIP chan<- string) error {
-	defer close(internalIP)
+// setupControl copies a new random token for the control API to the instance
+// (only readable by the SSH user and root) and returns a client that reaches the API through SSH. It returns nil if the
+// control API is disabled.
+func setupControl(ctx context.Context, instance gcloud.Instance, appConf *config.ConductorApplicationConfig) (*control.Client, error) {
+	if appConf.ControlPort == 0 {
+		return nil, nil
+	}
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+	// remove the token of a previous run, the permission only applies to new
+	// files
+	if _, stderr, err := instance.Run(ctx, fmt.Sprintf("rm -f %s", appControlTokenFile)); err != nil {
+		return nil, fmt.Errorf("failed to remove previous control token: %w (%s)", err, stderr)
+	}
+	if err := instance.CopyFile(ctx, bytes.NewReader([]byte(token)), appControlTokenFile, "0600"); err != nil {
+		return nil, fmt.Errorf("failed to copy control token: %w", err)
+	}
+	httpClient := &http.Client{
+		Transport: &http.Transport{DialContext: instance.DialContext},
+		Timeout:   time.Minute,
+	}
+	return control.NewClient(fmt.Sprintf("http://127.0.0.1:%d", appConf.ControlPort), token, httpClient), nil
+}
+
+// RunningApplication is reported once all versions of the application are
+// built.
+type RunningApplication struct {
+	// Targets are the benchmark targets of the versions (label=internal IP:port).
+	Targets []string
+	// Control is the client of the control API of the application runner or
+	// nil if it is disabled.
+	Control *control.Client
+}
+
+// Application runs all versions of the application and sends the running
+// application as soon as the application runner reports its manifest.
+func Application(ctx context.Context, log *logger.Logger, service gcloud.Service, apps chan<- *RunningApplication) error {
+	defer close(apps)
 	appConf := service.Config().Application
 
 	runnerName := fmt.Sprintf("%s-application", appConf.Name)
@@ -59,13 +211,46 @@ func Application(ctx context.Context, log *logger.Logger, service gcloud.Service
 	if err != nil {
 		return err
 	}
-	internalIP <- instance.InternalIP()
-	cmd := getAppRunnerCmd(appConf)
+	if err := copyRunnerFiles(ctx, log, runnerName, instance, appConf); err != nil {
+		return err
+	}
+	controlClient, err := setupControl(ctx, instance, appConf)
+	if err != nil {
+		return err
+	}
+	cmd, err := getAppRunnerCmd(appConf)
+	if err != nil {
+		return err
+	}
 	log.Infof("[%s] running: %s", runnerName, cmd)
-	return instance.RunWithLogger(ctx, func(stdout, stderr string) {
+	logFn := manifestLogger(log, runnerName, instance.InternalIP(), controlClient, apps, func(stdout, stderr string) {
 		if logFilterRe != nil && (logFilterRe.MatchString(stderr) || logFilterRe.MatchString(stdout)) {
 			return
 		}
 		log.Infof("[%s] %s%s", runnerName, stdout, stderr)
-	}, cmd)
+	})
+	return instance.RunWithLogger(ctx, logFn, cmd)
+}
+
+// manifestLogger sends the running application of the first manifest reported
+// by the application runner and passes all other output to next.
+func manifestLogger(log *logger.Logger, runnerName, internalIP string, controlClient *control.Client, apps chan<- *RunningApplication, next gcloud.LoggerFunc) gcloud.LoggerFunc {
+	manifestReceived := false
+	return func(stdout, stderr string) {
+		if manifestReceived {
+			next(stdout, stderr)
+			return
+		}
+		manifest, found, err := application.ParseManifestLine(stdout)
+		if err != nil {
+			log.Errorf("[%s] %s", runnerName, err)
+		}
+		if manifest != nil {
+			manifestReceived = true
+			apps <- &RunningApplication{Targets: manifest.Targets(internalIP), Control: controlClient}
+		}
+		if !found {
+			next(stdout, stderr)
+		}
+	}
 }
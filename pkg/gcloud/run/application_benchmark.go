@@ -39,11 +39,18 @@ func applyAppBenchOutputTemplate(appConf *config.ConductorApplicationConfig, tmp
 	return buf.String(), nil
 }
 
-func getAppBenchRunnerCmd(timeout time.Duration, appConf *config.ConductorApplicationConfig, targets []string) (string, error) {
+// getAppBenchRunnerCmd returns the command of the benchmark or of a phase of
+// it if phase is set.
+func getAppBenchRunnerCmd(timeout time.Duration, appConf *config.ConductorApplicationConfig, targets []string, phase *config.ConductorApplicationPhase) (string, error) {
 	resultsOutput, err := applyAppBenchOutputTemplate(appConf, appConf.Benchmark.Output)
 	if err != nil {
 		return "", err
 	}
+	env := appConf.Benchmark.Env
+	if phase != nil {
+		resultsOutput = strings.TrimSuffix(resultsOutput, "/") + "/" + phase.Name
+		env = append(append([]string{}, env...), phase.Env...)
+	}
 	cmd := []string{
 		"application-benchmark-runner",
 		fmt.Sprintf("--git-repository='%s' --reference='%s'", appConf.Repository, appConf.Benchmark.Reference),
@@ -59,13 +66,36 @@ func getAppBenchRunnerCmd(timeout time.Duration, appConf *config.ConductorApplic
 	for _, target := range targets {
 		cmd = append(cmd, fmt.Sprintf("--target='%s'", target))
 	}
-	for _, env := range appConf.Benchmark.Env {
-		cmd = append(cmd, fmt.Sprintf("--env='%s'", env))
+	for _, e := range env {
+		cmd = append(cmd, fmt.Sprintf("--env='%s'", e))
+	}
+	if appConf.Benchmark.MetricsPort != 0 {
+		cmd = append(cmd, fmt.Sprintf("--metrics-port=%d", appConf.Benchmark.MetricsPort))
 	}
+	cmd = append(cmd, getProbeArgs(appConf.Benchmark)...)
 	return strings.Join(cmd, " "), nil
 }
 
-func ApplicationBenchmark(ctx context.Context, log *logger.Logger, service gcloud.Service, targets []string) error {
+func getProbeArgs(benchConf *config.ConductorApplicationBenchmarkConfig) []string {
+	args := make([]string, 0)
+	if benchConf.ProbePath != "" {
+		args = append(args, fmt.Sprintf("--probe-path='%s'", benchConf.ProbePath))
+	}
+	if benchConf.ProbeStatus != 0 {
+		args = append(args, fmt.Sprintf("--probe-status=%d", benchConf.ProbeStatus))
+	}
+	if benchConf.ProbeTimeout != 0 {
+		args = append(args, fmt.Sprintf("--probe-timeout=%s", benchConf.ProbeTimeout))
+	}
+	if benchConf.Liveness != "" {
+		args = append(args, fmt.Sprintf("--liveness=%s", benchConf.Liveness))
+	}
+	return args
+}
+
+// ApplicationBenchmark runs the benchmark against the targets. If phase is
+// set, only this phase of the benchmark is run.
+func ApplicationBenchmark(ctx context.Context, log *logger.Logger, service gcloud.Service, targets []string, phase *config.ConductorApplicationPhase) error {
 	conf := service.Config()
 	appConf := conf.Application
 	runnerName := fmt.Sprintf("%s-application-benchmark", appConf.Name)
@@ -92,7 +122,7 @@ func ApplicationBenchmark(ctx context.Context, log *logger.Logger, service gclou
 	if err != nil {
 		return err
 	}
-	cmd, err := getAppBenchRunnerCmd(conf.Timeout, appConf, targets)
+	cmd, err := getAppBenchRunnerCmd(conf.Timeout, appConf, targets, phase)
 	if err != nil {
 		return err
 	}
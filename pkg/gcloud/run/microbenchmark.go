@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"strings"
 	"text/template"
 	"time"
@@ -15,6 +16,10 @@ import (
 	"github.com/christophwitzko/masters-thesis/pkg/logger"
 )
 
+// mbQuarantineFile is the location of the quarantine list on the
+// microbenchmark instances.
+const mbQuarantineFile = "/tmp/microbenchmark-quarantine.txt"
+
 type mbTmplData struct {
 	Timestamp string
 	Name      string
@@ -70,6 +75,12 @@ func getMbRunnerCmd(timeout time.Duration, mbConf *config.ConductorMicrobenchmar
 	for _, env := range mbConf.Env {
 		cmd = append(cmd, fmt.Sprintf("--env='%s'", env))
 	}
+	if mbConf.MetricsPort != 0 {
+		cmd = append(cmd, fmt.Sprintf("--metrics-port=%d", mbConf.MetricsPort))
+	}
+	if mbConf.QuarantineFile != "" {
+		cmd = append(cmd, fmt.Sprintf("--quarantine-file %s", mbQuarantineFile))
+	}
 	return strings.Join(cmd, " "), nil
 }
 
@@ -94,6 +105,16 @@ func Microbenchmark(ctx context.Context, log *logger.Logger, service gcloud.Serv
 	if err != nil {
 		return err
 	}
+	if mbConf.QuarantineFile != "" {
+		log.Infof("[%s] copying quarantine list...", runnerName)
+		quarantine, err := os.ReadFile(mbConf.QuarantineFile)
+		if err != nil {
+			return fmt.Errorf("failed to read quarantine file: %w", err)
+		}
+		if err := instance.CopyFile(ctx, bytes.NewReader(quarantine), mbQuarantineFile, "0644"); err != nil {
+			return fmt.Errorf("failed to copy quarantine file: %w", err)
+		}
+	}
 	cmd, err := getMbRunnerCmd(conf.Timeout, mbConf, runIndex)
 	if err != nil {
 		return err
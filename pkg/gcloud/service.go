@@ -8,6 +8,7 @@ import (
 	"fmt"
 	computepb "google.golang.org/genproto/googleapis/cloud/compute/v1"
 	resourcemanagerpb "google.golang.org/genproto/googleapis/cloud/resourcemanager/v3"
+	"strconv"
 	"strings"
 
 	"github.com/christophwitzko/masters-thesis/pkg/config"
@@ -233,14 +234,52 @@ func (s *service) GetOrCreateInstance(ctx context.Context, name, instanceType st
 	return s.GetInstance(ctx, name)
 }
 
+// firewallPorts returns the ports that are opened for the instances: SSH, the
+// application ports and the configured metrics ports.
+func (s *service) firewallPorts() []string {
+	// TODO: remove application ports after testing and use internal networking
+	ports := []string{"22", "3000-3010"}
+	metricsPorts := make([]int, 0, 2)
+	if s.config.Microbenchmark != nil {
+		metricsPorts = append(metricsPorts, s.config.Microbenchmark.MetricsPort)
+	}
+	if s.config.Application != nil && s.config.Application.Benchmark != nil {
+		metricsPorts = append(metricsPorts, s.config.Application.Benchmark.MetricsPort)
+	}
+	for _, metricsPort := range metricsPorts {
+		port := strconv.Itoa(metricsPort)
+		if metricsPort > 0 && !containsString(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
 func (s *service) EnsureFirewallRules(ctx context.Context) error {
-	_, err := s.firewallClient.Get(ctx, &computepb.GetFirewallRequest{
+	ports := s.firewallPorts()
+	allowed := []*computepb.Allowed{{
+		IPProtocol: proto.String("tcp"),
+		Ports:      ports,
+	}}
+	firewall, err := s.firewallClient.Get(ctx, &computepb.GetFirewallRequest{
 		Project:  s.config.Project,
 		Firewall: toolName,
 	})
 	if err == nil {
-		// firewall already exists, nothing to do
-		return nil
+		if hasAllowedPorts(firewall, ports) {
+			// firewall already exists, nothing to do
+			return nil
+		}
+		// open the newly configured ports (e.g. metrics ports)
+		patchOp, err := s.firewallClient.Patch(ctx, &computepb.PatchFirewallRequest{
+			Project:          s.config.Project,
+			Firewall:         toolName,
+			FirewallResource: &computepb.Firewall{Allowed: allowed},
+		})
+		if err != nil {
+			return err
+		}
+		return patchOp.Wait(ctx)
 	}
 	var gErr *googleapi.Error
 	if !errors.As(err, &gErr) || gErr.Code != 404 {
@@ -250,16 +289,12 @@ func (s *service) EnsureFirewallRules(ctx context.Context) error {
 	insertOp, err := s.firewallClient.Insert(ctx, &computepb.InsertFirewallRequest{
 		Project: s.config.Project,
 		FirewallResource: &computepb.Firewall{
-			Name:       proto.String(toolName),
-			Network:    proto.String("global/networks/default"),
-			Direction:  proto.String(computepb.Firewall_INGRESS.String()),
-			Priority:   proto.Int32(1000),
-			TargetTags: []string{toolName},
-			Allowed: []*computepb.Allowed{{
-				IPProtocol: proto.String("tcp"),
-				// TODO: remove application ports after testing and use internal networking
-				Ports: []string{"22", "3000-3010"},
-			}},
+			Name:         proto.String(toolName),
+			Network:      proto.String("global/networks/default"),
+			Direction:    proto.String(computepb.Firewall_INGRESS.String()),
+			Priority:     proto.Int32(1000),
+			TargetTags:   []string{toolName},
+			Allowed:      allowed,
 			SourceRanges: []string{"0.0.0.0/0"},
 		},
 	})
@@ -269,6 +304,32 @@ func (s *service) EnsureFirewallRules(ctx context.Context) error {
 	return insertOp.Wait(ctx)
 }
 
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAllowedPorts reports whether the firewall allows all TCP ports.
+func hasAllowedPorts(firewall *computepb.Firewall, ports []string) bool {
+	for _, port := range ports {
+		found := false
+		for _, allowed := range firewall.GetAllowed() {
+			if allowed.GetIPProtocol() == "tcp" && containsString(allowed.GetPorts(), port) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *service) DeleteInstance(ctx context.Context, instanceName string) error {
 	delOp, err := s.instancesClient.Delete(ctx, &computepb.DeleteInstanceRequest{
 		Project:  s.config.Project,
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net"
 	"sync"
 
 	"github.com/bramvdbogaerde/go-scp"
@@ -128,3 +129,28 @@ func (c *sshClient) CopyFile(ctx context.Context, data *bytes.Reader, remotePath
 	defer scpClient.Close()
 	return scpClient.Copy(ctx, data, remotePath, permission, data.Size())
 }
+
+// Dial opens a connection to addr from the remote host through the ssh
+// connection.
+func (c *sshClient) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	resultCh := make(chan dialResult, 1)
+	go func() {
+		conn, err := c.sshClient.Dial(network, addr)
+		resultCh <- dialResult{conn, err}
+	}()
+	select {
+	case <-ctx.Done():
+		go func() {
+			if res := <-resultCh; res.conn != nil {
+				_ = res.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	case res := <-resultCh:
+		return res.conn, res.err
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
 )
 
 func NewObjectWriter(ctx context.Context, bucketName, objectName string) (*storage.Writer, *storage.Client, error) {
@@ -33,6 +34,48 @@ func NewObjectWriter(ctx context.Context, bucketName, objectName string) (*stora
 	return objectWriter, client, nil
 }
 
+func NewObjectReader(ctx context.Context, bucketName, objectName string) (*storage.Reader, *storage.Client, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objectName = strings.TrimPrefix(objectName, "/")
+	objectReader, err := client.Bucket(bucketName).Object(objectName).NewReader(ctx)
+	if err != nil {
+		_ = client.Close()
+		return nil, nil, fmt.Errorf("failed to read object %s: %w", objectName, err)
+	}
+	return objectReader, client, nil
+}
+
+// ListObjects returns the names of all objects in the bucket starting with the given prefix.
+func ListObjects(ctx context.Context, bucketName, prefix string) ([]string, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	prefix = strings.TrimPrefix(prefix, "/")
+	objectNames := make([]string, 0)
+	it := client.Bucket(bucketName).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, storage.ErrBucketNotExist) {
+				return nil, fmt.Errorf("bucket %s does not exist", bucketName)
+			}
+			return nil, err
+		}
+		objectNames = append(objectNames, attrs.Name)
+	}
+	return objectNames, nil
+}
+
 func UploadToBucket(ctx context.Context, bucketName, objectName string, r io.Reader) error {
 	objectWriter, client, err := NewObjectWriter(ctx, bucketName, objectName)
 	if err != nil {
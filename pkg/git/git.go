@@ -94,6 +94,19 @@ func Clone(repoURL string, destinationDirs ...string) ([]*git.Repository, error)
 	return repos, nil
 }
 
+// HeadCommit returns the commit hash currently checked out in the given repository.
+func HeadCommit(repoPath string) (string, error) {
+	repo, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
 type CheckoutOption struct {
 	DestinationDir string
 	RefName        string
@@ -0,0 +1,118 @@
+package hostinfo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Info struct {
+	Hostname     string
+	Kernel       string
+	OS           string
+	Arch         string
+	CPUModel     string
+	CPUCount     int
+	MemoryTotal  uint64 // bytes
+	InstanceType string
+	Zone         string
+}
+
+// Collect gathers information about the host the process is running on.
+// Values that cannot be determined (e.g. outside a cloud instance) are left empty.
+func Collect(ctx context.Context) Info {
+	hostname, _ := os.Hostname()
+	info := Info{
+		Hostname: hostname,
+		Kernel:   readKernelRelease(),
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		CPUModel: readProcValue("/proc/cpuinfo", "model name"),
+		CPUCount: runtime.NumCPU(),
+	}
+	memTotal := strings.TrimSuffix(readProcValue("/proc/meminfo", "MemTotal"), " kB")
+	if kb, err := strconv.ParseUint(memTotal, 10, 64); err == nil {
+		info.MemoryTotal = kb * 1024
+	}
+	// e.g. projects/123456/machineTypes/n2-highcpu-4
+	if machineType, err := fetchInstanceMetadata(ctx, "machine-type"); err == nil {
+		info.InstanceType = path.Base(machineType)
+	}
+	if zone, err := fetchInstanceMetadata(ctx, "zone"); err == nil {
+		info.Zone = path.Base(zone)
+	}
+	return info
+}
+
+func readKernelRelease() string {
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(release))
+}
+
+// readProcValue returns the value of the first "key: value" line of the given file.
+func readProcValue(fileName, key string) string {
+	procFile, err := os.Open(fileName)
+	if err != nil {
+		return ""
+	}
+	defer procFile.Close()
+	scanner := bufio.NewScanner(procFile)
+	for scanner.Scan() {
+		lineKey, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.TrimSpace(lineKey) == key {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+var metadataClient = &http.Client{Timeout: time.Second}
+
+func fetchInstanceMetadata(ctx context.Context, key string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://metadata.google.internal/computeMetadata/v1/instance/"+key, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := metadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected %d status code while fetching instance metadata", res.StatusCode)
+	}
+	value, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// GoEnv returns the environment of the go toolchain that is used to run the benchmarks.
+func GoEnv(ctx context.Context, env []string) (map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "go", "env", "-json")
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	goEnv := make(map[string]string)
+	if err := json.Unmarshal(out, &goEnv); err != nil {
+		return nil, err
+	}
+	return goEnv, nil
+}
@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/netutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	TargetStatusWaiting  = "waiting"
+	TargetStatusRunning  = "running"
+	TargetStatusFinished = "finished"
+	TargetStatusFailed   = "failed"
+)
+
+var targetStatuses = []string{TargetStatusWaiting, TargetStatusRunning, TargetStatusFinished, TargetStatusFailed}
+
+var (
+	// e.g. "http.request_rate: ......... 50/sec" or "http.codes.200: ......... 500"
+	artilleryMetricRegexp = regexp.MustCompile(`^\s*(http\.[\w.]+):\s*\.*\s*([\d.]+)(/sec)?\s*$`)
+	// e.g. "running (0m10.0s), 10/10 VUs, 1234 complete and 0 interrupted iterations"
+	k6ProgressRegexp = regexp.MustCompile(`^running \(([\dhms.]+)\), \d+/\d+ VUs, (\d+) complete and (\d+) interrupted iterations`)
+)
+
+// ApplicationBenchmarkMetrics tracks the status of the targets and the
+// request rates reported by the load generator.
+type ApplicationBenchmarkMetrics struct {
+	targetStatus  *prometheus.GaugeVec
+	targetUp      *prometheus.GaugeVec
+	requestRate   *prometheus.GaugeVec
+	requests      *prometheus.CounterVec
+	responses     *prometheus.CounterVec
+	iterations    *prometheus.GaugeVec
+	iterationRate *prometheus.GaugeVec
+}
+
+func NewApplicationBenchmarkMetrics(registry prometheus.Registerer) *ApplicationBenchmarkMetrics {
+	m := &ApplicationBenchmarkMetrics{
+		targetStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "application_benchmark", Name: "target_status",
+			Help: "Set to 1 for the current status of the benchmark against a target.",
+		}, []string{"target", "status"}),
+		targetUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "application_benchmark", Name: "target_up",
+			Help: "Whether the target endpoint accepts connections.",
+		}, []string{"target"}),
+		requestRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "application_benchmark", Name: "request_rate",
+			Help: "Latest request rate (requests per second) reported by artillery.",
+		}, []string{"target"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "application_benchmark", Name: "requests_total",
+			Help: "Amount of requests reported by artillery.",
+		}, []string{"target"}),
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "application_benchmark", Name: "responses_total",
+			Help: "Amount of responses by status code reported by artillery.",
+		}, []string{"target", "code"}),
+		iterations: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "application_benchmark", Name: "iterations",
+			Help: "Amount of completed iterations reported by k6.",
+		}, []string{"target"}),
+		iterationRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "application_benchmark", Name: "iteration_rate",
+			Help: "Latest iteration rate (iterations per second) reported by k6.",
+		}, []string{"target"}),
+	}
+	registry.MustRegister(m.targetStatus, m.targetUp, m.requestRate, m.requests, m.responses, m.iterations, m.iterationRate)
+	return m
+}
+
+func (m *ApplicationBenchmarkMetrics) SetTargetStatus(target, status string) {
+	for _, s := range targetStatuses {
+		value := 0.0
+		if s == status {
+			value = 1
+		}
+		m.targetStatus.WithLabelValues(target, s).Set(value)
+	}
+}
+
+// MonitorTarget periodically checks if the target endpoint accepts
+// connections until the context is done.
+func (m *ApplicationBenchmarkMetrics) MonitorTarget(ctx context.Context, target, endpoint string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		up := 0.0
+		if netutil.IsPortOpen(endpoint, interval) {
+			up = 1
+		}
+		m.targetUp.WithLabelValues(target).Set(up)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// OutputObserver returns a function that parses the output lines of the
+// benchmark tool (artillery or k6) and updates the request rate metrics.
+func (m *ApplicationBenchmarkMetrics) OutputObserver(target string) func(line string) {
+	var mu sync.Mutex
+	summary := false
+	var lastIterations float64
+	var lastElapsed time.Duration
+	return func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		// the artillery summary report repeats the totals of all periods
+		if strings.HasPrefix(line, "Summary report") {
+			summary = true
+			return
+		}
+		if strings.HasPrefix(line, "Metrics for period to") {
+			summary = false
+			return
+		}
+		if matches := k6ProgressRegexp.FindStringSubmatch(line); matches != nil {
+			elapsed, err := time.ParseDuration(matches[1])
+			if err != nil {
+				return
+			}
+			iterations, _ := strconv.ParseFloat(matches[2], 64)
+			m.iterations.WithLabelValues(target).Set(iterations)
+			if elapsed > lastElapsed {
+				rate := (iterations - lastIterations) / (elapsed - lastElapsed).Seconds()
+				m.iterationRate.WithLabelValues(target).Set(rate)
+			}
+			lastIterations, lastElapsed = iterations, elapsed
+			return
+		}
+		if summary {
+			return
+		}
+		matches := artilleryMetricRegexp.FindStringSubmatch(line)
+		if matches == nil {
+			return
+		}
+		value, err := strconv.ParseFloat(matches[2], 64)
+		if err != nil {
+			return
+		}
+		switch {
+		case matches[1] == "http.request_rate":
+			m.requestRate.WithLabelValues(target).Set(value)
+		case matches[1] == "http.requests":
+			m.requests.WithLabelValues(target).Add(value)
+		case strings.HasPrefix(matches[1], "http.codes."):
+			m.responses.WithLabelValues(target, strings.TrimPrefix(matches[1], "http.codes.")).Add(value)
+		}
+	}
+}
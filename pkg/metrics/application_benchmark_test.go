@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputObserver(t *testing.T) {
+	m := NewApplicationBenchmarkMetrics(prometheus.NewRegistry())
+	observe := m.OutputObserver("v1")
+	for _, line := range []string{
+		"Metrics for period to: 12:00:10(+0000) (width: 9.5s)",
+		"http.codes.200: ................................................................ 48",
+		"http.codes.500: ................................................................ 2",
+		"http.request_rate: ............................................................. 5/sec",
+		"http.requests: ................................................................. 50",
+		"Metrics for period to: 12:00:20(+0000) (width: 9.9s)",
+		"http.request_rate: ............................................................. 7/sec",
+		"http.requests: ................................................................. 70",
+		"Summary report @ 12:00:21(+0000)",
+		"http.requests: ................................................................. 120",
+		"running (0m10.0s), 10/10 VUs, 100 complete and 0 interrupted iterations",
+		"running (0m12.0s), 10/10 VUs, 150 complete and 0 interrupted iterations",
+	} {
+		observe(line)
+	}
+	require.Equal(t, 120.0, testutil.ToFloat64(m.requests.WithLabelValues("v1")))
+	require.Equal(t, 7.0, testutil.ToFloat64(m.requestRate.WithLabelValues("v1")))
+	require.Equal(t, 48.0, testutil.ToFloat64(m.responses.WithLabelValues("v1", "200")))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.responses.WithLabelValues("v1", "500")))
+	require.Equal(t, 150.0, testutil.ToFloat64(m.iterations.WithLabelValues("v1")))
+	require.Equal(t, 25.0, testutil.ToFloat64(m.iterationRate.WithLabelValues("v1")))
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cbc"
+
+// NewRegistry creates a registry that already contains the go runtime and
+// process collectors.
+func NewRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
+// Serve exposes the metrics of the registry in the Prometheus and OpenMetrics
+// text format on http://0.0.0.0:<port>/metrics until the context is done.
+func Serve(ctx context.Context, log *logger.Logger, port int, registry *prometheus.Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	}))
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	log.Infof("|metrics| serving metrics on %s/metrics", server.Addr)
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Start serves the metrics in the background. Errors are only logged as the
+// metrics endpoint should never interrupt a benchmark.
+func Start(ctx context.Context, log *logger.Logger, port int, registry *prometheus.Registry) {
+	go func() {
+		if err := Serve(ctx, log, port, registry); err != nil {
+			log.Errorf("|metrics| failed to serve metrics: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MicrobenchmarkMetrics tracks the progress and the latest results of a
+// microbenchmark runner.
+type MicrobenchmarkMetrics struct {
+	run             prometheus.Gauge
+	suite           prometheus.Gauge
+	suiteRuns       prometheus.Gauge
+	functions       prometheus.Gauge
+	currentFunction *prometheus.GaugeVec
+	trials          *prometheus.CounterVec
+	failures        *prometheus.CounterVec
+	latestSecPerOp  *prometheus.GaugeVec
+}
+
+func NewMicrobenchmarkMetrics(registry prometheus.Registerer) *MicrobenchmarkMetrics {
+	m := &MicrobenchmarkMetrics{
+		run: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "microbenchmark", Name: "run",
+			Help: "Run index of the microbenchmark runner.",
+		}),
+		suite: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "microbenchmark", Name: "suite",
+			Help: "Index of the currently executed suite run.",
+		}),
+		suiteRuns: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "microbenchmark", Name: "suite_runs",
+			Help: "Total amount of suite runs.",
+		}),
+		functions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "microbenchmark", Name: "functions",
+			Help: "Amount of benchmark functions per suite run.",
+		}),
+		currentFunction: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "microbenchmark", Name: "current_function",
+			Help: "Set to 1 for the benchmark function that is currently executed.",
+		}, []string{"function", "version"}),
+		trials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "microbenchmark", Name: "trials_total",
+			Help: "Amount of completed benchmark trials.",
+		}, []string{"version"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "microbenchmark", Name: "failures_total",
+			Help: "Amount of failed benchmark function executions.",
+		}, []string{"function", "version"}),
+		latestSecPerOp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "microbenchmark", Name: "latest_sec_per_op",
+			Help: "Latest measured seconds per operation of a benchmark function.",
+		}, []string{"function", "version"}),
+	}
+	registry.MustRegister(m.run, m.suite, m.suiteRuns, m.functions, m.currentFunction, m.trials, m.failures, m.latestSecPerOp)
+	return m
+}
+
+// SetRun records the static parameters of the runner.
+func (m *MicrobenchmarkMetrics) SetRun(run, suiteRuns, functions int) {
+	m.run.Set(float64(run))
+	m.suiteRuns.Set(float64(suiteRuns))
+	m.functions.Set(float64(functions))
+}
+
+// ResultWriter wraps the given writer and updates the metrics for each
+// written result.
+func (m *MicrobenchmarkMetrics) ResultWriter(w microbenchmark.ResultWriter) microbenchmark.ResultWriter {
+	return &metricsResultWriter{
+		ResultWriter: w,
+		metrics:      m,
+	}
+}
+
+type metricsResultWriter struct {
+	microbenchmark.ResultWriter
+	metrics *MicrobenchmarkMetrics
+}
+
+func (w *metricsResultWriter) Write(result microbenchmark.Result) error {
+	if err := w.ResultWriter.Write(result); err != nil {
+		return err
+	}
+	version := strconv.Itoa(result.Version)
+	w.metrics.trials.WithLabelValues(version).Inc()
+	w.metrics.latestSecPerOp.WithLabelValues(result.Function.String(), version).Set(result.Ops)
+	return nil
+}
+
+func (w *metricsResultWriter) FunctionStarted(f microbenchmark.Function, version, _, suite int) {
+	w.metrics.suite.Set(float64(suite))
+	w.metrics.currentFunction.Reset()
+	w.metrics.currentFunction.WithLabelValues(f.String(), strconv.Itoa(version)).Set(1)
+}
+
+func (w *metricsResultWriter) FunctionFinished(f microbenchmark.Function, version, _, _ int, err error) {
+	w.metrics.currentFunction.Reset()
+	if err != nil {
+		w.metrics.failures.WithLabelValues(f.String(), strconv.Itoa(version)).Inc()
+	}
+}
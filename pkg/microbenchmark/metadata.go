@@ -0,0 +1,30 @@
+package microbenchmark
+
+import (
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/hostinfo"
+)
+
+type VersionInfo struct {
+	Reference  string // git reference or source path
+	Commit     string // resolved commit hash, empty if the source is not a git repository
+	SourcePath string
+}
+
+// Metadata describes the environment and configuration a set of results was recorded with.
+type Metadata struct {
+	Runner     string // build info of the microbenchmark runner
+	Repository string
+	V1, V2     VersionInfo
+	RunIndex   int
+	SuiteRuns  int
+	Seed       int64
+	Functions  []string
+	Host       hostinfo.Info
+	GoVersion  string // version of the go toolchain running the benchmarks
+	GoEnv      map[string]string
+	Config     map[string]string // runner flags
+	StartTime  time.Time
+	EndTime    time.Time // zero while the run is in progress
+}
@@ -0,0 +1,151 @@
+package output
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/klauspost/compress/zstd"
+)
+
+type CompressorFactory func(w io.Writer) (io.WriteCloser, error)
+
+type DecompressorFactory func(r io.Reader) (io.ReadCloser, error)
+
+type compression struct {
+	Extension    string
+	Compressor   CompressorFactory
+	Decompressor DecompressorFactory
+}
+
+var compressions = map[string]compression{
+	"gzip": {
+		Extension: ".gz",
+		Compressor: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		Decompressor: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	"zstd": {
+		Extension: ".zst",
+		Compressor: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+		Decompressor: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		},
+	},
+}
+
+func IsValidCompression(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, ok := compressions[name]
+	return ok
+}
+
+// CompressionExtension returns the file extension (including the ".") that is
+// appended to output paths using the given compression.
+func CompressionExtension(name string) string {
+	return compressions[name].Extension
+}
+
+// CompressionFromPath returns the compression of a file based on its extension
+// and the path without the compression extension.
+func CompressionFromPath(p string) (string, string) {
+	for name, c := range compressions {
+		if strings.HasSuffix(p, c.Extension) {
+			return name, strings.TrimSuffix(p, c.Extension)
+		}
+	}
+	return "", p
+}
+
+type compressedWriter struct {
+	compressor io.WriteCloser
+	writer     io.WriteCloser
+}
+
+// NewCompressedWriter wraps the writer with the given compression. Closing the
+// returned writer flushes the compressor and closes the underlying writer.
+func NewCompressedWriter(name string, w io.WriteCloser) (io.WriteCloser, error) {
+	if name == "" {
+		return w, nil
+	}
+	c, ok := compressions[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression: %s", name)
+	}
+	compressor, err := c.Compressor(w)
+	if err != nil {
+		return nil, err
+	}
+	return &compressedWriter{
+		compressor: compressor,
+		writer:     w,
+	}, nil
+}
+
+func (c *compressedWriter) Write(p []byte) (n int, err error) {
+	return c.compressor.Write(p)
+}
+
+func (c *compressedWriter) Close() error {
+	var mErr error
+	if err := c.compressor.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	if err := c.writer.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	return mErr
+}
+
+type decompressedReader struct {
+	decompressor io.ReadCloser
+	reader       io.ReadCloser
+}
+
+// NewDecompressedReader wraps the reader with the given compression. Closing the
+// returned reader closes the decompressor and the underlying reader.
+func NewDecompressedReader(name string, r io.ReadCloser) (io.ReadCloser, error) {
+	if name == "" {
+		return r, nil
+	}
+	c, ok := compressions[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression: %s", name)
+	}
+	decompressor, err := c.Decompressor(r)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressedReader{
+		decompressor: decompressor,
+		reader:       r,
+	}, nil
+}
+
+func (d *decompressedReader) Read(p []byte) (n int, err error) {
+	return d.decompressor.Read(p)
+}
+
+func (d *decompressedReader) Close() error {
+	var mErr error
+	if err := d.decompressor.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	if err := d.reader.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	return mErr
+}
@@ -0,0 +1,96 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+)
+
+type DecoderFunc func(r io.Reader) (microbenchmark.Results, error)
+
+var decoders = map[string]DecoderFunc{
+	"json": decodeJSONResults,
+	"csv":  decodeCSVResults,
+}
+
+func NewDecoder(outputType string) (DecoderFunc, error) {
+	decoder, ok := decoders[outputType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported input type: %s", outputType)
+	}
+	return decoder, nil
+}
+
+// decodeCSVResults decodes the records by the columns of the last header.
+// Records without a preceding header must contain all fields, therefore
+// projected outputs (fields parameter) need the CSV header.
+func decodeCSVResults(r io.Reader) (microbenchmark.Results, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	results := make(microbenchmark.Results, 0)
+	// nil columns use the full record layout
+	var columns []resultField
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// chunks may or may not start with a header
+		if header, ok := csvFields(record); ok {
+			columns = header
+			continue
+		}
+		result, err := parseCSVRecord(columns, record)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func parseCSVRecord(columns []resultField, record []string) (microbenchmark.Result, error) {
+	if columns == nil {
+		return microbenchmark.ParseRecord(record)
+	}
+	if len(record) != len(columns) {
+		return microbenchmark.Result{}, fmt.Errorf("invalid record length: %d (expected %d)", len(record), len(columns))
+	}
+	var result microbenchmark.Result
+	for i, field := range columns {
+		if err := field.ParseCSV(&result, record[i]); err != nil {
+			return microbenchmark.Result{}, fmt.Errorf("invalid %s %s: %w", field.CSVHeader, record[i], err)
+		}
+	}
+	return result, nil
+}
+
+func decodeJSONResults(r io.Reader) (microbenchmark.Results, error) {
+	jsonDecoder := json.NewDecoder(r)
+	results := make(microbenchmark.Results, 0)
+	for {
+		var record struct {
+			metadataHeader
+			microbenchmark.Result
+		}
+		err := jsonDecoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record.Metadata != nil {
+			continue
+		}
+		results = append(results, record.Result)
+	}
+	return results, nil
+}
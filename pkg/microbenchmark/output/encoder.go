@@ -10,6 +10,11 @@ type ResultEncoder interface {
 	Encode(result microbenchmark.Result) ([]byte, error)
 }
 
+// chunkStarter is implemented by encoders that start each chunk with a header.
+type chunkStarter interface {
+	StartChunk()
+}
+
 type EncoderFactory func(config *Output) (ResultEncoder, error)
 
 var encoders = map[string]EncoderFactory{
@@ -11,6 +11,8 @@ type csvResultEncoder struct {
 	buffer           *bytes.Buffer
 	csvWriter        *csv.Writer
 	hasWrittenHeader bool
+	noHeader         bool
+	fields           []resultField
 }
 
 func newCsvResultEncoder(config *Output) (ResultEncoder, error) {
@@ -23,22 +25,52 @@ func newCsvResultEncoder(config *Output) (ResultEncoder, error) {
 		buffer:           buffer,
 		csvWriter:        csv.NewWriter(buffer),
 		hasWrittenHeader: noCSVHeader,
+		noHeader:         noCSVHeader,
+		fields:           config.fields,
 	}
 	return csvEncoder, nil
 }
 
+// StartChunk writes the header again at the start of the next chunk, so that
+// each chunk can be read on its own.
+func (c *csvResultEncoder) StartChunk() {
+	c.hasWrittenHeader = c.noHeader
+}
+
 func (c *csvResultEncoder) Encode(result microbenchmark.Result) ([]byte, error) {
 	c.buffer.Reset()
 	if !c.hasWrittenHeader {
-		err := c.csvWriter.Write(microbenchmark.CSVOutputHeader)
+		err := c.csvWriter.Write(c.header())
 		if err != nil {
 			return nil, err
 		}
 		c.hasWrittenHeader = true
 	}
-	if err := c.csvWriter.Write(result.Record()); err != nil {
+	if err := c.csvWriter.Write(c.record(&result)); err != nil {
 		return nil, err
 	}
 	c.csvWriter.Flush()
 	return c.buffer.Bytes(), nil
 }
+
+func (c *csvResultEncoder) header() []string {
+	if c.fields == nil {
+		return microbenchmark.CSVOutputHeader
+	}
+	header := make([]string, len(c.fields))
+	for i, field := range c.fields {
+		header[i] = field.CSVHeader
+	}
+	return header
+}
+
+func (c *csvResultEncoder) record(result *microbenchmark.Result) []string {
+	if c.fields == nil {
+		return result.Record()
+	}
+	record := make([]string, len(c.fields))
+	for i, field := range c.fields {
+		record[i] = field.CSVValue(result)
+	}
+	return record
+}
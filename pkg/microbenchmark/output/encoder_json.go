@@ -10,6 +10,7 @@ import (
 type jsonResultEncoder struct {
 	buffer  *bytes.Buffer
 	encoder *json.Encoder
+	fields  []resultField
 }
 
 func newJSONResultEncoder(config *Output) (ResultEncoder, error) {
@@ -17,13 +18,25 @@ func newJSONResultEncoder(config *Output) (ResultEncoder, error) {
 	return &jsonResultEncoder{
 		buffer:  buffer,
 		encoder: json.NewEncoder(buffer),
+		fields:  config.fields,
 	}, nil
 }
 
 func (j *jsonResultEncoder) Encode(result microbenchmark.Result) ([]byte, error) {
 	j.buffer.Reset()
-	if err := j.encoder.Encode(result); err != nil {
+	if err := j.encoder.Encode(j.record(&result)); err != nil {
 		return nil, err
 	}
 	return j.buffer.Bytes(), nil
 }
+
+func (j *jsonResultEncoder) record(result *microbenchmark.Result) any {
+	if j.fields == nil {
+		return result
+	}
+	record := make(map[string]any, len(j.fields))
+	for _, field := range j.fields {
+		record[field.JSONKey] = field.JSONValue(result)
+	}
+	return record
+}
@@ -0,0 +1,215 @@
+package output
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+)
+
+// resultFilter decides which results are written to an output.
+type resultFilter struct {
+	include  *regexp.Regexp
+	exclude  *regexp.Regexp
+	versions map[int]bool
+}
+
+func (o *Output) parseFilter() (*resultFilter, error) {
+	filter := &resultFilter{}
+	var err error
+	if include := o.Parameters.Get("include"); include != "" {
+		filter.include, err = regexp.Compile(include)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include filter expression %s: %w", include, err)
+		}
+	}
+	if exclude := o.Parameters.Get("exclude"); exclude != "" {
+		filter.exclude, err = regexp.Compile(exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude filter expression %s: %w", exclude, err)
+		}
+	}
+	if versions := o.Parameters.Get("version"); versions != "" {
+		filter.versions = make(map[int]bool)
+		for _, v := range strings.Split(versions, ",") {
+			version, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(v), "v"))
+			if err != nil || (version != 1 && version != 2) {
+				return nil, fmt.Errorf("invalid version filter: %s", v)
+			}
+			filter.versions[version] = true
+		}
+	}
+	return filter, nil
+}
+
+func (f *resultFilter) Match(result *microbenchmark.Result) bool {
+	fnName := result.Function.String()
+	if f.include != nil && !f.include.MatchString(fnName) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(fnName) {
+		return false
+	}
+	if f.versions != nil && !f.versions[result.Version] {
+		return false
+	}
+	return true
+}
+
+// resultField is a projectable field of a result.
+type resultField struct {
+	CSVHeader string
+	JSONKey   string
+	CSVValue  func(r *microbenchmark.Result) string
+	JSONValue func(r *microbenchmark.Result) any
+	// ParseCSV sets the field of the result from its CSV value.
+	ParseCSV func(r *microbenchmark.Result, value string) error
+}
+
+func formatInt(v int) string {
+	return strconv.FormatInt(int64(v), 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 32)
+}
+
+func parseInt(field func(r *microbenchmark.Result) *int) func(r *microbenchmark.Result, value string) error {
+	return func(r *microbenchmark.Result, value string) (err error) {
+		*field(r), err = strconv.Atoi(value)
+		return err
+	}
+}
+
+func parseFloat(field func(r *microbenchmark.Result) *float64) func(r *microbenchmark.Result, value string) error {
+	return func(r *microbenchmark.Result, value string) (err error) {
+		*field(r), err = strconv.ParseFloat(value, 64)
+		return err
+	}
+}
+
+var resultFields = map[string]resultField{
+	"rsi": {
+		CSVHeader: "R-S-I", JSONKey: "RSI",
+		CSVValue:  func(r *microbenchmark.Result) string { return r.RSI() },
+		JSONValue: func(r *microbenchmark.Result) any { return r.RSI() },
+		ParseCSV: func(r *microbenchmark.Result, value string) error {
+			_, err := fmt.Sscanf(value, "%d-%d-%d", &r.R, &r.S, &r.I)
+			return err
+		},
+	},
+	"r": {
+		CSVHeader: "R", JSONKey: "R",
+		CSVValue:  func(r *microbenchmark.Result) string { return formatInt(r.R) },
+		JSONValue: func(r *microbenchmark.Result) any { return r.R },
+		ParseCSV:  parseInt(func(r *microbenchmark.Result) *int { return &r.R }),
+	},
+	"s": {
+		CSVHeader: "S", JSONKey: "S",
+		CSVValue:  func(r *microbenchmark.Result) string { return formatInt(r.S) },
+		JSONValue: func(r *microbenchmark.Result) any { return r.S },
+		ParseCSV:  parseInt(func(r *microbenchmark.Result) *int { return &r.S }),
+	},
+	"i": {
+		CSVHeader: "I", JSONKey: "I",
+		CSVValue:  func(r *microbenchmark.Result) string { return formatInt(r.I) },
+		JSONValue: func(r *microbenchmark.Result) any { return r.I },
+		ParseCSV:  parseInt(func(r *microbenchmark.Result) *int { return &r.I }),
+	},
+	"function": {
+		CSVHeader: "package.BenchmarkFunction", JSONKey: "Function",
+		CSVValue:  func(r *microbenchmark.Result) string { return r.Function.String() },
+		JSONValue: func(r *microbenchmark.Result) any { return r.Function },
+		ParseCSV: func(r *microbenchmark.Result, value string) error {
+			pkgName, fnName, ok := strings.Cut(value, ".")
+			if !ok {
+				return fmt.Errorf("invalid function name: %s", value)
+			}
+			r.Function.PackageName, r.Function.Name = pkgName, fnName
+			return nil
+		},
+	},
+	"version": {
+		CSVHeader: "Version", JSONKey: "Version",
+		CSVValue:  func(r *microbenchmark.Result) string { return formatInt(r.Version) },
+		JSONValue: func(r *microbenchmark.Result) any { return r.Version },
+		ParseCSV:  parseInt(func(r *microbenchmark.Result) *int { return &r.Version }),
+	},
+	"file": {
+		CSVHeader: "FileName", JSONKey: "FileName",
+		CSVValue:  func(r *microbenchmark.Result) string { return r.Function.FileName },
+		JSONValue: func(r *microbenchmark.Result) any { return r.Function.FileName },
+		ParseCSV: func(r *microbenchmark.Result, value string) error {
+			r.Function.FileName = value
+			return nil
+		},
+	},
+	"iterations": {
+		CSVHeader: "Iterations", JSONKey: "Iterations",
+		CSVValue:  func(r *microbenchmark.Result) string { return formatInt(r.Iterations) },
+		JSONValue: func(r *microbenchmark.Result) any { return r.Iterations },
+		ParseCSV:  parseInt(func(r *microbenchmark.Result) *int { return &r.Iterations }),
+	},
+	"ops": {
+		CSVHeader: "sec/op", JSONKey: "Ops",
+		CSVValue:  func(r *microbenchmark.Result) string { return formatFloat(r.Ops) },
+		JSONValue: func(r *microbenchmark.Result) any { return r.Ops },
+		ParseCSV:  parseFloat(func(r *microbenchmark.Result) *float64 { return &r.Ops }),
+	},
+	"bytes": {
+		CSVHeader: "B/op", JSONKey: "Bytes",
+		CSVValue:  func(r *microbenchmark.Result) string { return formatFloat(r.Bytes) },
+		JSONValue: func(r *microbenchmark.Result) any { return r.Bytes },
+		ParseCSV:  parseFloat(func(r *microbenchmark.Result) *float64 { return &r.Bytes }),
+	},
+	"allocs": {
+		CSVHeader: "allocs/op", JSONKey: "Allocs",
+		CSVValue:  func(r *microbenchmark.Result) string { return formatFloat(r.Allocs) },
+		JSONValue: func(r *microbenchmark.Result) any { return r.Allocs },
+		ParseCSV:  parseFloat(func(r *microbenchmark.Result) *float64 { return &r.Allocs }),
+	},
+}
+
+// csvFields returns the fields of a CSV header. It reports false if the
+// record is not a header.
+func csvFields(header []string) ([]resultField, bool) {
+	fields := make([]resultField, len(header))
+	for i, name := range header {
+		found := false
+		for _, field := range resultFields {
+			if field.CSVHeader == name {
+				fields[i], found = field, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+// parseFields returns the fields selected by the "fields" parameter
+// (e.g. fields=function,version,ops) or nil if all fields should be written.
+// Projected csv outputs must have a header to be read back.
+func (o *Output) parseFields() ([]resultField, error) {
+	fieldsParam := o.Parameters.Get("fields")
+	if fieldsParam == "" {
+		return nil, nil
+	}
+	// projected csv records are read back by the columns of the header
+	if o.Type == "csv" && o.Parameters.Get("no-csv-header") == "true" {
+		return nil, fmt.Errorf("the fields parameter requires a csv output with header")
+	}
+	fields := make([]resultField, 0)
+	for _, name := range strings.Split(fieldsParam, ",") {
+		field, ok := resultFields[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unsupported output field: %s", name)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
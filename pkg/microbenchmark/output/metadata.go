@@ -0,0 +1,208 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+)
+
+// MetadataSuffix is appended to the output path to create the metadata sidecar path.
+const MetadataSuffix = ".meta.json"
+
+const (
+	metadataModeNone    = "none"
+	metadataModeSidecar = "sidecar"
+	metadataModeHeader  = "header"
+)
+
+// metadataHeader is the first record of each json output (chunk) if metadata=header is used.
+type metadataHeader struct {
+	Metadata *microbenchmark.Metadata
+}
+
+func (o *Output) parseMetadataMode() (string, error) {
+	if o.metadata == nil {
+		return metadataModeNone, nil
+	}
+	mode := o.Parameters.Get("metadata")
+	if mode == "" {
+		switch {
+		case o.path != "-":
+			mode = metadataModeSidecar
+		case o.Type == "json":
+			mode = metadataModeHeader
+		default:
+			mode = metadataModeNone
+		}
+	}
+	switch mode {
+	case metadataModeNone:
+	case metadataModeSidecar:
+		if o.path == "-" {
+			return "", fmt.Errorf("cannot write metadata sidecar for stdout")
+		}
+	case metadataModeHeader:
+		if o.Type != "json" {
+			return "", fmt.Errorf("metadata header is only supported for json outputs")
+		}
+	default:
+		return "", fmt.Errorf("unsupported metadata mode: %s", mode)
+	}
+	return mode, nil
+}
+
+func (o *Output) MetadataPath() string {
+	return o.path + MetadataSuffix
+}
+
+func (o *Output) writeMetadataHeader() error {
+	header, err := json.Marshal(metadataHeader{Metadata: o.metadata})
+	if err != nil {
+		return err
+	}
+	_, err = o.writer.Write(append(header, '\n'))
+	return err
+}
+
+func (o *Output) writeMetadataSidecar(metadata *microbenchmark.Metadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	writer, err := NewWriterForPath(o, o.MetadataPath())
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// finishMetadataSidecar rewrites the sidecar including the end timestamp.
+func (o *Output) finishMetadataSidecar() error {
+	metadata := *o.metadata
+	metadata.EndTime = time.Now()
+	return o.writeMetadataSidecar(&metadata)
+}
+
+// ReadMetadata reads all metadata sidecars stored at the given location,
+// which is interpreted the same way as in ReadResults.
+func ReadMetadata(ctx context.Context, location string) ([]*microbenchmark.Metadata, error) {
+	sidecars, err := ReadSidecars(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	allMetadata := make([]*microbenchmark.Metadata, 0, len(sidecars))
+	for _, sidecar := range sidecars {
+		allMetadata = append(allMetadata, sidecar.Metadata)
+	}
+	return allMetadata, nil
+}
+
+// Sidecar is a metadata sidecar together with the result files of its output.
+type Sidecar struct {
+	// Path identifies the sidecar, e.g. gs://cbc-results/mb/exp/run-1.csv.meta.json.
+	Path     string
+	Metadata *microbenchmark.Metadata
+
+	source      Source
+	resultNames []string
+}
+
+// ReadResults reads the results of the output of the sidecar, i.e. of all its
+// chunks and compressed variants.
+func (s *Sidecar) ReadResults(ctx context.Context) (microbenchmark.Results, error) {
+	results := make(microbenchmark.Results, 0)
+	for _, name := range s.resultNames {
+		outputType, compression, _ := ParseResultPath(name)
+		fileResults, err := readResultFile(ctx, s.source, name, outputType, compression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		results = append(results, fileResults...)
+	}
+	return results, nil
+}
+
+// ReadSidecars reads all metadata sidecars stored at the given location, which
+// is interpreted the same way as in ReadResults, and pairs each of them with
+// the result files written next to it by the same output.
+func ReadSidecars(ctx context.Context, location string) ([]*Sidecar, error) {
+	source, err := NewSource(location)
+	if err != nil {
+		return nil, err
+	}
+	names, err := source.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// chunk indices are zero padded, hence sorting restores the write order
+	sort.Strings(names)
+	resultNames := make(map[string][]string)
+	for _, name := range names {
+		if _, _, ok := ParseResultPath(name); ok {
+			outputPath := resultOutputPath(name)
+			resultNames[outputPath] = append(resultNames[outputPath], name)
+		}
+	}
+	sidecars := make([]*Sidecar, 0)
+	for _, name := range names {
+		if !strings.HasSuffix(name, MetadataSuffix) {
+			continue
+		}
+		metadata, err := readMetadataFile(ctx, source, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		sidecars = append(sidecars, &Sidecar{
+			Path:        sourcePath(location, name),
+			Metadata:    metadata,
+			source:      source,
+			resultNames: resultNames[strings.TrimSuffix(name, MetadataSuffix)],
+		})
+	}
+	return sidecars, nil
+}
+
+// resultOutputPath returns the path of the output that wrote the result file,
+// i.e. the path without chunk suffix and compression extension.
+func resultOutputPath(name string) string {
+	_, p := CompressionFromPath(name)
+	return chunkSuffixRe.ReplaceAllString(p, "")
+}
+
+// sourcePath returns the full path of an object listed by the source of the
+// location, e.g. including the bucket of gs:// locations.
+func sourcePath(location, name string) string {
+	parsedLocation, err := url.Parse(location)
+	if err != nil || parsedLocation.Scheme == "" || parsedLocation.Scheme == "file" {
+		return name
+	}
+	return fmt.Sprintf("%s://%s/%s", parsedLocation.Scheme, parsedLocation.Host, strings.TrimPrefix(name, "/"))
+}
+
+func readMetadataFile(ctx context.Context, source Source, name string) (*microbenchmark.Metadata, error) {
+	reader, err := source.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &microbenchmark.Metadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -6,8 +6,12 @@ import (
 	"io"
 	"net/url"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
 	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
 )
 
@@ -32,6 +36,34 @@ func BenchFnChunkFn(lastResult, newResult *microbenchmark.Result) bool {
 	return lastResult.Function.String() != newResult.Function.String()
 }
 
+// ChunkStats describes the content of the currently open chunk.
+type ChunkStats struct {
+	Bytes    uint64 // encoded bytes before compression
+	Records  uint64
+	OpenedAt time.Time
+}
+
+// MaxBytesChunkFn starts a new chunk once the current chunk holds at least maxBytes of encoded data.
+func MaxBytesChunkFn(stats *ChunkStats, maxBytes uint64) NewChunkFunc {
+	return func(lastResult, newResult *microbenchmark.Result) bool {
+		return lastResult == nil || stats.Bytes >= maxBytes
+	}
+}
+
+// MaxRecordsChunkFn starts a new chunk once the current chunk holds maxRecords results.
+func MaxRecordsChunkFn(stats *ChunkStats, maxRecords uint64) NewChunkFunc {
+	return func(lastResult, newResult *microbenchmark.Result) bool {
+		return lastResult == nil || stats.Records >= maxRecords
+	}
+}
+
+// MaxDurationChunkFn starts a new chunk once the current chunk has been open for maxDuration.
+func MaxDurationChunkFn(stats *ChunkStats, maxDuration time.Duration) NewChunkFunc {
+	return func(lastResult, newResult *microbenchmark.Result) bool {
+		return lastResult == nil || time.Since(stats.OpenedAt) >= maxDuration
+	}
+}
+
 type Output struct {
 	Context context.Context
 
@@ -41,17 +73,39 @@ type Output struct {
 	path       string
 	Parameters url.Values
 
-	writer     io.WriteCloser
-	encoder    ResultEncoder
-	writeMutex sync.Mutex
+	writer      io.WriteCloser
+	encoder     ResultEncoder
+	compression string
+	writeMutex  sync.Mutex
+
+	metadata     *microbenchmark.Metadata
+	metadataMode string
+
+	filter *resultFilter
+	fields []resultField
 
 	chunked    bool
-	newChunkFn func(previous, current *microbenchmark.Result) bool
+	newChunkFn NewChunkFunc
 	chunkIndex uint64
+	chunkStats ChunkStats
 	lastResult *microbenchmark.Result
 }
 
-func newOutput(ctx context.Context, outputPath, defaultType string) (*Output, error) {
+func newOutput(ctx context.Context, outputPath, defaultType string, metadata *microbenchmark.Metadata) (*Output, error) {
+	o, err := parseOutput(ctx, outputPath, defaultType, metadata)
+	if err != nil {
+		return nil, err
+	}
+	o.encoder, err = NewEncoder(o)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// parseOutput parses the output path and its parameters without setting up
+// the result encoder.
+func parseOutput(ctx context.Context, outputPath, defaultType string, metadata *microbenchmark.Metadata) (*Output, error) {
 	outputType := defaultType
 	parsedPath, err := url.Parse(outputPath)
 	if err != nil {
@@ -69,39 +123,82 @@ func newOutput(ctx context.Context, outputPath, defaultType string) (*Output, er
 	}
 	params := parsedPath.Query()
 	o := &Output{
-		Context:    ctx,
-		Schema:     schema,
-		Type:       outputType,
-		Host:       parsedPath.Host,
-		path:       parsedPath.Path,
-		Parameters: params,
-		chunked:    params.Get("chunked") == "true",
+		Context:     ctx,
+		Schema:      schema,
+		Type:        outputType,
+		Host:        parsedPath.Host,
+		path:        parsedPath.Path,
+		Parameters:  params,
+		compression: params.Get("compress"),
+		metadata:    metadata,
+		chunked:     params.Get("chunked") == "true",
+	}
+
+	if err := o.parseParameters(); err != nil {
+		return nil, err
 	}
+	return o, nil
+}
 
+// parseParameters validates the output parameters and sets up compression,
+// metadata, chunking, filters and field projections.
+func (o *Output) parseParameters() error {
 	if o.chunked && o.path == "-" {
-		return nil, fmt.Errorf("cannot chunk to stdout")
+		return fmt.Errorf("cannot chunk to stdout")
+	}
+
+	if !IsValidCompression(o.compression) {
+		return fmt.Errorf("unsupported output compression: %s", o.compression)
+	}
+
+	var err error
+	o.metadataMode, err = o.parseMetadataMode()
+	if err != nil {
+		return err
 	}
 
 	if o.chunked {
-		chunkFn := params.Get("new-chunk-fn")
-		switch chunkFn {
-		case "no-chunk":
-			o.newChunkFn = NoChunkFn
-		case "suite":
-			o.newChunkFn = SuiteChunkFn
-		case "benchFn":
-			o.newChunkFn = BenchFnChunkFn
-		default:
-			o.newChunkFn = BenchFnChunkFn
+		o.newChunkFn, err = o.parseNewChunkFn()
+		if err != nil {
+			return err
 		}
 	}
 
-	o.encoder, err = NewEncoder(o)
+	o.filter, err = o.parseFilter()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	o.fields, err = o.parseFields()
+	return err
+}
 
-	return o, nil
+func (o *Output) parseNewChunkFn() (NewChunkFunc, error) {
+	switch chunkFn := o.Parameters.Get("new-chunk-fn"); chunkFn {
+	case "no-chunk":
+		return NoChunkFn, nil
+	case "suite":
+		return SuiteChunkFn, nil
+	case "bytes":
+		maxBytes, err := strconv.ParseUint(o.Parameters.Get("chunk-max-bytes"), 10, 64)
+		if err != nil || maxBytes == 0 {
+			return nil, fmt.Errorf("new-chunk-fn=%s requires a positive chunk-max-bytes parameter", chunkFn)
+		}
+		return MaxBytesChunkFn(&o.chunkStats, maxBytes), nil
+	case "records":
+		maxRecords, err := strconv.ParseUint(o.Parameters.Get("chunk-max-records"), 10, 64)
+		if err != nil || maxRecords == 0 {
+			return nil, fmt.Errorf("new-chunk-fn=%s requires a positive chunk-max-records parameter", chunkFn)
+		}
+		return MaxRecordsChunkFn(&o.chunkStats, maxRecords), nil
+	case "time":
+		maxDuration, err := time.ParseDuration(o.Parameters.Get("chunk-max-duration"))
+		if err != nil || maxDuration <= 0 {
+			return nil, fmt.Errorf("new-chunk-fn=%s requires a positive chunk-max-duration parameter", chunkFn)
+		}
+		return MaxDurationChunkFn(&o.chunkStats, maxDuration), nil
+	default:
+		return BenchFnChunkFn, nil
+	}
 }
 
 // opens the output file if it is not already open
@@ -112,31 +209,69 @@ func (o *Output) open() error {
 			return err
 		}
 	}
-	var err error
-	o.writer, err = NewWriter(o)
-	return err
+	writer, err := NewWriter(o)
+	if err != nil {
+		o.writer = nil
+		return err
+	}
+	o.writer, err = NewCompressedWriter(o.compression, writer)
+	if err != nil {
+		_ = writer.Close()
+		o.writer = nil
+		return err
+	}
+	o.chunkStats = ChunkStats{OpenedAt: time.Now()}
+	return nil
 }
 
 func (o *Output) GetPath() string {
-	if !o.chunked {
-		return o.path
+	p := o.path
+	if o.chunked {
+		p = fmt.Sprintf("%s.%04d", p, o.chunkIndex)
+	}
+	if o.path == "-" {
+		return p
 	}
-	return fmt.Sprintf("%s.%04d", o.path, o.chunkIndex)
+	return p + CompressionExtension(o.compression)
+}
+
+// openChunk opens the writer of the first or next chunk and writes the metadata.
+func (o *Output) openChunk(isNewChunk bool) error {
+	// the sidecar is written on first use and updated when the output is closed
+	if o.writer == nil && o.lastResult == nil && o.metadataMode == metadataModeSidecar {
+		if err := o.writeMetadataSidecar(o.metadata); err != nil {
+			return err
+		}
+	}
+	if err := o.open(); err != nil {
+		return err
+	}
+	if isNewChunk {
+		o.chunkIndex++
+	}
+	if starter, ok := o.encoder.(chunkStarter); ok {
+		starter.StartChunk()
+	}
+	if o.metadataMode == metadataModeHeader {
+		return o.writeMetadataHeader()
+	}
+	return nil
 }
 
 func (o *Output) Write(result microbenchmark.Result) error {
 	o.writeMutex.Lock()
 	defer o.writeMutex.Unlock()
 
+	if !o.filter.Match(&result) {
+		return nil
+	}
+
 	// open new writer if it is not already open or a new chunk is needed
 	isNewChunk := o.chunked && o.newChunkFn(o.lastResult, &result)
 	if isNewChunk || o.writer == nil {
-		if err := o.open(); err != nil {
+		if err := o.openChunk(isNewChunk); err != nil {
 			return err
 		}
-		if isNewChunk {
-			o.chunkIndex++
-		}
 	}
 
 	env, err := o.encoder.Encode(result)
@@ -150,6 +285,8 @@ func (o *Output) Write(result microbenchmark.Result) error {
 	if n != len(env) {
 		return io.ErrShortWrite
 	}
+	o.chunkStats.Bytes += uint64(n)
+	o.chunkStats.Records++
 	o.lastResult = &result
 	return nil
 }
@@ -157,18 +294,31 @@ func (o *Output) Write(result microbenchmark.Result) error {
 func (o *Output) Close() error {
 	o.writeMutex.Lock()
 	defer o.writeMutex.Unlock()
+	var err error
 	if o.writer != nil {
-		err := o.writer.Close()
+		err = o.writer.Close()
 		o.writer = nil
-		return err
 	}
-	return nil
+	if o.metadataMode == metadataModeSidecar {
+		err = merror.MaybeMultiError(err, o.finishMetadataSidecar())
+	}
+	return err
 }
 
-func New(ctx context.Context, outputPaths []string, defaultType string) (microbenchmark.ResultWriter, error) {
+// New creates a result writer for the given output paths. If metadata is
+// provided, it is stored next to the results of each output.
+func New(ctx context.Context, outputPaths []string, defaultType string, metadata *microbenchmark.Metadata) (microbenchmark.ResultWriter, error) {
 	resultWriters := make([]microbenchmark.ResultWriter, 0, len(outputPaths))
 	for _, outputPath := range outputPaths {
-		out, err := newOutput(ctx, outputPath, defaultType)
+		if strings.HasPrefix(outputPath, sqliteSchema+"://") {
+			store, err := newSQLiteOutput(ctx, outputPath, metadata)
+			if err != nil {
+				return nil, err
+			}
+			resultWriters = append(resultWriters, store)
+			continue
+		}
+		out, err := newOutput(ctx, outputPath, defaultType, metadata)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,262 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/resultstore"
+	"github.com/stretchr/testify/require"
+)
+
+func testResults() microbenchmark.Results {
+	results := make(microbenchmark.Results, 0)
+	for s := 1; s <= 2; s++ {
+		for i, fnName := range []string{"BenchmarkA", "BenchmarkB"} {
+			for v := 1; v <= 2; v++ {
+				results = append(results, microbenchmark.Result{
+					Function: microbenchmark.Function{
+						Name:        fnName,
+						FileName:    "service/service_test.go",
+						PackageName: "service",
+					},
+					Iterations: 1000 * v,
+					Ops:        0.5 * float64(v),
+					Bytes:      128,
+					Allocs:     2,
+					R:          1,
+					S:          s,
+					I:          i + 1,
+					Version:    v,
+				})
+			}
+		}
+	}
+	return results
+}
+
+func writeResults(t *testing.T, outputPath string, results microbenchmark.Results, metadata *microbenchmark.Metadata) {
+	out, err := newOutput(context.Background(), outputPath, "csv", metadata)
+	require.NoError(t, err)
+	for _, result := range results {
+		require.NoError(t, out.Write(result))
+	}
+	require.NoError(t, out.Close())
+}
+
+func TestReadResultsRoundTrip(t *testing.T) {
+	outputs := map[string]int{
+		"run.csv":                1,
+		"run.json":               1,
+		"run.csv?compress=gzip":  1,
+		"run.json?compress=zstd": 1,
+		"run.csv?chunked=true&no-csv-header=true":                                     4,
+		"run.csv?chunked=true&new-chunk-fn=suite":                                     2,
+		"run.csv?chunked=true&new-chunk-fn=records&chunk-max-records=3&compress=zstd": 3,
+		"run.json?chunked=true&new-chunk-fn=bytes&chunk-max-bytes=1&compress=gzip":    8,
+	}
+	results := testResults()
+	for outputPath, chunks := range outputs {
+		t.Run(outputPath, func(t *testing.T) {
+			dir := t.TempDir()
+			writeResults(t, filepath.Join(dir, outputPath), results, nil)
+
+			files, err := filepath.Glob(filepath.Join(dir, "*"))
+			require.NoError(t, err)
+			require.Len(t, files, chunks)
+
+			readResults, err := ReadResults(context.Background(), dir)
+			require.NoError(t, err)
+			require.Equal(t, results, readResults)
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	results := testResults()
+	metadata := &microbenchmark.Metadata{
+		V1:        microbenchmark.VersionInfo{Reference: "main", Commit: "abc"},
+		V2:        microbenchmark.VersionInfo{Reference: "feature", Commit: "def"},
+		RunIndex:  1,
+		SuiteRuns: 2,
+		Seed:      42,
+		StartTime: time.Now(),
+	}
+	dir := t.TempDir()
+	writeResults(t, filepath.Join(dir, "run.csv?chunked=true&compress=gzip"), results, metadata)
+	writeResults(t, filepath.Join(dir, "run.json?metadata=header"), results, metadata)
+
+	_, err := os.Stat(filepath.Join(dir, "run.csv.meta.json"))
+	require.NoError(t, err)
+
+	readResults, err := ReadResults(context.Background(), dir)
+	require.NoError(t, err)
+	require.Equal(t, append(results, results...), readResults)
+
+	readMetadata, err := ReadMetadata(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, readMetadata, 1)
+	require.Equal(t, "def", readMetadata[0].V2.Commit)
+	require.Equal(t, int64(42), readMetadata[0].Seed)
+	require.False(t, readMetadata[0].EndTime.IsZero())
+	require.True(t, metadata.EndTime.IsZero())
+}
+
+func TestIngestSidecars(t *testing.T) {
+	ctx := context.Background()
+	prefix := filepath.Join(t.TempDir(), "mb")
+	writeExperiment := func(name, v2 string, ops float64) {
+		metadata := &microbenchmark.Metadata{
+			V1:       microbenchmark.VersionInfo{Reference: "main", Commit: "main"},
+			V2:       microbenchmark.VersionInfo{Reference: v2, Commit: v2},
+			RunIndex: 1,
+		}
+		results := testResults()
+		for i := range results {
+			if results[i].Version == 2 {
+				results[i].Ops = ops
+			}
+		}
+		require.NoError(t, os.MkdirAll(filepath.Join(prefix, name), 0o755))
+		writeResults(t, filepath.Join(prefix, name, "run-1.csv?chunked=true&compress=gzip"), results, metadata)
+	}
+	store, err := resultstore.Open(ctx, filepath.Join(t.TempDir(), "results.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	ingest := func() int {
+		sidecars, err := ReadSidecars(ctx, prefix)
+		require.NoError(t, err)
+		ingested := 0
+		for _, sidecar := range sidecars {
+			results, err := sidecar.ReadResults(ctx)
+			require.NoError(t, err)
+			require.Len(t, results, len(testResults()))
+			ok, err := store.Ingest(ctx, sidecar.Path, "", sidecar.Metadata, results)
+			require.NoError(t, err)
+			if ok {
+				ingested++
+			}
+		}
+		return ingested
+	}
+
+	// two experiments below the same prefix, both with a run 1
+	writeExperiment("exp-a", "a", 1)
+	writeExperiment("exp-b", "b", 2)
+	require.Equal(t, 2, ingest())
+	require.Equal(t, 0, ingest())
+
+	// each run is stored with its own metadata and results
+	query, err := resultstore.FindQuery("medians")
+	require.NoError(t, err)
+	_, rows, err := query.Run(ctx, store, resultstore.QueryFilter{})
+	require.NoError(t, err)
+	medians := make(map[string]string)
+	for _, row := range rows {
+		if row[0] == "service.BenchmarkA" {
+			medians[row[1]] = row[4]
+		}
+	}
+	require.Equal(t, map[string]string{"main": "0.5", "a": "1", "b": "2"}, medians)
+
+	// experiments added later are ingested as well
+	writeExperiment("exp-c", "c", 3)
+	require.Equal(t, 1, ingest())
+
+	require.Equal(t, "gs://cbc-results/mb/exp/run-1.csv.meta.json", sourcePath("gs://cbc-results/mb/", "mb/exp/run-1.csv.meta.json"))
+}
+
+func TestInvalidOutputParameters(t *testing.T) {
+	invalid := []string{
+		"run.csv?compress=brotli",
+		"run.csv?chunked=true&new-chunk-fn=bytes",
+		"run.csv?chunked=true&new-chunk-fn=records&chunk-max-records=0",
+		"run.csv?chunked=true&new-chunk-fn=time&chunk-max-duration=soon",
+		"run.csv?metadata=header",
+		"run.csv?metadata=footer",
+		"run.csv?include=(",
+		"run.csv?version=3",
+		"run.json?fields=function,duration",
+		"run.csv?fields=function,ops&no-csv-header=true",
+	}
+	for _, outputPath := range invalid {
+		_, err := newOutput(context.Background(), outputPath, "csv", &microbenchmark.Metadata{})
+		require.Error(t, err, outputPath)
+	}
+}
+
+func TestParseResultPath(t *testing.T) {
+	outputType, compression, ok := ParseResultPath("mb/run-1.csv.0003.gz")
+	require.True(t, ok)
+	require.Equal(t, "csv", outputType)
+	require.Equal(t, "gzip", compression)
+
+	outputType, compression, ok = ParseResultPath("mb/run-1.json")
+	require.True(t, ok)
+	require.Equal(t, "json", outputType)
+	require.Equal(t, "", compression)
+
+	_, _, ok = ParseResultPath("mb/profile.out")
+	require.False(t, ok)
+}
+
+func TestFiltersAndFields(t *testing.T) {
+	results := testResults()
+	dir := t.TempDir()
+	writeResults(t, filepath.Join(dir, "full.csv"), results, nil)
+	writeResults(t, filepath.Join(dir, "filtered.csv?include=BenchmarkB&version=2&chunked=true&no-csv-header=true"), results, nil)
+	writeResults(t, filepath.Join(dir, "compact.csv?fields=function,version,ops&exclude=BenchmarkB"), results, nil)
+	writeResults(t, filepath.Join(dir, "compact.json?fields=function,ops&version=v1"), results, nil)
+	writeResults(t, filepath.Join(dir, "chunked.csv?fields=function,version,ops&chunked=true&new-chunk-fn=records&chunk-max-records=3"), results, nil)
+
+	filtered, err := ReadResults(context.Background(), filepath.Join(dir, "filtered.csv"))
+	require.NoError(t, err)
+	require.Len(t, filtered, 2)
+	for _, result := range filtered {
+		require.Equal(t, "service.BenchmarkB", result.Function.String())
+		require.Equal(t, 2, result.Version)
+	}
+
+	compactCSV, err := os.ReadFile(filepath.Join(dir, "compact.csv"))
+	require.NoError(t, err)
+	require.Equal(t, "package.BenchmarkFunction,Version,sec/op\n"+
+		"service.BenchmarkA,1,0.5\nservice.BenchmarkA,2,1\n"+
+		"service.BenchmarkA,1,0.5\nservice.BenchmarkA,2,1\n", string(compactCSV))
+
+	compact, err := ReadResults(context.Background(), filepath.Join(dir, "compact.csv"))
+	require.NoError(t, err)
+	require.Len(t, compact, 4)
+	require.Equal(t, microbenchmark.Result{
+		Function: microbenchmark.Function{PackageName: "service", Name: "BenchmarkA"},
+		Version:  2,
+		Ops:      1,
+	}, compact[1])
+
+	// each chunk starts with the projected header
+	for i, expected := range []string{
+		"service.BenchmarkA,1,0.5\nservice.BenchmarkA,2,1\nservice.BenchmarkB,1,0.5\n",
+		"service.BenchmarkB,2,1\nservice.BenchmarkA,1,0.5\nservice.BenchmarkA,2,1\n",
+		"service.BenchmarkB,1,0.5\nservice.BenchmarkB,2,1\n",
+	} {
+		chunk, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("chunked.csv.%04d", i)))
+		require.NoError(t, err)
+		require.Equal(t, "package.BenchmarkFunction,Version,sec/op\n"+expected, string(chunk))
+	}
+	chunked, err := ReadResults(context.Background(), filepath.Join(dir, "chunked.csv"))
+	require.NoError(t, err)
+	require.Len(t, chunked, len(results))
+	require.Equal(t, microbenchmark.Result{
+		Function: microbenchmark.Function{PackageName: "service", Name: "BenchmarkB"},
+		Version:  2,
+		Ops:      1,
+	}, chunked[3])
+
+	compactJSON, err := os.ReadFile(filepath.Join(dir, "compact.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(compactJSON), `{"Function":{"Name":"BenchmarkA","FileName":"service/service_test.go","PackageName":"service","RootDirectory":""},"Ops":0.5}`)
+	require.NotContains(t, string(compactJSON), "Iterations")
+}
@@ -0,0 +1,117 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+)
+
+// Source lists and opens the objects stored at an input location.
+type Source interface {
+	List(ctx context.Context) ([]string, error)
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+type SourceFactory func(host, path string) (Source, error)
+
+var sources = map[string]SourceFactory{
+	"file": newFileSource,
+	"gcs":  newGCSSource,
+	"gs":   newGCSSource,
+}
+
+func NewSource(location string) (Source, error) {
+	parsedLocation, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	schema := parsedLocation.Scheme
+	if schema == "" {
+		schema = "file"
+	}
+	sFactory, ok := sources[schema]
+	if !ok {
+		return nil, fmt.Errorf("unsupported input schema: %s", schema)
+	}
+	return sFactory(parsedLocation.Host, parsedLocation.Path)
+}
+
+var chunkSuffixRe = regexp.MustCompile(`\.\d{4,}$`)
+
+// ParseResultPath returns the output type and compression of a result file
+// written by an Output. Chunk suffixes and compression extensions are taken
+// into account, e.g. "run-1.csv.0003.gz" is a gzip compressed csv file.
+func ParseResultPath(p string) (outputType, compression string, ok bool) {
+	if strings.HasSuffix(p, MetadataSuffix) {
+		return "", "", false
+	}
+	compression, p = CompressionFromPath(p)
+	p = chunkSuffixRe.ReplaceAllString(p, "")
+	ext := filepath.Ext(p)
+	if ext == "" {
+		return "", "", false
+	}
+	outputType = ext[1:]
+	if _, ok := decoders[outputType]; !ok {
+		return "", "", false
+	}
+	return outputType, compression, true
+}
+
+// ReadResults reads all microbenchmark results stored at the given location.
+// The location can be a single result file, the path of a chunked output or a
+// directory (or bucket prefix) containing multiple result files. Results
+// can also be read from a SQLite result store (sqlite://results.db?label=...).
+func ReadResults(ctx context.Context, location string) (microbenchmark.Results, error) {
+	if strings.HasPrefix(location, sqliteSchema+"://") {
+		return readSQLiteResults(ctx, location)
+	}
+	source, err := NewSource(location)
+	if err != nil {
+		return nil, err
+	}
+	names, err := source.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// chunk indices are zero padded, hence sorting restores the write order
+	sort.Strings(names)
+	results := make(microbenchmark.Results, 0)
+	for _, name := range names {
+		outputType, compression, ok := ParseResultPath(name)
+		if !ok {
+			continue
+		}
+		fileResults, err := readResultFile(ctx, source, name, outputType, compression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		results = append(results, fileResults...)
+	}
+	return results, nil
+}
+
+func readResultFile(ctx context.Context, source Source, name, outputType, compression string) (microbenchmark.Results, error) {
+	decoder, err := NewDecoder(outputType)
+	if err != nil {
+		return nil, err
+	}
+	objectReader, err := source.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewDecompressedReader(compression, objectReader)
+	if err != nil {
+		_ = objectReader.Close()
+		return nil, err
+	}
+	defer reader.Close()
+	return decoder(reader)
+}
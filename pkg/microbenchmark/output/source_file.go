@@ -0,0 +1,48 @@
+package output
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type fileSource struct {
+	path string
+}
+
+func newFileSource(_, path string) (Source, error) {
+	return &fileSource{path: path}, nil
+}
+
+func (f *fileSource) List(_ context.Context) ([]string, error) {
+	info, err := os.Stat(f.path)
+	if err == nil && info.IsDir() {
+		names := make([]string, 0)
+		err = filepath.WalkDir(f.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				names = append(names, path)
+			}
+			return nil
+		})
+		return names, err
+	}
+	names := make([]string, 0)
+	if err == nil {
+		names = append(names, f.path)
+	}
+	// chunks and compressed variants of the given path
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	return append(names, matches...), nil
+}
+
+func (f *fileSource) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
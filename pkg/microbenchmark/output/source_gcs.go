@@ -0,0 +1,72 @@
+package output
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/gcloud/storage"
+	"github.com/hashicorp/go-multierror"
+)
+
+type gcsSource struct {
+	bucket string
+	prefix string
+}
+
+func newGCSSource(host, path string) (Source, error) {
+	return &gcsSource{
+		bucket: host,
+		prefix: strings.TrimPrefix(path, "/"),
+	}, nil
+}
+
+func (g *gcsSource) List(ctx context.Context) ([]string, error) {
+	objectNames, err := storage.ListObjects(ctx, g.bucket, g.prefix)
+	if err != nil {
+		return nil, err
+	}
+	if g.prefix == "" {
+		return objectNames, nil
+	}
+	dirPrefix := strings.TrimSuffix(g.prefix, "/") + "/"
+	names := make([]string, 0, len(objectNames))
+	for _, name := range objectNames {
+		// only accept the object itself, its chunks or objects in the "directory"
+		if name == g.prefix || strings.HasPrefix(name, g.prefix+".") || strings.HasPrefix(name, dirPrefix) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (g *gcsSource) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	objectReader, client, err := storage.NewObjectReader(ctx, g.bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsReader{
+		client: client,
+		reader: objectReader,
+	}, nil
+}
+
+type gcsReader struct {
+	client io.Closer
+	reader io.ReadCloser
+}
+
+func (g *gcsReader) Read(p []byte) (n int, err error) {
+	return g.reader.Read(p)
+}
+
+func (g *gcsReader) Close() error {
+	var mErr error
+	if err := g.reader.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	if err := g.client.Close(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+	return mErr
+}
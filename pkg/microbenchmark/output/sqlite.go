@@ -0,0 +1,46 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/resultstore"
+)
+
+const sqliteSchema = "sqlite"
+
+// newSQLiteOutput stores the results in a SQLite database, e.g.
+// sqlite://results.db?label=nightly or sqlite:///abs/path/results.db
+func newSQLiteOutput(ctx context.Context, outputPath string, metadata *microbenchmark.Metadata) (microbenchmark.ResultWriter, error) {
+	parsedPath, err := url.Parse(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	fileName := parsedPath.Host + parsedPath.Path
+	if fileName == "" {
+		return nil, fmt.Errorf("missing sqlite database path: %s", outputPath)
+	}
+	return resultstore.NewResultWriter(ctx, fileName, parsedPath.Query().Get("label"), metadata)
+}
+
+// readSQLiteResults reads the results of all runs (optionally only the ones
+// with the given label) from a SQLite database.
+func readSQLiteResults(ctx context.Context, location string) (microbenchmark.Results, error) {
+	parsedPath, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	fileName := parsedPath.Host + parsedPath.Path
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, err
+	}
+	store, err := resultstore.Open(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.Results(ctx, resultstore.QueryFilter{Label: parsedPath.Query().Get("label")})
+}
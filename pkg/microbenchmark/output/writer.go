@@ -1,11 +1,12 @@
 package output
 
 import (
+	"context"
 	"fmt"
 	"io"
 )
 
-type WriterFactory func(config *Output) (io.WriteCloser, error)
+type WriterFactory func(config *Output, path string) (io.WriteCloser, error)
 
 var writers = map[string]WriterFactory{
 	"file": newFileWriter,
@@ -19,9 +20,32 @@ func IsValidSchema(schema string) bool {
 }
 
 func NewWriter(config *Output) (io.WriteCloser, error) {
+	return NewWriterForPath(config, config.GetPath())
+}
+
+// NewWriterForPath opens a writer for a file next to the output, e.g. a metadata sidecar.
+func NewWriterForPath(config *Output, path string) (io.WriteCloser, error) {
 	wFactory, ok := writers[config.Schema]
 	if !ok {
 		return nil, fmt.Errorf("unsupported output schema: %s", config.Type)
 	}
-	return wFactory(config)
+	return wFactory(config, path)
+}
+
+// NewRawWriter opens an output location for data that is not a
+// microbenchmark result, e.g. cgroup stats or log files. It supports the same
+// schemas and compression parameters as the result outputs and returns the
+// output type detected from the file extension (defaults to defaultType).
+func NewRawWriter(ctx context.Context, location, defaultType string) (io.WriteCloser, string, error) {
+	o, err := parseOutput(ctx, location, defaultType, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.chunked {
+		return nil, "", fmt.Errorf("chunking is not supported for %s", location)
+	}
+	if err := o.open(); err != nil {
+		return nil, "", err
+	}
+	return o.writer, o.Type, nil
 }
@@ -9,12 +9,11 @@ type fileWriter struct {
 	osFile *os.File
 }
 
-func newFileWriter(config *Output) (io.WriteCloser, error) {
-	path := config.GetPath()
+func newFileWriter(_ *Output, path string) (io.WriteCloser, error) {
 	if path == "-" {
 		return &fileWriter{osFile: os.Stdout}, nil
 	}
-	outFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	outFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 	if err != nil {
 		return nil, err
 	}
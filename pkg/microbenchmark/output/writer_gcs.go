@@ -12,8 +12,8 @@ type gcsWriter struct {
 	writer io.WriteCloser
 }
 
-func newGCSWriter(config *Output) (io.WriteCloser, error) {
-	objectWriter, client, err := storage.NewObjectWriter(config.Context, config.Host, config.GetPath())
+func newGCSWriter(config *Output, path string) (io.WriteCloser, error) {
+	objectWriter, client, err := storage.NewObjectWriter(config.Context, config.Host, path)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,28 @@
+package microbenchmark
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ReadQuarantine reads a quarantine list with one function (<package>.<name>)
+// per line. Everything after a # is a comment.
+func ReadQuarantine(r io.Reader) (map[string]bool, error) {
+	quarantined := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if fn := strings.TrimSpace(line); fn != "" {
+			quarantined[fn] = true
+		}
+	}
+	return quarantined, scanner.Err()
+}
+
+// Quarantine removes all quarantined functions.
+func (vfs VersionedFunctions) Quarantine(quarantined map[string]bool) VersionedFunctions {
+	return vfs.Filter(func(vf VersionedFunction) bool {
+		return !quarantined[vf.String()]
+	})
+}
@@ -35,3 +35,10 @@ func (m *multiResultWriter) Close() error {
 	}
 	return mErr
 }
+
+// ProgressReporter can optionally be implemented by a ResultWriter to be
+// notified when the execution of a benchmark function starts and finishes.
+type ProgressReporter interface {
+	FunctionStarted(f Function, version, run, suite int)
+	FunctionFinished(f Function, version, run, suite int, err error)
+}
@@ -10,7 +10,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/christophwitzko/masters-thesis/pkg/logger"
 	"golang.org/x/perf/benchfmt"
@@ -83,6 +82,41 @@ func (r Result) Record() []string {
 	}
 }
 
+// ParseRecord is the inverse of Result.Record. The root directory of the
+// function is not part of the record and therefore left empty.
+func ParseRecord(record []string) (Result, error) {
+	if len(record) != len(CSVOutputHeader) {
+		return Result{}, fmt.Errorf("invalid record length: %d", len(record))
+	}
+	var res Result
+	if _, err := fmt.Sscanf(record[0], "%d-%d-%d", &res.R, &res.S, &res.I); err != nil {
+		return Result{}, fmt.Errorf("invalid R-S-I %s: %w", record[0], err)
+	}
+	pkgName, fnName, ok := strings.Cut(record[1], ".")
+	if !ok {
+		return Result{}, fmt.Errorf("invalid function name: %s", record[1])
+	}
+	res.Function = Function{
+		Name:        fnName,
+		FileName:    record[3],
+		PackageName: pkgName,
+	}
+	var err error
+	if res.Version, err = strconv.Atoi(record[2]); err != nil {
+		return Result{}, fmt.Errorf("invalid version %s: %w", record[2], err)
+	}
+	if res.Iterations, err = strconv.Atoi(record[4]); err != nil {
+		return Result{}, fmt.Errorf("invalid iterations %s: %w", record[4], err)
+	}
+	values := []*float64{&res.Ops, &res.Bytes, &res.Allocs}
+	for i, value := range values {
+		if *value, err = strconv.ParseFloat(record[5+i], 64); err != nil {
+			return Result{}, fmt.Errorf("invalid %s %s: %w", CSVOutputHeader[5+i], record[5+i], err)
+		}
+	}
+	return res, nil
+}
+
 type Results []Result
 
 func (r Results) Records() [][]string {
@@ -94,6 +128,17 @@ func (r Results) Records() [][]string {
 }
 
 func RunFunction(ctx context.Context, log *logger.Logger, resultWriter ResultWriter, f Function, version, run, suite int, env []string) error {
+	progressReporter, ok := resultWriter.(ProgressReporter)
+	if !ok {
+		return runFunction(ctx, log, resultWriter, f, version, run, suite, env)
+	}
+	progressReporter.FunctionStarted(f, version, run, suite)
+	err := runFunction(ctx, log, resultWriter, f, version, run, suite, env)
+	progressReporter.FunctionFinished(f, version, run, suite, err)
+	return err
+}
+
+func runFunction(ctx context.Context, log *logger.Logger, resultWriter ResultWriter, f Function, version, run, suite int, env []string) error {
 	args := []string{
 		"test",
 		"-run=^$",
@@ -203,11 +248,17 @@ func RunVersionedFunction(ctx context.Context, rng *rand.Rand, log *logger.Logge
 	return RunFunction(ctx, log, resultWriter, b, bVersion, run, suite, env)
 }
 
-func RunSuite(ctx context.Context, log *logger.Logger, resultWriter ResultWriter, fns VersionedFunctions, run, suite int, env []string) error {
+// SuiteSeed returns the seed of the random number generator that is used to
+// randomize the execution order of the given suite run.
+func SuiteSeed(seed int64, suite int) int64 {
+	return seed + int64(suite)
+}
+
+func RunSuite(ctx context.Context, log *logger.Logger, resultWriter ResultWriter, fns VersionedFunctions, run, suite int, seed int64, env []string) error {
 	newFns := make(VersionedFunctions, len(fns))
 	copy(newFns, fns)
 
-	rng := rand.New(rand.NewSource(time.Now().Unix()))
+	rng := rand.New(rand.NewSource(SuiteSeed(seed, suite)))
 
 	// shuffle execution order
 	rng.Shuffle(len(newFns), func(i, j int) {
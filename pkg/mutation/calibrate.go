@@ -0,0 +1,35 @@
+package mutation
+
+import "time"
+
+var busyLoopSink uint64
+
+// busyLoop is the busy loop that is injected by KindBusyLoop.
+func busyLoop(iterations int) {
+	for i := 0; i < iterations; i++ {
+		busyLoopSink = busyLoopSink*6364136223846793005 + uint64(i)
+	}
+}
+
+// CalibrateBusyLoop measures the amount of busy loop iterations per
+// microsecond on the current host. The fastest of several measurements is
+// used to reduce the influence of interruptions.
+func CalibrateBusyLoop() int {
+	const iterations = 10_000_000
+	fastest := time.Duration(0)
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		busyLoop(iterations)
+		if elapsed := time.Since(start); fastest == 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+	}
+	if fastest <= 0 {
+		fastest = 1
+	}
+	perMicrosecond := int(float64(iterations) * float64(time.Microsecond) / float64(fastest))
+	if perMicrosecond < 1 {
+		return 1
+	}
+	return perMicrosecond
+}
@@ -0,0 +1,217 @@
+package mutation
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	KindBusyLoop = "busy-loop" // severity is the additional cpu time per call in microseconds
+	KindAlloc    = "alloc"     // severity is the amount of additional heap allocations per call
+)
+
+// AllocSize is the size in bytes of each additional allocation.
+const AllocSize = 1024
+
+// injectors create the statements that are inserted at the beginning of the
+// mutated function and the declarations that are appended to its file.
+var injectors = map[string]func(severity, busyLoopIterations int) (stmts, decls string){
+	KindBusyLoop: func(severity, busyLoopIterations int) (string, string) {
+		return fmt.Sprintf(`for perfMutationI := 0; perfMutationI < %d; perfMutationI++ {
+	perfMutationSink = perfMutationSink*6364136223846793005 + uint64(perfMutationI)
+}
+`, severity*busyLoopIterations), "var perfMutationSink uint64\n"
+	},
+	KindAlloc: func(severity, _ int) (string, string) {
+		// assigning to a package variable moves the allocations to the heap
+		return fmt.Sprintf(`for perfMutationI := 0; perfMutationI < %d; perfMutationI++ {
+	perfMutationBuf = make([]byte, %d)
+}
+`, severity, AllocSize), "var perfMutationBuf []byte\n"
+	},
+}
+
+func IsValidKind(kind string) bool {
+	_, ok := injectors[kind]
+	return ok
+}
+
+func Kinds() []string {
+	kinds := make([]string, 0, len(injectors))
+	for kind := range injectors {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Target is a function or method that is mutated, e.g. "service.GetFlights"
+// or "service.Service.GetFlights" (the receiver can also be written as
+// "(*Service)").
+type Target struct {
+	Package  string
+	Receiver string // type name of the receiver, empty for functions
+	Name     string
+}
+
+func ParseTarget(s string) (Target, error) {
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		parts[i] = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(p, "("), "*"), ")")
+	}
+	switch {
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return Target{Package: parts[0], Name: parts[1]}, nil
+	case len(parts) == 3 && parts[0] != "" && parts[1] != "" && parts[2] != "":
+		return Target{Package: parts[0], Receiver: parts[1], Name: parts[2]}, nil
+	default:
+		return Target{}, fmt.Errorf("invalid target %s (expected <package>.<function> or <package>.<type>.<method>)", s)
+	}
+}
+
+func (t Target) String() string {
+	if t.Receiver != "" {
+		return fmt.Sprintf("%s.%s.%s", t.Package, t.Receiver, t.Name)
+	}
+	return fmt.Sprintf("%s.%s", t.Package, t.Name)
+}
+
+func receiverName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return ""
+	}
+	expr := decl.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	// generic receivers, e.g. List[T]
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		expr = e.X
+	case *ast.IndexListExpr:
+		expr = e.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+func (t Target) matches(pkg string, decl *ast.FuncDecl) bool {
+	return pkg == t.Package && decl.Name.Name == t.Name && receiverName(decl) == t.Receiver && decl.Body != nil
+}
+
+// Mutant is a synthetic slowdown of a function.
+type Mutant struct {
+	Target   Target
+	Kind     string
+	Severity int
+}
+
+// ID is a file name friendly identifier of the mutant.
+func (m Mutant) ID() string {
+	return fmt.Sprintf("%s-%s-%d", m.Target, m.Kind, m.Severity)
+}
+
+func (m Mutant) Validate() error {
+	if !IsValidKind(m.Kind) {
+		return fmt.Errorf("unsupported mutation kind %s (supported: %s)", m.Kind, strings.Join(Kinds(), ", "))
+	}
+	if m.Severity <= 0 {
+		return fmt.Errorf("severity must be positive")
+	}
+	return nil
+}
+
+type location struct {
+	file   string
+	lbrace int // offset of the opening brace of the function body
+}
+
+func findInFile(path string, target Target) ([]location, error) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, path, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]location, 0)
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && target.matches(file.Name.Name, fn) {
+			found = append(found, location{file: path, lbrace: fileSet.Position(fn.Body.Lbrace).Offset})
+		}
+	}
+	return found, nil
+}
+
+// findTarget returns the location of the target in the non-test files below
+// the root directory. The target has to be unique.
+func findTarget(root string, target Target) (location, error) {
+	found := make([]location, 0, 1)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == "vendor" || d.Name() == ".git") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		fileLocations, err := findInFile(path, target)
+		found = append(found, fileLocations...)
+		return err
+	})
+	if err != nil {
+		return location{}, err
+	}
+	switch len(found) {
+	case 0:
+		return location{}, fmt.Errorf("could not find %s in %s", target, root)
+	case 1:
+		return found[0], nil
+	default:
+		return location{}, fmt.Errorf("found %s in %d files", target, len(found))
+	}
+}
+
+// Apply rewrites the target function of the mutant in the source tree at root.
+// The mutation is inserted at the beginning of the function body and does not
+// require additional imports. busyLoopIterations is the amount of busy loop
+// iterations per microsecond (see CalibrateBusyLoop).
+func Apply(root string, mutant Mutant, busyLoopIterations int) (string, error) {
+	if err := mutant.Validate(); err != nil {
+		return "", err
+	}
+	loc, err := findTarget(root, mutant.Target)
+	if err != nil {
+		return "", err
+	}
+	src, err := os.ReadFile(loc.file)
+	if err != nil {
+		return "", err
+	}
+	stmts, decls := injectors[mutant.Kind](mutant.Severity, busyLoopIterations)
+	mutated := bytes.Buffer{}
+	mutated.Write(src[:loc.lbrace+1])
+	mutated.WriteString("\n" + stmts)
+	mutated.Write(src[loc.lbrace+1:])
+	mutated.WriteString("\n" + decls)
+	formatted, err := format.Source(mutated.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to format mutated %s: %w", loc.file, err)
+	}
+	info, err := os.Stat(loc.file)
+	if err != nil {
+		return "", err
+	}
+	return loc.file, os.WriteFile(loc.file, formatted, info.Mode())
+}
@@ -0,0 +1,101 @@
+package mutation
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/stretchr/testify/require"
+)
+
+const testSource = `package service
+
+// Service handles requests.
+type Service struct{}
+
+// GetFlights returns all flights.
+func (s *Service) GetFlights() []string {
+	return nil
+}
+
+func GetFlights() []string {
+	return []string{"a"}
+}
+`
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("service.(*Service).GetFlights")
+	require.NoError(t, err)
+	require.Equal(t, Target{Package: "service", Receiver: "Service", Name: "GetFlights"}, target)
+	require.Equal(t, "service.Service.GetFlights", target.String())
+
+	target, err = ParseTarget("service.GetFlights")
+	require.NoError(t, err)
+	require.Equal(t, Target{Package: "service", Name: "GetFlights"}, target)
+
+	_, err = ParseTarget("GetFlights")
+	require.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	for _, kind := range Kinds() {
+		root := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "service"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "service", "service.go"), []byte(testSource), 0o644))
+		clone := t.TempDir()
+		require.NoError(t, CopyTree(root, clone))
+
+		mutant := Mutant{Target: Target{Package: "service", Receiver: "Service", Name: "GetFlights"}, Kind: kind, Severity: 3}
+		file, err := Apply(clone, mutant, 100)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(clone, "service", "service.go"), file)
+
+		mutated, err := os.ReadFile(file)
+		require.NoError(t, err)
+		_, err = parser.ParseFile(token.NewFileSet(), file, mutated, parser.AllErrors)
+		require.NoError(t, err)
+		// only the method is mutated
+		require.Equal(t, 1, strings.Count(string(mutated), "for perfMutationI"))
+		require.Less(t, strings.Index(string(mutated), "func (s *Service) GetFlights"), strings.Index(string(mutated), "for perfMutationI"))
+		require.Less(t, strings.Index(string(mutated), "for perfMutationI"), strings.Index(string(mutated), "func GetFlights"))
+
+		// the original source is not modified
+		original, err := os.ReadFile(filepath.Join(root, "service", "service.go"))
+		require.NoError(t, err)
+		require.Equal(t, testSource, string(original))
+	}
+
+	_, err := Apply(t.TempDir(), Mutant{Target: Target{Package: "service", Name: "Missing"}, Kind: KindAlloc, Severity: 1}, 1)
+	require.Error(t, err)
+}
+
+func TestScores(t *testing.T) {
+	target := Target{Package: "service", Name: "GetFlights"}
+	results := func(regressed ...string) []analysis.Result {
+		res := make([]analysis.Result, 0)
+		for _, fn := range []string{"service.BenchmarkA", "service.BenchmarkB"} {
+			classification := analysis.Unchanged
+			for _, r := range regressed {
+				if r == fn {
+					classification = analysis.Regressed
+				}
+			}
+			res = append(res, analysis.Result{Function: fn, Classification: classification})
+		}
+		return res
+	}
+	outcomes := []Outcome{
+		NewOutcome(Mutant{Target: target, Kind: KindBusyLoop, Severity: 1}, results()),
+		NewOutcome(Mutant{Target: target, Kind: KindBusyLoop, Severity: 100}, results("service.BenchmarkA")),
+	}
+	require.Equal(t, 1, Killed(outcomes))
+	require.Equal(t, []Score{{Name: "service.GetFlights", Mutants: 2, Detected: 1, Score: 0.5}}, FunctionScores(outcomes))
+	require.Equal(t, []Score{
+		{Name: "service.BenchmarkA", Mutants: 2, Detected: 1, Score: 0.5},
+		{Name: "service.BenchmarkB", Mutants: 2, Detected: 0, Score: 0},
+	}, BenchmarkScores(outcomes))
+}
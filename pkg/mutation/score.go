@@ -0,0 +1,130 @@
+package mutation
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+)
+
+// Outcome is the analysis of the suite run against a mutant (v1 is the
+// original source, v2 the mutant).
+type Outcome struct {
+	Mutant     Mutant
+	Benchmarks []string // all analyzed benchmarks
+	Detected   []string // benchmarks that classified the mutant as regression
+}
+
+func NewOutcome(mutant Mutant, results []analysis.Result) Outcome {
+	outcome := Outcome{Mutant: mutant, Benchmarks: make([]string, 0, len(results)), Detected: make([]string, 0)}
+	for _, r := range results {
+		outcome.Benchmarks = append(outcome.Benchmarks, r.Function)
+		if r.Classification == analysis.Regressed {
+			outcome.Detected = append(outcome.Detected, r.Function)
+		}
+	}
+	return outcome
+}
+
+// Killed reports whether at least one benchmark detected the mutant.
+func (o Outcome) Killed() bool {
+	return len(o.Detected) != 0
+}
+
+// Killed returns the amount of mutants that were detected by at least one
+// benchmark.
+func Killed(outcomes []Outcome) int {
+	killed := 0
+	for _, o := range outcomes {
+		if o.Killed() {
+			killed++
+		}
+	}
+	return killed
+}
+
+// Score is the share of detected mutants of a mutated function or of the
+// mutants that were detected by a benchmark.
+type Score struct {
+	Name     string
+	Mutants  int
+	Detected int
+	Score    float64
+}
+
+func sortedScores(scores map[string]*Score) []Score {
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]Score, 0, len(names))
+	for _, name := range names {
+		s := scores[name]
+		s.Score = float64(s.Detected) / float64(s.Mutants)
+		result = append(result, *s)
+	}
+	return result
+}
+
+// FunctionScores returns the mutation score of each mutated function: the
+// share of its mutants that were detected by at least one benchmark. A low
+// score indicates that the function needs (better) benchmarks.
+func FunctionScores(outcomes []Outcome) []Score {
+	scores := make(map[string]*Score)
+	for _, o := range outcomes {
+		name := o.Mutant.Target.String()
+		if scores[name] == nil {
+			scores[name] = &Score{Name: name}
+		}
+		scores[name].Mutants++
+		if o.Killed() {
+			scores[name].Detected++
+		}
+	}
+	return sortedScores(scores)
+}
+
+// BenchmarkScores returns the share of mutants that were detected by each
+// benchmark.
+func BenchmarkScores(outcomes []Outcome) []Score {
+	scores := make(map[string]*Score)
+	for _, o := range outcomes {
+		for _, benchmark := range o.Benchmarks {
+			if scores[benchmark] == nil {
+				scores[benchmark] = &Score{Name: benchmark}
+			}
+			scores[benchmark].Mutants++
+		}
+		for _, benchmark := range o.Detected {
+			scores[benchmark].Detected++
+		}
+	}
+	return sortedScores(scores)
+}
+
+var OutcomeTableHeader = []string{"function", "kind", "severity", "benchmarks", "detected", "detected by"}
+
+func OutcomeTableRows(outcomes []Outcome) [][]string {
+	rows := make([][]string, 0, len(outcomes))
+	for _, o := range outcomes {
+		rows = append(rows, []string{
+			o.Mutant.Target.String(), o.Mutant.Kind, strconv.Itoa(o.Mutant.Severity),
+			strconv.Itoa(len(o.Benchmarks)), strconv.Itoa(len(o.Detected)), strings.Join(o.Detected, " "),
+		})
+	}
+	return rows
+}
+
+var ScoreTableHeader = []string{"name", "mutants", "detected", "mutation score"}
+
+func ScoreTableRows(scores []Score) [][]string {
+	rows := make([][]string, 0, len(scores))
+	for _, s := range scores {
+		rows = append(rows, []string{
+			s.Name, strconv.Itoa(s.Mutants), strconv.Itoa(s.Detected), strconv.FormatFloat(s.Score, 'g', 6, 64),
+		})
+	}
+	return rows
+}
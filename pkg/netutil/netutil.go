@@ -12,11 +12,18 @@ func WaitForPortOpen(ctx context.Context, endpoint string) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-time.After(time.Second):
-			conn, err := net.DialTimeout("tcp4", endpoint, time.Second)
-			if err == nil {
-				_ = conn.Close()
+			if IsPortOpen(endpoint, time.Second) {
 				return nil
 			}
 		}
 	}
 }
+
+func IsPortOpen(endpoint string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp4", endpoint, timeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
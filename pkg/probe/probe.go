@@ -0,0 +1,193 @@
+package probe
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/netutil"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Config configures the readiness and liveness probes of a target. Without a
+// path the probe only checks if the port accepts TCP connections.
+type Config struct {
+	Path             string        `yaml:"path,omitempty"`
+	ExpectedStatus   int           `yaml:"expectedStatus,omitempty"`
+	Timeout          time.Duration `yaml:"timeout,omitempty"`
+	Interval         time.Duration `yaml:"interval,omitempty"`
+	FailureThreshold int           `yaml:"failureThreshold,omitempty"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ExpectedStatus:   http.StatusOK,
+		Timeout:          time.Second,
+		Interval:         time.Second,
+		FailureThreshold: 3,
+	}
+}
+
+// WithDefaults returns the config with all unset fields set to their defaults.
+func (c Config) WithDefaults() Config {
+	defaults := DefaultConfig()
+	if c.ExpectedStatus == 0 {
+		c.ExpectedStatus = defaults.ExpectedStatus
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaults.Timeout
+	}
+	if c.Interval == 0 {
+		c.Interval = defaults.Interval
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaults.FailureThreshold
+	}
+	return c
+}
+
+func (c Config) Validate() error {
+	var err error
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		err = multierror.Append(err, fmt.Errorf("probe path must start with /: %s", c.Path))
+	}
+	if c.ExpectedStatus < 100 || c.ExpectedStatus > 599 {
+		err = multierror.Append(err, fmt.Errorf("invalid expected probe status: %d", c.ExpectedStatus))
+	}
+	if c.Timeout <= 0 {
+		err = multierror.Append(err, fmt.Errorf("probe timeout must be positive"))
+	}
+	if c.Interval <= 0 {
+		err = multierror.Append(err, fmt.Errorf("probe interval must be positive"))
+	}
+	if c.FailureThreshold < 1 {
+		err = multierror.Append(err, fmt.Errorf("probe failure threshold must be at least 1"))
+	}
+	return err
+}
+
+// Check probes the endpoint once.
+func (c Config) Check(ctx context.Context, endpoint string) error {
+	if c.Path == "" {
+		if !netutil.IsPortOpen(endpoint, c.Timeout) {
+			return fmt.Errorf("%s does not accept connections", endpoint)
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s", endpoint, c.Path), nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode != c.ExpectedStatus {
+		return fmt.Errorf("%s%s returned status %d, expected %d", endpoint, c.Path, res.StatusCode, c.ExpectedStatus)
+	}
+	return nil
+}
+
+// WaitReady probes the endpoint every interval until it succeeds or the
+// context is done.
+func (c Config) WaitReady(ctx context.Context, endpoint string) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Check(ctx, endpoint); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Event is a change of the liveness of a target.
+type Event struct {
+	Target string
+	Time   time.Time
+	Alive  bool
+	Error  string
+}
+
+// Monitor probes the endpoint every interval until the context is done. After
+// FailureThreshold consecutive failed probes the target is considered down
+// and onChange is called. onChange is called again once the target recovers.
+func (c Config) Monitor(ctx context.Context, target, endpoint string, onChange func(Event)) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	alive := true
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := c.Check(ctx, endpoint)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			failures = 0
+			if !alive {
+				alive = true
+				onChange(Event{Target: target, Time: time.Now(), Alive: true})
+			}
+			continue
+		}
+		failures++
+		if alive && failures >= c.FailureThreshold {
+			alive = false
+			onChange(Event{Target: target, Time: time.Now(), Alive: false, Error: err.Error()})
+		}
+	}
+}
+
+// Recorder collects the liveness events of several targets.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *Recorder) Record(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// Events returns a copy of the recorded events.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// WriteCSV writes the recorded events as CSV.
+func (r *Recorder) WriteCSV(w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write([]string{"target", "time", "alive", "error"}); err != nil {
+		return err
+	}
+	for _, e := range r.Events() {
+		record := []string{e.Target, e.Time.UTC().Format(time.RFC3339Nano), strconv.FormatBool(e.Alive), e.Error}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
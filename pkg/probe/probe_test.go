@@ -0,0 +1,66 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbe(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	config := DefaultConfig()
+	config.Path = "/health"
+	config.Interval = 10 * time.Millisecond
+	config.FailureThreshold = 2
+	require.NoError(t, config.Validate())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.Error(t, config.Check(ctx, endpoint))
+	tcpConfig := config
+	tcpConfig.Path = ""
+	require.NoError(t, tcpConfig.Check(ctx, endpoint))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		healthy.Store(true)
+	}()
+	require.NoError(t, config.WaitReady(ctx, endpoint))
+
+	recorder := &Recorder{}
+	monitorCtx, stopMonitor := context.WithCancel(ctx)
+	changed := make(chan Event, 2)
+	go config.Monitor(monitorCtx, "v1", endpoint, func(e Event) {
+		recorder.Record(e)
+		changed <- e
+	})
+	healthy.Store(false)
+	require.False(t, (<-changed).Alive)
+	healthy.Store(true)
+	require.True(t, (<-changed).Alive)
+	stopMonitor()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, recorder.WriteCSV(buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasPrefix(lines[1], "v1,"))
+	require.Contains(t, lines[1], ",false,")
+}
@@ -0,0 +1,150 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark/output"
+	"github.com/google/pprof/profile"
+)
+
+// Stack is a sampled call stack of a profile. The functions are ordered from
+// the root (caller) to the leaf (callee).
+type Stack struct {
+	Functions []string
+	Value     int64 // e.g. cpu time in nanoseconds
+}
+
+// ReadStacks parses a pprof profile (e.g. a cpu profile created by the
+// profiling mode of the runners) and returns its call stacks. The last sample
+// type of the profile is used as value (cpu/nanoseconds for cpu profiles).
+func ReadStacks(r io.Reader) ([]Stack, error) {
+	p, err := profile.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	valueIndex := len(p.SampleType) - 1
+	stacks := make([]Stack, 0, len(p.Sample))
+	for _, sample := range p.Sample {
+		if valueIndex < 0 || valueIndex >= len(sample.Value) {
+			continue
+		}
+		stack := Stack{Value: sample.Value[valueIndex]}
+		// the first location is the leaf and the last line of a location is
+		// the caller of the inlined functions
+		for i := len(sample.Location) - 1; i >= 0; i-- {
+			lines := sample.Location[i].Line
+			for j := len(lines) - 1; j >= 0; j-- {
+				if lines[j].Function != nil {
+					stack.Functions = append(stack.Functions, lines[j].Function.Name)
+				}
+			}
+		}
+		stacks = append(stacks, stack)
+	}
+	return stacks, nil
+}
+
+// Functions returns all functions of the stacks that are accepted by filter.
+func Functions(stacks []Stack, filter func(fn string) bool) map[string]bool {
+	functions := make(map[string]bool)
+	for _, stack := range stacks {
+		for _, fn := range stack.Functions {
+			if filter(fn) {
+				functions[fn] = true
+			}
+		}
+	}
+	return functions
+}
+
+// HotPath returns the parts of the stacks that were called by a function
+// matching root (e.g. the http handler of an endpoint). Stacks without a
+// matching function are dropped.
+func HotPath(stacks []Stack, root *regexp.Regexp) []Stack {
+	res := make([]Stack, 0)
+	for _, stack := range stacks {
+		for i, fn := range stack.Functions {
+			if root.MatchString(fn) {
+				res = append(res, Stack{Functions: stack.Functions[i:], Value: stack.Value})
+				break
+			}
+		}
+	}
+	return res
+}
+
+// Coverage returns the share of the total value of the stacks that passes
+// through at least one of the given functions.
+func Coverage(stacks []Stack, functions map[string]bool) float64 {
+	var total, covered int64
+	for _, stack := range stacks {
+		total += stack.Value
+		for _, fn := range stack.Functions {
+			if functions[fn] {
+				covered += stack.Value
+				break
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(covered) / float64(total)
+}
+
+// ReadLocation reads all pprof profiles (*.out) stored at the given location
+// (file, directory or bucket prefix). The stacks are keyed by the file name
+// without extension, e.g. the function name for microbenchmark profiles.
+func ReadLocation(ctx context.Context, location string) (map[string][]Stack, error) {
+	source, err := output.NewSource(location)
+	if err != nil {
+		return nil, err
+	}
+	names, err := source.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string][]Stack)
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".out") {
+			continue
+		}
+		stacks, err := readStacksFromSource(ctx, source, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		profiles[strings.TrimSuffix(path.Base(name), ".out")] = stacks
+	}
+	return profiles, nil
+}
+
+func readStacksFromSource(ctx context.Context, source output.Source, name string) ([]Stack, error) {
+	reader, err := source.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ReadStacks(reader)
+}
+
+// IsStandardLibrary reports whether the function (e.g. "strings.(*Builder).Grow"
+// or "github.com/org/repo/pkg.Func") belongs to the Go standard library or
+// the runtime. Functions of main packages are not part of the standard library.
+func IsStandardLibrary(fn string) bool {
+	importPath := fn
+	if i := strings.LastIndex(importPath, "/"); i >= 0 {
+		// the package name of the last element ends at the first dot
+		if j := strings.Index(importPath[i:], "."); j >= 0 {
+			importPath = importPath[:i+j]
+		}
+	} else if j := strings.Index(importPath, "."); j >= 0 {
+		importPath = importPath[:j]
+	}
+	firstElement, _, _ := strings.Cut(importPath, "/")
+	return importPath != "main" && !strings.Contains(firstElement, ".")
+}
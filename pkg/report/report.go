@@ -0,0 +1,273 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/application/latency"
+	"github.com/christophwitzko/masters-thesis/pkg/hostinfo"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/christophwitzko/masters-thesis/pkg/svgplot"
+)
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+// Experiment contains all data of one experiment that is rendered into the report.
+type Experiment struct {
+	Title string
+	// Metric of the analysis and the function plots (ops, bytes or allocs),
+	// defaults to ops.
+	Metric      string
+	Metadata    []*microbenchmark.Metadata
+	Results     microbenchmark.Results
+	Analysis    []analysis.Result
+	Latencies   latency.Samples
+	GeneratedAt time.Time
+}
+
+type summaryRow struct {
+	Key, Value string
+}
+
+type analysisRow struct {
+	analysis.Result
+	RatioStr, CIStr string
+}
+
+type plot struct {
+	Title string
+	SVG   template.HTML
+}
+
+type templateData struct {
+	Title       string
+	GeneratedAt string
+	Summary     []summaryRow
+	Analysis    []analysisRow
+	ForestPlot  template.HTML
+	Functions   []plot
+	Endpoints   []plot
+}
+
+var classificationColors = map[string]string{
+	analysis.Improved:  "#2ca02c",
+	analysis.Regressed: "#d62728",
+	analysis.Unchanged: "#7f7f7f",
+}
+
+// distinct returns the sorted distinct non-empty values.
+func distinct(values []string) string {
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	sort.Strings(res)
+	return strings.Join(res, ", ")
+}
+
+func versionString(v microbenchmark.VersionInfo) string {
+	if v.Commit == "" {
+		return v.Reference
+	}
+	commit := v.Commit
+	if len(commit) > 8 {
+		commit = commit[:8]
+	}
+	return fmt.Sprintf("%s (%s)", v.Reference, commit)
+}
+
+// cpuString returns the CPU model and cores of the host or an empty string if
+// they are unknown.
+func cpuString(host hostinfo.Info) string {
+	switch {
+	case host.CPUCount == 0:
+		return host.CPUModel
+	case host.CPUModel == "":
+		return fmt.Sprintf("%d cores", host.CPUCount)
+	default:
+		return fmt.Sprintf("%s (%d cores)", host.CPUModel, host.CPUCount)
+	}
+}
+
+func metadataSummary(metadata []*microbenchmark.Metadata) []summaryRow {
+	var repositories, v1, v2, instanceTypes, zones, cpus, goVersions, runs, suiteRuns []string
+	var start, end time.Time
+	for _, m := range metadata {
+		repositories = append(repositories, m.Repository)
+		v1 = append(v1, versionString(m.V1))
+		v2 = append(v2, versionString(m.V2))
+		instanceTypes = append(instanceTypes, m.Host.InstanceType)
+		zones = append(zones, m.Host.Zone)
+		cpus = append(cpus, cpuString(m.Host))
+		goVersions = append(goVersions, m.GoVersion)
+		runs = append(runs, fmt.Sprint(m.RunIndex))
+		suiteRuns = append(suiteRuns, fmt.Sprint(m.SuiteRuns))
+		if start.IsZero() || m.StartTime.Before(start) {
+			start = m.StartTime
+		}
+		if m.EndTime.After(end) {
+			end = m.EndTime
+		}
+	}
+	rows := []summaryRow{
+		{"Repository", distinct(repositories)},
+		{"Version 1", distinct(v1)},
+		{"Version 2", distinct(v2)},
+		{"Runs (instances)", fmt.Sprint(len(metadata))},
+		{"Run indices", distinct(runs)},
+		{"Suite runs", distinct(suiteRuns)},
+		{"Instance types", distinct(instanceTypes)},
+		{"Zones", distinct(zones)},
+		{"CPUs", distinct(cpus)},
+		{"Go versions", distinct(goVersions)},
+	}
+	if !start.IsZero() {
+		rows = append(rows, summaryRow{"Start", start.UTC().Format(time.RFC3339)})
+	}
+	if !end.IsZero() {
+		rows = append(rows, summaryRow{"End", end.UTC().Format(time.RFC3339)})
+	}
+	return rows
+}
+
+func summary(e Experiment) []summaryRow {
+	rows := metadataSummary(e.Metadata)
+	if len(e.Results) != 0 {
+		counts := make(map[string]int)
+		for _, a := range e.Analysis {
+			counts[a.Classification]++
+		}
+		rows = append(rows,
+			summaryRow{"Microbenchmark trials", fmt.Sprint(len(e.Results))},
+			summaryRow{"Functions", fmt.Sprintf("%d (%d improved, %d regressed, %d unchanged)",
+				len(e.Analysis), counts[analysis.Improved], counts[analysis.Regressed], counts[analysis.Unchanged])},
+		)
+	}
+	if len(e.Latencies) != 0 {
+		rows = append(rows, summaryRow{"Application benchmark endpoints", fmt.Sprint(len(e.Latencies.Endpoints()))})
+		if start, end, ok := e.Latencies.Window(); ok {
+			rows = append(rows, summaryRow{"Application benchmark overlap", fmt.Sprintf("%ds", end-start)})
+		}
+	}
+	// hide empty rows (e.g. missing metadata)
+	filtered := make([]summaryRow, 0, len(rows))
+	for _, row := range rows {
+		if row.Value != "" {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered
+}
+
+func functionPlots(results microbenchmark.Results, metric string) []plot {
+	grouped := make(map[string]map[int][]float64)
+	for _, r := range results {
+		fn := r.Function.String()
+		if grouped[fn] == nil {
+			grouped[fn] = make(map[int][]float64)
+		}
+		grouped[fn][r.Version] = append(grouped[fn][r.Version], analysis.MetricValue(metric, r))
+	}
+	functions := make([]string, 0, len(grouped))
+	for fn := range grouped {
+		functions = append(functions, fn)
+	}
+	sort.Strings(functions)
+	plots := make([]plot, 0, len(functions))
+	for _, fn := range functions {
+		svg := svgplot.StripPlot(fn, analysis.MetricUnit(metric), []svgplot.Group{
+			{Name: "v1", Values: grouped[fn][1]},
+			{Name: "v2", Values: grouped[fn][2]},
+		})
+		plots = append(plots, plot{Title: fn, SVG: template.HTML(svg)})
+	}
+	return plots
+}
+
+func endpointPlots(samples latency.Samples) []plot {
+	if len(samples) == 0 {
+		return nil
+	}
+	// timestamps are relative to the first sample of all versions
+	origin := samples[0].Timestamp
+	for _, s := range samples {
+		if s.Timestamp < origin {
+			origin = s.Timestamp
+		}
+	}
+	var highlight *svgplot.Range
+	if start, end, ok := samples.Window(); ok {
+		highlight = &svgplot.Range{Label: "all versions under load", From: float64(start - origin), To: float64(end - origin)}
+	}
+	plots := make([]plot, 0)
+	for _, endpoint := range samples.Endpoints() {
+		series := make([]svgplot.Series, 0)
+		for _, version := range samples.Versions() {
+			s := svgplot.Series{Name: version}
+			for _, sample := range samples {
+				if sample.Endpoint != endpoint || sample.Version != version {
+					continue
+				}
+				s.X = append(s.X, float64(sample.Timestamp-origin))
+				s.Y = append(s.Y, sample.Latency)
+			}
+			series = append(series, s)
+		}
+		svg := svgplot.ScatterPlot(endpoint, "time [s]", "median latency [ms]", series, highlight)
+		plots = append(plots, plot{Title: endpoint, SVG: template.HTML(svg)})
+	}
+	return plots
+}
+
+// Write renders the experiment as self-contained HTML document (no external
+// resources are referenced).
+func Write(w io.Writer, e Experiment) error {
+	tmpl, err := template.New("report").Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	metric := e.Metric
+	if metric == "" {
+		metric = analysis.DefaultConfig().Metric
+	}
+	if !analysis.IsValidMetric(metric) {
+		return fmt.Errorf("unsupported metric: %s", metric)
+	}
+	data := templateData{
+		Title:       e.Title,
+		GeneratedAt: e.GeneratedAt.UTC().Format(time.RFC3339),
+		Summary:     summary(e),
+		Functions:   functionPlots(e.Results, metric),
+		Endpoints:   endpointPlots(e.Latencies),
+	}
+	estimates := make([]svgplot.Estimate, 0, len(e.Analysis))
+	for _, a := range e.Analysis {
+		data.Analysis = append(data.Analysis, analysisRow{
+			Result:   a,
+			RatioStr: fmt.Sprintf("%.4f", a.Ratio),
+			CIStr:    fmt.Sprintf("[%.4f, %.4f]", a.CILower, a.CIUpper),
+		})
+		estimates = append(estimates, svgplot.Estimate{
+			Label: a.Function,
+			Value: a.Ratio,
+			Lower: a.CILower,
+			Upper: a.CIUpper,
+			Color: classificationColors[a.Classification],
+		})
+	}
+	if len(estimates) != 0 {
+		data.ForestPlot = template.HTML(svgplot.ForestPlot("median ratio v2/v1 with confidence interval", "ratio v2/v1", estimates, 1))
+	}
+	return tmpl.Execute(w, data)
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/analysis"
+	"github.com/christophwitzko/masters-thesis/pkg/application/latency"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	fn := microbenchmark.Function{Name: "BenchmarkA", PackageName: "pkg"}
+	results := make(microbenchmark.Results, 0)
+	for i := 0; i < 10; i++ {
+		results = append(results,
+			microbenchmark.Result{Function: fn, Ops: 1 + float64(i)/100, Version: 1},
+			microbenchmark.Result{Function: fn, Ops: 2 + float64(i)/100, Version: 2},
+		)
+	}
+	config := analysis.DefaultConfig()
+	config.Iterations = 100
+	analysisResults, err := analysis.Analyze(results, config)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	err = Write(buf, Experiment{
+		Title:    "test <report>",
+		Results:  results,
+		Analysis: analysisResults,
+		Latencies: latency.Samples{
+			{Version: "v1", Endpoint: "GET /a", Timestamp: 10, Count: 1, Latency: 1},
+			{Version: "v2", Endpoint: "GET /a", Timestamp: 11, Count: 1, Latency: 2},
+		},
+		GeneratedAt: time.Unix(0, 0),
+	})
+	require.NoError(t, err)
+	html := buf.String()
+	require.Contains(t, html, "<title>test &lt;report&gt;</title>")
+	require.Contains(t, html, `<td class="regressed">regressed</td>`)
+	require.Contains(t, html, "<svg")
+	require.NotContains(t, html, "<script")
+	require.NotContains(t, html, "<link")
+	require.NotContains(t, html, "1.5999999999999999")
+}
+
+func TestWriteMetric(t *testing.T) {
+	fn := microbenchmark.Function{Name: "BenchmarkA", PackageName: "pkg"}
+	results := microbenchmark.Results{
+		{Function: fn, Ops: 1, Bytes: 128, Version: 1},
+		{Function: fn, Ops: 1, Bytes: 256, Version: 2},
+	}
+	config := analysis.DefaultConfig()
+	config.Metric = "bytes"
+	config.Iterations = 100
+	analysisResults, err := analysis.Analyze(results, config)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	err = Write(buf, Experiment{
+		Metric:   config.Metric,
+		Results:  results,
+		Analysis: analysisResults,
+		Metadata: []*microbenchmark.Metadata{{Repository: "repo"}},
+	})
+	require.NoError(t, err)
+	html := buf.String()
+	require.Contains(t, html, "B/op")
+	require.NotContains(t, html, "sec/op")
+	require.NotContains(t, html, "CPUs")
+
+	require.Error(t, Write(&bytes.Buffer{}, Experiment{Metric: "foo"}))
+}
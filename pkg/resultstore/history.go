@@ -0,0 +1,134 @@
+package resultstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/stats"
+)
+
+var metricColumns = map[string]string{
+	"ops":    "trials.sec_per_op",
+	"bytes":  "trials.bytes_per_op",
+	"allocs": "trials.allocs_per_op",
+}
+
+// Commit is a benchmarked version of the repository.
+type Commit struct {
+	Reference string
+	Hash      string
+	// Time is the start of the first run that benchmarked the commit
+	Time time.Time
+	// version (1 or 2) of the commit in its first run, used to order the
+	// commits of the same run
+	version int
+}
+
+// ID returns the commit hash or the reference if the hash is unknown.
+func (c Commit) ID() string {
+	if c.Hash != "" {
+		return c.Hash
+	}
+	return c.Reference
+}
+
+func (c Commit) before(other Commit) bool {
+	if !c.Time.Equal(other.Time) {
+		return c.Time.Before(other.Time)
+	}
+	return c.version < other.version
+}
+
+// Series is the history of a benchmark function: the median of all trials of
+// each commit in chronological order.
+type Series struct {
+	Function string
+	Commits  []Commit
+	Medians  []float64
+	Trials   []int
+}
+
+type historyKey struct {
+	Function string
+	Commit   string
+}
+
+// History returns the per-commit medians of the metric (ops, bytes or allocs)
+// of all functions across all stored runs that match the filter.
+func (s *Store) History(ctx context.Context, filter QueryFilter, metric string) ([]Series, error) {
+	column, ok := metricColumns[metric]
+	if !ok {
+		return nil, fmt.Errorf("unsupported metric: %s", metric)
+	}
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT functions.package || '.' || functions.name, versions.reference,
+		versions.commit_hash, versions.version, runs.start_time, `+column+`
+	FROM trials
+	JOIN functions ON functions.id = trials.function_id
+	JOIN versions ON versions.id = trials.version_id
+	JOIN runs ON runs.id = versions.run_id
+	WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	commits := make(map[string]Commit)
+	values := make(map[historyKey][]float64)
+	for rows.Next() {
+		var fn, startTime string
+		var commit Commit
+		var value float64
+		if err := rows.Scan(&fn, &commit.Reference, &commit.Hash, &commit.version, &startTime, &value); err != nil {
+			return nil, err
+		}
+		commit.Time, err = time.Parse(time.RFC3339Nano, startTime)
+		if err != nil {
+			return nil, err
+		}
+		if known, ok := commits[commit.ID()]; !ok || commit.before(known) {
+			commits[commit.ID()] = commit
+		}
+		key := historyKey{Function: fn, Commit: commit.ID()}
+		values[key] = append(values[key], value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buildSeries(commits, values), nil
+}
+
+func buildSeries(commits map[string]Commit, values map[historyKey][]float64) []Series {
+	orderedCommits := make([]Commit, 0, len(commits))
+	for _, commit := range commits {
+		orderedCommits = append(orderedCommits, commit)
+	}
+	sort.Slice(orderedCommits, func(i, j int) bool {
+		return orderedCommits[i].before(orderedCommits[j])
+	})
+	functions := make(map[string]bool)
+	for key := range values {
+		functions[key.Function] = true
+	}
+	names := make([]string, 0, len(functions))
+	for fn := range functions {
+		names = append(names, fn)
+	}
+	sort.Strings(names)
+	series := make([]Series, 0, len(names))
+	for _, fn := range names {
+		fnSeries := Series{Function: fn}
+		for _, commit := range orderedCommits {
+			data, ok := values[historyKey{Function: fn, Commit: commit.ID()}]
+			if !ok {
+				continue
+			}
+			fnSeries.Commits = append(fnSeries.Commits, commit)
+			fnSeries.Medians = append(fnSeries.Medians, stats.Median(data))
+			fnSeries.Trials = append(fnSeries.Trials, len(data))
+		}
+		series = append(series, fnSeries)
+	}
+	return series
+}
@@ -0,0 +1,81 @@
+package resultstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+)
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// IsIngested reports whether the run with the given index of the source
+// (e.g. the metadata sidecar gs://cbc-results/mb/exp/run-1.csv.meta.json) was
+// already ingested.
+func (s *Store) IsIngested(ctx context.Context, source string, runIndex int) (bool, error) {
+	return isIngested(ctx, s.db, source, runIndex)
+}
+
+func isIngested(ctx context.Context, db queryer, source string, runIndex int) (bool, error) {
+	var runID int64
+	err := db.QueryRowContext(ctx, "SELECT run_id FROM ingestions WHERE source = ? AND run_index = ?", source, runIndex).Scan(&runID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Ingest stores a finished run of an experiment together with its results.
+// The run is recorded as ingested, hence the same run of a source is only
+// stored once. The returned bool is false if the run was already ingested.
+// The run is stored in a single transaction, so a failed ingestion leaves
+// nothing behind and can be retried.
+func (s *Store) Ingest(ctx context.Context, source, label string, metadata *microbenchmark.Metadata, results microbenchmark.Results) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	ingested, err := isIngested(ctx, tx, source, metadata.RunIndex)
+	if err != nil || ingested {
+		return false, err
+	}
+	run, err := createRun(ctx, tx, label, metadata)
+	if err != nil {
+		return false, err
+	}
+	if err := addRunResults(ctx, tx, run, results); err != nil {
+		return false, err
+	}
+	endTime := metadata.EndTime
+	if endTime.IsZero() {
+		endTime = time.Now()
+	}
+	if err := finishRun(ctx, tx, run, endTime); err != nil {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO ingestions (source, run_index, run_id) VALUES (?, ?, ?)", source, metadata.RunIndex, run.ID)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func addRunResults(ctx context.Context, tx *sql.Tx, run *Run, results microbenchmark.Results) error {
+	inserter, err := newTrialInserter(ctx, tx)
+	if err != nil {
+		return err
+	}
+	defer inserter.Close()
+	for _, result := range results {
+		if err := inserter.add(ctx, run, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package resultstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/christophwitzko/masters-thesis/pkg/stats"
+)
+
+// Query is a canned query that returns a table.
+type Query struct {
+	Name        string
+	Description string
+	Run         func(ctx context.Context, s *Store, filter QueryFilter) (header []string, rows [][]string, err error)
+}
+
+// QueryFilter restricts the runs that are considered by a query.
+type QueryFilter struct {
+	Label string
+}
+
+func (f QueryFilter) where() (string, []any) {
+	if f.Label == "" {
+		return "1 = 1", nil
+	}
+	return "runs.label = ?", []any{f.Label}
+}
+
+var Queries = []Query{
+	{
+		Name:        "runs",
+		Description: "list all stored runs",
+		Run:         queryRuns,
+	},
+	{
+		Name:        "medians",
+		Description: "per-function medians per version (reference and commit) across all stored runs",
+		Run:         queryMedians,
+	},
+	{
+		Name:        "ratios",
+		Description: "per-function median v2/v1 sec/op ratios per version pair across all stored runs",
+		Run:         queryRatios,
+	},
+}
+
+func FindQuery(name string) (Query, error) {
+	for _, q := range Queries {
+		if q.Name == name {
+			return q, nil
+		}
+	}
+	return Query{}, fmt.Errorf("unknown query: %s", name)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
+
+func queryRuns(ctx context.Context, s *Store, filter QueryFilter) ([]string, [][]string, error) {
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT runs.id, runs.label, runs.run_index, v1.reference, v1.commit_hash,
+		v2.reference, v2.commit_hash, runs.instance_type, runs.start_time, COALESCE(runs.end_time, ''),
+		(SELECT COUNT(*) FROM trials WHERE trials.version_id IN (v1.id, v2.id))
+	FROM runs
+	JOIN versions v1 ON v1.run_id = runs.id AND v1.version = 1
+	JOIN versions v2 ON v2.run_id = runs.id AND v2.version = 2
+	WHERE `+where+` ORDER BY runs.id`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	header := []string{"id", "label", "run", "v1", "v1 commit", "v2", "v2 commit", "instance type", "start", "end", "trials"}
+	result := make([][]string, 0)
+	for rows.Next() {
+		var id, runIndex, trials int64
+		var label, v1Ref, v1Commit, v2Ref, v2Commit, instanceType, start, end string
+		err := rows.Scan(&id, &label, &runIndex, &v1Ref, &v1Commit, &v2Ref, &v2Commit, &instanceType, &start, &end, &trials)
+		if err != nil {
+			return nil, nil, err
+		}
+		result = append(result, []string{
+			strconv.FormatInt(id, 10), label, strconv.FormatInt(runIndex, 10),
+			v1Ref, shortCommit(v1Commit), v2Ref, shortCommit(v2Commit),
+			instanceType, start, end, strconv.FormatInt(trials, 10),
+		})
+	}
+	return header, result, rows.Err()
+}
+
+type medianKey struct {
+	Function  string
+	Reference string
+	Commit    string
+}
+
+type sample struct {
+	ops, bytes, allocs []float64
+}
+
+func queryMedians(ctx context.Context, s *Store, filter QueryFilter) ([]string, [][]string, error) {
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT functions.package || '.' || functions.name, versions.reference,
+		versions.commit_hash, trials.sec_per_op, trials.bytes_per_op, trials.allocs_per_op
+	FROM trials
+	JOIN functions ON functions.id = trials.function_id
+	JOIN versions ON versions.id = trials.version_id
+	JOIN runs ON runs.id = versions.run_id
+	WHERE `+where, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	samples := make(map[medianKey]*sample)
+	for rows.Next() {
+		var key medianKey
+		var ops, bytes, allocs float64
+		if err := rows.Scan(&key.Function, &key.Reference, &key.Commit, &ops, &bytes, &allocs); err != nil {
+			return nil, nil, err
+		}
+		if samples[key] == nil {
+			samples[key] = &sample{}
+		}
+		samples[key].ops = append(samples[key].ops, ops)
+		samples[key].bytes = append(samples[key].bytes, bytes)
+		samples[key].allocs = append(samples[key].allocs, allocs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	keys := make([]medianKey, 0, len(samples))
+	for key := range samples {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Function != keys[j].Function {
+			return keys[i].Function < keys[j].Function
+		}
+		if keys[i].Reference != keys[j].Reference {
+			return keys[i].Reference < keys[j].Reference
+		}
+		return keys[i].Commit < keys[j].Commit
+	})
+	header := []string{"function", "reference", "commit", "n", "median sec/op", "median B/op", "median allocs/op"}
+	result := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		sample := samples[key]
+		result = append(result, []string{
+			key.Function, key.Reference, shortCommit(key.Commit), strconv.Itoa(len(sample.ops)),
+			formatFloat(stats.Median(sample.ops)), formatFloat(stats.Median(sample.bytes)), formatFloat(stats.Median(sample.allocs)),
+		})
+	}
+	return header, result, nil
+}
+
+type ratioKey struct {
+	Function string
+	V1, V2   string
+}
+
+func queryRatios(ctx context.Context, s *Store, filter QueryFilter) ([]string, [][]string, error) {
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT functions.package || '.' || functions.name, v1.reference, v2.reference,
+		versions.version, trials.sec_per_op
+	FROM trials
+	JOIN functions ON functions.id = trials.function_id
+	JOIN versions ON versions.id = trials.version_id
+	JOIN runs ON runs.id = versions.run_id
+	JOIN versions v1 ON v1.run_id = runs.id AND v1.version = 1
+	JOIN versions v2 ON v2.run_id = runs.id AND v2.version = 2
+	WHERE `+where, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	samples := make(map[ratioKey]map[int][]float64)
+	for rows.Next() {
+		var key ratioKey
+		var version int
+		var ops float64
+		if err := rows.Scan(&key.Function, &key.V1, &key.V2, &version, &ops); err != nil {
+			return nil, nil, err
+		}
+		if samples[key] == nil {
+			samples[key] = make(map[int][]float64)
+		}
+		samples[key][version] = append(samples[key][version], ops)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	keys := make([]ratioKey, 0, len(samples))
+	for key := range samples {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].V1 != keys[j].V1 {
+			return keys[i].V1 < keys[j].V1
+		}
+		if keys[i].V2 != keys[j].V2 {
+			return keys[i].V2 < keys[j].V2
+		}
+		return keys[i].Function < keys[j].Function
+	})
+	header := []string{"v1", "v2", "function", "n v1", "n v2", "median v1 sec/op", "median v2 sec/op", "ratio v2/v1"}
+	result := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		medianV1 := stats.Median(samples[key][1])
+		medianV2 := stats.Median(samples[key][2])
+		result = append(result, []string{
+			key.V1, key.V2, key.Function,
+			strconv.Itoa(len(samples[key][1])), strconv.Itoa(len(samples[key][2])),
+			formatFloat(medianV1), formatFloat(medianV2), formatFloat(medianV2 / medianV1),
+		})
+	}
+	return header, result, nil
+}
@@ -0,0 +1,290 @@
+package resultstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	// pure go sqlite driver (no cgo required)
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY,
+	label TEXT NOT NULL DEFAULT '',
+	repository TEXT NOT NULL DEFAULT '',
+	run_index INTEGER NOT NULL DEFAULT 0,
+	suite_runs INTEGER NOT NULL DEFAULT 0,
+	seed INTEGER NOT NULL DEFAULT 0,
+	runner TEXT NOT NULL DEFAULT '',
+	hostname TEXT NOT NULL DEFAULT '',
+	kernel TEXT NOT NULL DEFAULT '',
+	cpu_model TEXT NOT NULL DEFAULT '',
+	cpu_count INTEGER NOT NULL DEFAULT 0,
+	memory_total INTEGER NOT NULL DEFAULT 0,
+	instance_type TEXT NOT NULL DEFAULT '',
+	zone TEXT NOT NULL DEFAULT '',
+	go_version TEXT NOT NULL DEFAULT '',
+	metadata TEXT NOT NULL DEFAULT '{}',
+	start_time TEXT NOT NULL,
+	end_time TEXT
+);
+CREATE TABLE IF NOT EXISTS versions (
+	id INTEGER PRIMARY KEY,
+	run_id INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	version INTEGER NOT NULL,
+	reference TEXT NOT NULL DEFAULT '',
+	commit_hash TEXT NOT NULL DEFAULT '',
+	UNIQUE(run_id, version)
+);
+CREATE TABLE IF NOT EXISTS functions (
+	id INTEGER PRIMARY KEY,
+	package TEXT NOT NULL,
+	name TEXT NOT NULL,
+	file_name TEXT NOT NULL,
+	UNIQUE(package, name, file_name)
+);
+CREATE TABLE IF NOT EXISTS trials (
+	id INTEGER PRIMARY KEY,
+	version_id INTEGER NOT NULL REFERENCES versions(id) ON DELETE CASCADE,
+	function_id INTEGER NOT NULL REFERENCES functions(id),
+	r INTEGER NOT NULL,
+	s INTEGER NOT NULL,
+	i INTEGER NOT NULL,
+	iterations INTEGER NOT NULL,
+	sec_per_op REAL NOT NULL,
+	bytes_per_op REAL NOT NULL,
+	allocs_per_op REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS trials_function_idx ON trials(function_id, version_id);
+CREATE TABLE IF NOT EXISTS ingestions (
+	source TEXT NOT NULL,
+	run_index INTEGER NOT NULL,
+	run_id INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	UNIQUE(source, run_index)
+);
+`
+
+// Store is a SQLite database containing microbenchmark results of many runs.
+type Store struct {
+	db *sql.DB
+}
+
+func Open(ctx context.Context, fileName string) (*Store, error) {
+	db, err := sql.Open("sqlite3", "file:"+fileName)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite only supports a single writer
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func formatTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Run is a single microbenchmark run (one runner instance) stored in the database.
+type Run struct {
+	ID       int64
+	Versions map[int]int64 // version (1 or 2) -> version id
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// CreateRun stores the metadata of a new run. The metadata may be nil if unknown.
+func (s *Store) CreateRun(ctx context.Context, label string, metadata *microbenchmark.Metadata) (*Run, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	run, err := createRun(ctx, tx, label, metadata)
+	if err != nil {
+		return nil, err
+	}
+	return run, tx.Commit()
+}
+
+func createRun(ctx context.Context, tx execer, label string, metadata *microbenchmark.Metadata) (*Run, error) {
+	if metadata == nil {
+		metadata = &microbenchmark.Metadata{StartTime: time.Now()}
+	}
+	startTime := metadata.StartTime
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO runs (
+		label, repository, run_index, suite_runs, seed, runner, hostname, kernel, cpu_model, cpu_count,
+		memory_total, instance_type, zone, go_version, metadata, start_time, end_time
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		label, metadata.Repository, metadata.RunIndex, metadata.SuiteRuns, metadata.Seed, metadata.Runner,
+		metadata.Host.Hostname, metadata.Host.Kernel, metadata.Host.CPUModel, metadata.Host.CPUCount,
+		int64(metadata.Host.MemoryTotal), metadata.Host.InstanceType, metadata.Host.Zone, metadata.GoVersion,
+		string(metadataJSON), formatTime(startTime), formatTime(metadata.EndTime),
+	)
+	if err != nil {
+		return nil, err
+	}
+	run := &Run{Versions: make(map[int]int64)}
+	run.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	for version, info := range map[int]microbenchmark.VersionInfo{1: metadata.V1, 2: metadata.V2} {
+		res, err := tx.ExecContext(ctx, "INSERT INTO versions (run_id, version, reference, commit_hash) VALUES (?, ?, ?, ?)",
+			run.ID, version, info.Reference, info.Commit)
+		if err != nil {
+			return nil, err
+		}
+		run.Versions[version], err = res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+// FinishRun sets the end timestamp of the run.
+func (s *Store) FinishRun(ctx context.Context, run *Run, endTime time.Time) error {
+	return finishRun(ctx, s.db, run, endTime)
+}
+
+func finishRun(ctx context.Context, db execer, run *Run, endTime time.Time) error {
+	_, err := db.ExecContext(ctx, "UPDATE runs SET end_time = ? WHERE id = ?", formatTime(endTime), run.ID)
+	return err
+}
+
+// trialInserter adds trials with prepared statements and caches the ids of
+// the functions.
+type trialInserter struct {
+	selectFunction *sql.Stmt
+	insertFunction *sql.Stmt
+	insertTrial    *sql.Stmt
+	functions      map[microbenchmark.Function]int64
+}
+
+// preparer is implemented by *sql.DB and *sql.Tx.
+type preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+func newTrialInserter(ctx context.Context, db preparer) (*trialInserter, error) {
+	t := &trialInserter{functions: make(map[microbenchmark.Function]int64)}
+	var err error
+	if t.selectFunction, err = db.PrepareContext(ctx, "SELECT id FROM functions WHERE package = ? AND name = ? AND file_name = ?"); err != nil {
+		return nil, err
+	}
+	if t.insertFunction, err = db.PrepareContext(ctx, "INSERT INTO functions (package, name, file_name) VALUES (?, ?, ?)"); err != nil {
+		return nil, merror.MaybeMultiError(err, t.Close())
+	}
+	t.insertTrial, err = db.PrepareContext(ctx, `INSERT INTO trials (
+		version_id, function_id, r, s, i, iterations, sec_per_op, bytes_per_op, allocs_per_op
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, merror.MaybeMultiError(err, t.Close())
+	}
+	return t, nil
+}
+
+func (t *trialInserter) functionID(ctx context.Context, fn microbenchmark.Function) (int64, error) {
+	if id, ok := t.functions[fn]; ok {
+		return id, nil
+	}
+	var id int64
+	err := t.selectFunction.QueryRowContext(ctx, fn.PackageName, fn.Name, fn.FileName).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		var res sql.Result
+		res, err = t.insertFunction.ExecContext(ctx, fn.PackageName, fn.Name, fn.FileName)
+		if err == nil {
+			id, err = res.LastInsertId()
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	t.functions[fn] = id
+	return id, nil
+}
+
+// add stores a single benchmark trial of the run.
+func (t *trialInserter) add(ctx context.Context, run *Run, result microbenchmark.Result) error {
+	versionID, ok := run.Versions[result.Version]
+	if !ok {
+		return fmt.Errorf("unknown version: %d", result.Version)
+	}
+	functionID, err := t.functionID(ctx, result.Function)
+	if err != nil {
+		return err
+	}
+	_, err = t.insertTrial.ExecContext(ctx,
+		versionID, functionID, result.R, result.S, result.I, result.Iterations, result.Ops, result.Bytes, result.Allocs,
+	)
+	return err
+}
+
+func (t *trialInserter) Close() error {
+	var err error
+	for _, stmt := range []*sql.Stmt{t.selectFunction, t.insertFunction, t.insertTrial} {
+		if stmt != nil {
+			err = merror.MaybeMultiError(err, stmt.Close())
+		}
+	}
+	return err
+}
+
+// Results returns all stored trials of the runs that match the filter.
+func (s *Store) Results(ctx context.Context, filter QueryFilter) (microbenchmark.Results, error) {
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT functions.package, functions.name, functions.file_name, versions.version,
+		runs.run_index, trials.s, trials.i, trials.iterations, trials.sec_per_op, trials.bytes_per_op, trials.allocs_per_op
+	FROM trials
+	JOIN functions ON functions.id = trials.function_id
+	JOIN versions ON versions.id = trials.version_id
+	JOIN runs ON runs.id = versions.run_id
+	WHERE `+where+` ORDER BY trials.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make(microbenchmark.Results, 0)
+	for rows.Next() {
+		var r microbenchmark.Result
+		err := rows.Scan(&r.Function.PackageName, &r.Function.Name, &r.Function.FileName, &r.Version,
+			&r.R, &r.S, &r.I, &r.Iterations, &r.Ops, &r.Bytes, &r.Allocs)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
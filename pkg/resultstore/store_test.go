@@ -0,0 +1,122 @@
+package resultstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreQueries(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "results.db")
+	fn := microbenchmark.Function{PackageName: "pkg", Name: "BenchmarkA", FileName: "a_test.go"}
+	metadata := &microbenchmark.Metadata{
+		V1: microbenchmark.VersionInfo{Reference: "main", Commit: "aaaaaaaaaaaa"},
+		V2: microbenchmark.VersionInfo{Reference: "feature", Commit: "bbbbbbbbbbbb"},
+	}
+	for run := 0; run < 2; run++ {
+		w, err := NewResultWriter(ctx, dbPath, "test", metadata)
+		require.NoError(t, err)
+		for i, ops := range []float64{1, 2, 3} {
+			require.NoError(t, w.Write(microbenchmark.Result{R: run, I: i, Function: fn, Version: 1, Ops: ops}))
+			require.NoError(t, w.Write(microbenchmark.Result{R: run, I: i, Function: fn, Version: 2, Ops: ops * 2}))
+		}
+		require.NoError(t, w.Close())
+	}
+
+	store, err := Open(ctx, dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, rows, err := queryRuns(ctx, store, QueryFilter{})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, "6", rows[0][10])
+
+	_, rows, err = queryMedians(ctx, store, QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"pkg.BenchmarkA", "feature", "bbbbbbbb", "6", "4", "0", "0"},
+		{"pkg.BenchmarkA", "main", "aaaaaaaa", "6", "2", "0", "0"},
+	}, rows)
+
+	_, rows, err = queryRatios(ctx, store, QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"main", "feature", "pkg.BenchmarkA", "6", "6", "2", "4", "2"}}, rows)
+
+	_, rows, err = queryRuns(ctx, store, QueryFilter{Label: "other"})
+	require.NoError(t, err)
+	require.Len(t, rows, 0)
+}
+
+func TestIngestAndHistory(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, filepath.Join(t.TempDir(), "results.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	fn := microbenchmark.Function{PackageName: "pkg", Name: "BenchmarkA", FileName: "a_test.go"}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	// two experiments comparing consecutive commits: a -> b and b -> c
+	for i, commits := range [][2]string{{"a", "b"}, {"b", "c"}} {
+		metadata := &microbenchmark.Metadata{
+			V1:        microbenchmark.VersionInfo{Reference: commits[0], Commit: commits[0]},
+			V2:        microbenchmark.VersionInfo{Reference: commits[1], Commit: commits[1]},
+			StartTime: start.Add(time.Duration(i) * time.Hour),
+		}
+		results := microbenchmark.Results{
+			{Function: fn, Version: 1, Ops: float64(i + 1)},
+			{Function: fn, Version: 2, Ops: float64(i + 2)},
+		}
+		ingested, err := store.Ingest(ctx, "experiment-"+commits[1], "", metadata, results)
+		require.NoError(t, err)
+		require.True(t, ingested)
+		ingested, err = store.Ingest(ctx, "experiment-"+commits[1], "", metadata, results)
+		require.NoError(t, err)
+		require.False(t, ingested)
+	}
+
+	series, err := store.History(ctx, QueryFilter{}, "ops")
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	require.Equal(t, "pkg.BenchmarkA", series[0].Function)
+	commits := make([]string, 0)
+	for _, c := range series[0].Commits {
+		commits = append(commits, c.ID())
+	}
+	require.Equal(t, []string{"a", "b", "c"}, commits)
+	require.Equal(t, []float64{1, 2, 3}, series[0].Medians)
+	require.Equal(t, []int{1, 2, 1}, series[0].Trials)
+}
+
+func TestIngestRollback(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, filepath.Join(t.TempDir(), "results.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	fn := microbenchmark.Function{PackageName: "pkg", Name: "BenchmarkA", FileName: "a_test.go"}
+	metadata := &microbenchmark.Metadata{RunIndex: 1, EndTime: time.Now()}
+	results := microbenchmark.Results{
+		{Function: fn, Version: 1, Ops: 1},
+		{Function: fn, Version: 3, Ops: 2},
+	}
+	_, err = store.Ingest(ctx, "experiment", "", metadata, results)
+	require.ErrorContains(t, err, "unknown version: 3")
+	_, rows, err := queryRuns(ctx, store, QueryFilter{})
+	require.NoError(t, err)
+	require.Len(t, rows, 0)
+
+	// the failed run can be ingested again
+	results[1].Version = 2
+	ingested, err := store.Ingest(ctx, "experiment", "", metadata, results)
+	require.NoError(t, err)
+	require.True(t, ingested)
+	stored, err := store.Results(ctx, QueryFilter{})
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+}
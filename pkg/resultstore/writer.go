@@ -0,0 +1,57 @@
+package resultstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/christophwitzko/masters-thesis/pkg/merror"
+	"github.com/christophwitzko/masters-thesis/pkg/microbenchmark"
+)
+
+type resultWriter struct {
+	ctx        context.Context
+	store      *Store
+	run        *Run
+	inserter   *trialInserter
+	writeMutex sync.Mutex
+}
+
+// NewResultWriter opens the database and creates a new run to which all
+// written results are added.
+func NewResultWriter(ctx context.Context, fileName, label string, metadata *microbenchmark.Metadata) (microbenchmark.ResultWriter, error) {
+	store, err := Open(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+	run, err := store.CreateRun(ctx, label, metadata)
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	inserter, err := newTrialInserter(ctx, store.db)
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	return &resultWriter{
+		ctx:      ctx,
+		store:    store,
+		run:      run,
+		inserter: inserter,
+	}, nil
+}
+
+func (w *resultWriter) Write(result microbenchmark.Result) error {
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+	return w.inserter.add(w.ctx, w.run, result)
+}
+
+func (w *resultWriter) Close() error {
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+	// use a fresh context as the run context might already be canceled
+	finishErr := w.store.FinishRun(context.Background(), w.run, time.Now())
+	return merror.MaybeMultiError(finishErr, w.inserter.Close(), w.store.Close())
+}
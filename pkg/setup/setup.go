@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 
+	"github.com/christophwitzko/masters-thesis/pkg/application"
 	"github.com/christophwitzko/masters-thesis/pkg/git"
 	"github.com/christophwitzko/masters-thesis/pkg/logger"
 )
@@ -63,3 +64,31 @@ func ApplicationBenchmarkPath(log *logger.Logger, checkoutDir, gitRepository, re
 	}
 	return checkoutDir, nil
 }
+
+// VersionSourcePaths returns the source path of each version. If a git
+// repository is given, each version is checked out into its own directory
+// named after its label.
+func VersionSourcePaths(log *logger.Logger, checkoutDir, gitRepository string, versions []application.Version) ([]string, error) {
+	sourcePaths := make([]string, len(versions))
+	if gitRepository == "" {
+		for i, v := range versions {
+			sourcePaths[i] = v.Source
+		}
+		return sourcePaths, nil
+	}
+	if err := CreateDirectory(checkoutDir); err != nil {
+		return nil, err
+	}
+	log.Infof("cloning %s", gitRepository)
+	checkoutOptions := make([]*git.CheckoutOption, len(versions))
+	for i, v := range versions {
+		sourcePaths[i] = path.Join(checkoutDir, v.Name)
+		log.Infof("checking out %s: %s (%s)", v.Name, v.Source, sourcePaths[i])
+		checkoutOptions[i] = git.NewCheckoutOption(sourcePaths[i], v.Source)
+	}
+	err := git.CloneAndCheckout(gitRepository, checkoutOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone or checkout %s: %w", gitRepository, err)
+	}
+	return sourcePaths, nil
+}
@@ -0,0 +1,119 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ChangePoint is a detected change in the distribution of a series. Index is
+// the first element of the new segment.
+type ChangePoint struct {
+	Index  int
+	Q      float64 // scaled energy distance between the segments
+	PValue float64 // of the permutation test
+}
+
+// energySplit returns the split of data that maximizes the scaled energy
+// distance (Q statistic with alpha = 1) between both parts. Both parts contain
+// at least minSize elements, ok is false if no such split exists.
+func energySplit(data []float64, minSize int) (index int, q float64, ok bool) {
+	n := len(data)
+	if minSize < 2 || n < 2*minSize {
+		return 0, 0, false
+	}
+	// rightSums[i] is the sum of the distances of element i to all later elements
+	rightSums := make([]float64, n)
+	// leftSums[j] is the sum of the distances of element j to all earlier elements
+	leftSums := make([]float64, n)
+	total := 0.0
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := math.Abs(data[i] - data[j])
+			rightSums[i] += d
+			leftSums[j] += d
+			total += d
+		}
+	}
+	// withinLeft[t] is the sum of all pair distances of data[:t], withinRight[t] of data[t:]
+	withinLeft := make([]float64, n+1)
+	for t := 1; t <= n; t++ {
+		withinLeft[t] = withinLeft[t-1] + leftSums[t-1]
+	}
+	withinRight := make([]float64, n+1)
+	for t := n - 1; t >= 0; t-- {
+		withinRight[t] = withinRight[t+1] + rightSums[t]
+	}
+	q = math.Inf(-1)
+	for t := minSize; t <= n-minSize; t++ {
+		left, right := float64(t), float64(n-t)
+		between := total - withinLeft[t] - withinRight[t]
+		energy := 2*between/(left*right) - withinLeft[t]/(left*(left-1)/2) - withinRight[t]/(right*(right-1)/2)
+		if tq := left * right / float64(n) * energy; tq > q {
+			index, q = t, tq
+		}
+	}
+	return index, q, true
+}
+
+// permutationPValue estimates the probability of observing a split with a Q
+// statistic of at least q if the segment has no change point.
+func permutationPValue(rng *rand.Rand, data []float64, minSize int, q float64, permutations int) float64 {
+	shuffled := make([]float64, len(data))
+	copy(shuffled, data)
+	exceeded := 0
+	for i := 0; i < permutations; i++ {
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		if _, pq, ok := energySplit(shuffled, minSize); ok && pq >= q {
+			exceeded++
+		}
+	}
+	return float64(exceeded+1) / float64(permutations+1)
+}
+
+type segmentCandidate struct {
+	start, end int
+	index      int
+	q          float64
+}
+
+// EDivisive detects change points in the data with the E-Divisive means
+// method (hierarchical divisive segmentation based on energy statistics,
+// Matteson and James 2014). In each step the split with the highest Q
+// statistic of all segments is tested with a permutation test and accepted
+// if its p-value is below the significance level. Segments contain at least
+// minSize (>= 2) elements. The change points are sorted by index.
+func EDivisive(rng *rand.Rand, data []float64, minSize, permutations int, significanceLevel float64) []ChangePoint {
+	segments := [][2]int{{0, len(data)}}
+	changePoints := make([]ChangePoint, 0)
+	for {
+		var best *segmentCandidate
+		for _, segment := range segments {
+			index, q, ok := energySplit(data[segment[0]:segment[1]], minSize)
+			if ok && (best == nil || q > best.q) {
+				best = &segmentCandidate{start: segment[0], end: segment[1], index: segment[0] + index, q: q}
+			}
+		}
+		if best == nil {
+			break
+		}
+		pValue := permutationPValue(rng, data[best.start:best.end], minSize, best.q, permutations)
+		if pValue > significanceLevel {
+			break
+		}
+		changePoints = append(changePoints, ChangePoint{Index: best.index, Q: best.q, PValue: pValue})
+		newSegments := make([][2]int, 0, len(segments)+1)
+		for _, segment := range segments {
+			if segment[0] == best.start {
+				newSegments = append(newSegments, [2]int{best.start, best.index}, [2]int{best.index, best.end})
+				continue
+			}
+			newSegments = append(newSegments, segment)
+		}
+		segments = newSegments
+	}
+	sort.Slice(changePoints, func(i, j int) bool {
+		return changePoints[i].Index < changePoints[j].Index
+	})
+	return changePoints
+}
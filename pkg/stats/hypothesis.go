@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// ranks returns the ranks (starting at 1) of the values, ties get the average
+// rank. tieSum is the sum of t^3 - t over all groups of t tied values.
+func ranks(values []float64) (r []float64, tieSum float64) {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return values[idx[i]] < values[idx[j]] })
+	r = make([]float64, len(values))
+	for start := 0; start < len(idx); {
+		end := start + 1
+		for end < len(idx) && values[idx[end]] == values[idx[start]] {
+			end++
+		}
+		avg := float64(start+end+1) / 2
+		for k := start; k < end; k++ {
+			r[idx[k]] = avg
+		}
+		t := float64(end - start)
+		tieSum += t*t*t - t
+		start = end
+	}
+	return r, tieSum
+}
+
+// KruskalWallis tests whether all groups come from the same distribution
+// (Kruskal-Wallis H test with tie correction). The p-value uses the
+// chi-squared approximation with len(groups)-1 degrees of freedom. Empty
+// groups are ignored, the p-value is NaN if less than two groups remain.
+func KruskalWallis(groups [][]float64) (h, pValue float64) {
+	values := make([]float64, 0)
+	sizes := make([]int, 0, len(groups))
+	for _, g := range groups {
+		if len(g) == 0 {
+			continue
+		}
+		values = append(values, g...)
+		sizes = append(sizes, len(g))
+	}
+	if len(sizes) < 2 {
+		return math.NaN(), math.NaN()
+	}
+	r, tieSum := ranks(values)
+	n := float64(len(values))
+	offset := 0
+	for _, size := range sizes {
+		sum := 0.0
+		for _, v := range r[offset : offset+size] {
+			sum += v
+		}
+		h += sum * sum / float64(size)
+		offset += size
+	}
+	h = 12/(n*(n+1))*h - 3*(n+1)
+	correction := 1 - tieSum/(n*n*n-n)
+	if correction <= 0 {
+		// all values are equal
+		return 0, 1
+	}
+	h /= correction
+	return h, ChiSquareSurvival(h, float64(len(sizes)-1))
+}
+
+// MannKendall tests the data for a monotonic trend (Mann-Kendall test with
+// tie correction and normal approximation). s is positive for increasing
+// and negative for decreasing values.
+func MannKendall(data []float64) (s, pValue float64) {
+	n := len(data)
+	if n < 3 {
+		return 0, 1
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			switch {
+			case data[j] > data[i]:
+				s++
+			case data[j] < data[i]:
+				s--
+			}
+		}
+	}
+	variance := float64(n*(n-1)*(2*n+5)) / 18
+	counts := make(map[float64]int)
+	for _, v := range data {
+		counts[v]++
+	}
+	for _, t := range counts {
+		variance -= float64(t*(t-1)*(2*t+5)) / 18
+	}
+	if variance <= 0 {
+		return s, 1
+	}
+	z := 0.0
+	switch {
+	case s > 0:
+		z = (s - 1) / math.Sqrt(variance)
+	case s < 0:
+		z = (s + 1) / math.Sqrt(variance)
+	}
+	return s, math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// BimodalityCoefficient returns the sample bimodality coefficient
+// (skewness^2 + 1) / (excess kurtosis + 3(n-1)^2 / ((n-2)(n-3))). Values above
+// 5/9 (the value of the uniform distribution) indicate a bimodal or
+// multimodal distribution. It is NaN for less than 4 values and 0 for
+// constant data.
+func BimodalityCoefficient(data []float64) float64 {
+	n := float64(len(data))
+	if len(data) < 4 {
+		return math.NaN()
+	}
+	mean := Mean(data)
+	var m2, m3, m4 float64
+	for _, v := range data {
+		d := v - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	if m2 == 0 {
+		return 0
+	}
+	skewness := m3 / math.Pow(m2, 1.5) * math.Sqrt(n*(n-1)) / (n - 2)
+	kurtosis := ((n+1)*(m4/(m2*m2)-3) + 6) * (n - 1) / ((n - 2) * (n - 3))
+	return (skewness*skewness + 1) / (kurtosis + 3*(n-1)*(n-1)/((n-2)*(n-3)))
+}
+
+// ChiSquareSurvival returns P(X > x) of the chi-squared distribution with df
+// degrees of freedom.
+func ChiSquareSurvival(x, df float64) float64 {
+	return regularizedGammaQ(df/2, x/2)
+}
+
+// regularizedGammaQ is the regularized upper incomplete gamma function Q(a, x).
+func regularizedGammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	lg, _ := math.Lgamma(a)
+	prefactor := math.Exp(-x + a*math.Log(x) - lg)
+	if x < a+1 {
+		// series expansion of P(a, x)
+		term := 1 / a
+		sum := term
+		for k := 1; k < 1000; k++ {
+			term *= x / (a + float64(k))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return 1 - sum*prefactor
+	}
+	// continued fraction of Q(a, x) (modified Lentz's method)
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for k := 1; k < 1000; k++ {
+		an := -float64(k) * (float64(k) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-15 {
+			break
+		}
+	}
+	return prefactor * h
+}
@@ -0,0 +1,169 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+func sortedCopy(data []float64) []float64 {
+	sorted := make([]float64, len(data))
+	copy(sorted, data)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+func medianOfSorted(sorted []float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// Median returns the median of the data or NaN if the data is empty.
+func Median(data []float64) float64 {
+	return medianOfSorted(sortedCopy(data))
+}
+
+// Percentile returns the p-th percentile (0-100) of the data using linear
+// interpolation between the closest ranks (same as numpy.percentile).
+func Percentile(data []float64, p float64) float64 {
+	return percentileOfSorted(sortedCopy(data), p)
+}
+
+func percentileOfSorted(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	// equal values are returned as is, interpolating between infinite values
+	// would be NaN
+	if lower == upper || sorted[lower] == sorted[upper] {
+		return sorted[lower]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+// resampleMedian draws len(data) samples with replacement and returns their median.
+func resampleMedian(rng *rand.Rand, data, buf []float64) float64 {
+	for i := range buf {
+		buf[i] = data[rng.Intn(len(data))]
+	}
+	sort.Float64s(buf)
+	return medianOfSorted(buf)
+}
+
+// confidenceBounds returns the percentiles of the bootstrap distribution
+// that bound the given confidence level (e.g. 99 -> 0.5 and 99.5).
+func confidenceBounds(dist []float64, confidenceLevel float64) (float64, float64) {
+	sort.Float64s(dist)
+	lower := (100 - confidenceLevel) / 2
+	return percentileOfSorted(dist, lower), percentileOfSorted(dist, confidenceLevel+lower)
+}
+
+// BootstrapMedianRatioCI returns the percentile bootstrap confidence interval
+// of the ratio median(v2)/median(v1).
+func BootstrapMedianRatioCI(rng *rand.Rand, v1, v2 []float64, iterations int, confidenceLevel float64) (float64, float64) {
+	if len(v1) == 0 || len(v2) == 0 || iterations <= 0 {
+		return math.NaN(), math.NaN()
+	}
+	return confidenceBounds(bootstrapMedianRatios(rng, v1, v2, iterations), confidenceLevel)
+}
+
+func bootstrapMedianRatios(rng *rand.Rand, v1, v2 []float64, iterations int) []float64 {
+	bufV1 := make([]float64, len(v1))
+	bufV2 := make([]float64, len(v2))
+	dist := make([]float64, iterations)
+	for i := range dist {
+		dist[i] = resampleMedian(rng, v2, bufV2) / resampleMedian(rng, v1, bufV1)
+	}
+	return dist
+}
+
+// BootstrapMedianRatioCIs returns the percentile bootstrap confidence
+// intervals of the ratio median(v2)/median(v1) for several confidence levels
+// based on the same bootstrap distribution.
+func BootstrapMedianRatioCIs(rng *rand.Rand, v1, v2 []float64, iterations int, confidenceLevels []float64) (lower, upper []float64) {
+	lower = make([]float64, len(confidenceLevels))
+	upper = make([]float64, len(confidenceLevels))
+	if len(v1) == 0 || len(v2) == 0 || iterations <= 0 {
+		for i := range confidenceLevels {
+			lower[i], upper[i] = math.NaN(), math.NaN()
+		}
+		return lower, upper
+	}
+	dist := bootstrapMedianRatios(rng, v1, v2, iterations)
+	for i, confidenceLevel := range confidenceLevels {
+		lower[i], upper[i] = confidenceBounds(dist, confidenceLevel)
+	}
+	return lower, upper
+}
+
+// Mean returns the arithmetic mean of the data or NaN if the data is empty.
+func Mean(data []float64) float64 {
+	if len(data) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, v := range data {
+		sum += v
+	}
+	return sum / float64(len(data))
+}
+
+// relative returns the spread relative to the center. Data without spread
+// has no variability, even if the center is zero (e.g. zero allocations).
+func relative(spread, center float64) float64 {
+	if spread == 0 {
+		return 0
+	}
+	return spread / center
+}
+
+// CV returns the coefficient of variation (population standard deviation
+// divided by the mean).
+func CV(data []float64) float64 {
+	mean := Mean(data)
+	sum := 0.0
+	for _, v := range data {
+		sum += (v - mean) * (v - mean)
+	}
+	return relative(math.Sqrt(sum/float64(len(data))), mean)
+}
+
+// RMAD returns the relative median absolute deviation (median absolute
+// deviation divided by the median).
+func RMAD(data []float64) float64 {
+	median := Median(data)
+	deviations := make([]float64, len(data))
+	for i, v := range data {
+		deviations[i] = math.Abs(v - median)
+	}
+	return relative(Median(deviations), median)
+}
+
+// BootstrapMedianCI returns the percentile bootstrap confidence interval of the median.
+func BootstrapMedianCI(rng *rand.Rand, data []float64, iterations int, confidenceLevel float64) (float64, float64) {
+	if len(data) == 0 || iterations <= 0 {
+		return math.NaN(), math.NaN()
+	}
+	buf := make([]float64, len(data))
+	dist := make([]float64, iterations)
+	for i := range dist {
+		dist[i] = resampleMedian(rng, data, buf)
+	}
+	return confidenceBounds(dist, confidenceLevel)
+}
+
+// RCIW returns the relative confidence interval width of the median
+// (width of the percentile bootstrap confidence interval divided by the median).
+func RCIW(rng *rand.Rand, data []float64, iterations int, confidenceLevel float64) float64 {
+	lower, upper := BootstrapMedianCI(rng, data, iterations, confidenceLevel)
+	return relative(math.Abs(upper-lower), Median(data))
+}
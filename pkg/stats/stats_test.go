@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMedianAndPercentile(t *testing.T) {
+	require.True(t, math.IsNaN(Median(nil)))
+	require.Equal(t, 2.0, Median([]float64{3, 1, 2}))
+	require.Equal(t, 2.5, Median([]float64{4, 1, 3, 2}))
+	// same values as numpy.percentile([1, 2, 3, 4], [25, 50, 99.5])
+	require.Equal(t, 1.75, Percentile([]float64{4, 3, 2, 1}, 25))
+	require.Equal(t, 2.5, Percentile([]float64{4, 3, 2, 1}, 50))
+	require.InDelta(t, 3.985, Percentile([]float64{4, 3, 2, 1}, 99.5), 1e-9)
+	require.True(t, math.IsInf(Percentile([]float64{1, math.Inf(1), math.Inf(1)}, 75), 1))
+}
+
+func TestVariability(t *testing.T) {
+	data := []float64{1, 2, 3, 4, 5}
+	require.Equal(t, 3.0, Mean(data))
+	require.InDelta(t, math.Sqrt(2)/3, CV(data), 1e-9)
+	require.InDelta(t, 1.0/3, RMAD(data), 1e-9)
+	require.Equal(t, 0.0, RCIW(rand.New(rand.NewSource(1)), []float64{2, 2, 2}, 100, 99))
+
+	zeros := []float64{0, 0, 0}
+	require.Equal(t, 0.0, CV(zeros))
+	require.Equal(t, 0.0, RMAD(zeros))
+	require.Equal(t, 0.0, RCIW(rand.New(rand.NewSource(1)), zeros, 100, 99))
+	require.True(t, math.IsNaN(CV(nil)))
+}
+
+func TestEDivisive(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]float64, 0, 60)
+	for _, level := range []float64{10, 12, 9} {
+		for i := 0; i < 20; i++ {
+			data = append(data, level+rng.NormFloat64()*0.2)
+		}
+	}
+	changePoints := EDivisive(rand.New(rand.NewSource(42)), data, 3, 199, 0.05)
+	require.Len(t, changePoints, 2)
+	require.Equal(t, 20, changePoints[0].Index)
+	require.Equal(t, 40, changePoints[1].Index)
+
+	noise := make([]float64, 40)
+	for i := range noise {
+		noise[i] = 10 + rng.NormFloat64()*0.2
+	}
+	require.Empty(t, EDivisive(rand.New(rand.NewSource(42)), noise, 3, 199, 0.05))
+	require.Empty(t, EDivisive(rand.New(rand.NewSource(42)), []float64{1, 2, 3}, 3, 199, 0.05))
+}
+
+func TestHypothesisTests(t *testing.T) {
+	// same values as scipy.stats.chi2.sf
+	require.InDelta(t, 0.05, ChiSquareSurvival(3.841458820694124, 1), 1e-9)
+	require.InDelta(t, 0.05, ChiSquareSurvival(18.307038053275146, 10), 1e-9)
+	// Q(1.5, 1) = 1 - (erf(1) - 2/sqrt(pi) * exp(-1))
+	require.InDelta(t, 0.5724067044708798, ChiSquareSurvival(2, 3), 1e-9)
+
+	// same values as scipy.stats.kruskal
+	h, p := KruskalWallis([][]float64{{1, 3, 5, 7, 9}, {2, 4, 6, 8, 10}})
+	require.InDelta(t, 0.2727272727, h, 1e-9)
+	require.InDelta(t, 0.6015081344405899, p, 1e-9)
+	_, p = KruskalWallis([][]float64{{1, 2, 3, 4, 5}, {11, 12, 13, 14, 15}, {21, 22, 23, 24, 25}})
+	require.Less(t, p, 0.01)
+
+	s, p := MannKendall([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	require.Equal(t, 45.0, s)
+	require.Less(t, p, 0.001)
+	_, p = MannKendall([]float64{3, 1, 4, 1, 5, 9, 2, 6, 5, 3})
+	require.Greater(t, p, 0.05)
+
+	rng := rand.New(rand.NewSource(1))
+	normal := make([]float64, 200)
+	bimodal := make([]float64, 200)
+	for i := range normal {
+		normal[i] = rng.NormFloat64()
+		bimodal[i] = rng.NormFloat64()*0.1 + float64(i%2)*2
+	}
+	require.Less(t, BimodalityCoefficient(normal), 5.0/9)
+	require.Greater(t, BimodalityCoefficient(bimodal), 5.0/9)
+}
@@ -0,0 +1,178 @@
+package svgplot
+
+import (
+	"fmt"
+	"html"
+	"math/rand"
+	"sort"
+)
+
+// Group is a named set of values that is drawn as one strip of points.
+type Group struct {
+	Name   string
+	Values []float64
+}
+
+// StripPlot draws the values of each group as jittered points together with
+// a line at the median of the group.
+func StripPlot(title, yLabel string, groups []Group) string {
+	c := newCanvas(420, 300, title)
+	allValues := make([][]float64, 0, len(groups))
+	for _, g := range groups {
+		allValues = append(allValues, g.Values)
+	}
+	yMin, yMax := dataRange(allValues...)
+	yMin, yMax = pad(yMin, yMax, 0.05)
+	c.setRange(0, float64(len(groups)), yMin, yMax)
+	c.axes("", yLabel, false, true)
+
+	// fixed seed to make the jitter (and hence the report) reproducible
+	rng := rand.New(rand.NewSource(1))
+	fmt.Fprintf(&c.b, `<g clip-path="url(#%s)">`, c.clipID)
+	for i, g := range groups {
+		center := float64(i) + 0.5
+		for _, v := range g.Values {
+			if !finite(v) {
+				continue
+			}
+			jitter := (rng.Float64() - 0.5) * 0.5
+			fmt.Fprintf(&c.b, `<circle cx="%s" cy="%s" r="2.5" fill="%s" fill-opacity="0.5"/>`, f(c.x(center+jitter)), f(c.y(v)), Color(i))
+		}
+		if median, ok := median(g.Values); ok {
+			fmt.Fprintf(&c.b, `<line x1="%s" x2="%s" y1="%s" y2="%s" stroke="#000" stroke-width="2"/>`,
+				f(c.x(center-0.3)), f(c.x(center+0.3)), f(c.y(median)), f(c.y(median)))
+		}
+	}
+	c.b.WriteString(`</g>`)
+	for i, g := range groups {
+		fmt.Fprintf(&c.b, `<text x="%s" y="%s" text-anchor="middle">%s (n=%d)</text>`,
+			f(c.x(float64(i)+0.5)), f(marginTop+c.plotH+15), html.EscapeString(g.Name), len(g.Values))
+	}
+	return c.String()
+}
+
+func median(values []float64) (float64, bool) {
+	finiteValues := make([]float64, 0, len(values))
+	for _, v := range values {
+		if finite(v) {
+			finiteValues = append(finiteValues, v)
+		}
+	}
+	if len(finiteValues) == 0 {
+		return 0, false
+	}
+	sort.Float64s(finiteValues)
+	mid := len(finiteValues) / 2
+	if len(finiteValues)%2 == 0 {
+		return (finiteValues[mid-1] + finiteValues[mid]) / 2, true
+	}
+	return finiteValues[mid], true
+}
+
+// Estimate is a point estimate together with its confidence interval.
+type Estimate struct {
+	Label        string
+	Value        float64
+	Lower, Upper float64
+	Color        string
+}
+
+// ForestPlot draws one row per estimate with its confidence interval and a
+// vertical reference line (e.g. at a ratio of 1).
+func ForestPlot(title, xLabel string, estimates []Estimate, reference float64) string {
+	const rowHeight = 22.0
+	labelWidth := 0
+	for _, e := range estimates {
+		if len(e.Label) > labelWidth {
+			labelWidth = len(e.Label)
+		}
+	}
+	offset := float64(labelWidth)*6.5 + 10 - marginLeft
+	if offset < 0 {
+		offset = 0
+	}
+	c := newCanvas(720+offset, marginTop+marginBottom+rowHeight*float64(len(estimates)+1), title)
+	lowers := make([]float64, 0, len(estimates))
+	uppers := make([]float64, 0, len(estimates))
+	for _, e := range estimates {
+		lowers = append(lowers, e.Lower, e.Value)
+		uppers = append(uppers, e.Upper, e.Value)
+	}
+	xMin, xMax := dataRange(lowers, uppers, []float64{reference})
+	xMin, xMax = pad(xMin, xMax, 0.05)
+	c.setRange(xMin, xMax, 0, float64(len(estimates)+1))
+	// shift the plot area to the right to make room for the labels
+	fmt.Fprintf(&c.b, `<g transform="translate(%s 0)">`, f(offset))
+	c.plotW -= offset
+	c.axes(xLabel, "", true, false)
+	refX := c.x(reference)
+	fmt.Fprintf(&c.b, `<line x1="%s" x2="%s" y1="%s" y2="%s" stroke="#888" stroke-dasharray="4 3"/>`, f(refX), f(refX), f(marginTop), f(marginTop+c.plotH))
+	for i, e := range estimates {
+		y := c.y(float64(len(estimates) - i))
+		fmt.Fprintf(&c.b, `<text x="%s" y="%s" text-anchor="end" dominant-baseline="middle">%s</text>`, f(marginLeft-5), f(y), html.EscapeString(e.Label))
+		if !finite(e.Value, e.Lower, e.Upper) {
+			continue
+		}
+		color := e.Color
+		if color == "" {
+			color = Color(0)
+		}
+		fmt.Fprintf(&c.b, `<line x1="%s" x2="%s" y1="%s" y2="%s" stroke="%s" stroke-width="2"/>`, f(c.x(e.Lower)), f(c.x(e.Upper)), f(y), f(y), color)
+		fmt.Fprintf(&c.b, `<rect x="%s" y="%s" width="7" height="7" fill="%s"><title>%s: %s [%s, %s]</title></rect>`,
+			f(c.x(e.Value)-3.5), f(y-3.5), color, html.EscapeString(e.Label), formatTick(e.Value), formatTick(e.Lower), formatTick(e.Upper))
+	}
+	c.b.WriteString(`</g>`)
+	return c.String()
+}
+
+// Series is a named set of points.
+type Series struct {
+	Name string
+	X, Y []float64
+}
+
+// Range is a highlighted range of the x-axis.
+type Range struct {
+	Label    string
+	From, To float64
+}
+
+// ScatterPlot draws the points of all series. If highlight is not nil, the
+// x range is shaded.
+func ScatterPlot(title, xLabel, yLabel string, series []Series, highlight *Range) string {
+	c := newCanvas(720, 320, title)
+	xs := make([][]float64, 0, len(series))
+	ys := make([][]float64, 0, len(series))
+	for _, s := range series {
+		xs = append(xs, s.X)
+		ys = append(ys, s.Y)
+	}
+	xMin, xMax := dataRange(xs...)
+	yMin, yMax := dataRange(ys...)
+	yMin, yMax = pad(yMin, yMax, 0.05)
+	c.setRange(xMin, xMax, yMin, yMax)
+	if highlight != nil {
+		from, to := c.x(highlight.From), c.x(highlight.To)
+		fmt.Fprintf(&c.b, `<rect x="%s" y="%s" width="%s" height="%s" fill="#2ca02c" fill-opacity="0.12" clip-path="url(#%s)"><title>%s</title></rect>`,
+			f(from), f(marginTop), f(to-from), f(c.plotH), c.clipID, html.EscapeString(highlight.Label))
+	}
+	c.axes(xLabel, yLabel, true, true)
+	fmt.Fprintf(&c.b, `<g clip-path="url(#%s)">`, c.clipID)
+	for i, s := range series {
+		for j := range s.X {
+			if j >= len(s.Y) || !finite(s.X[j], s.Y[j]) {
+				continue
+			}
+			fmt.Fprintf(&c.b, `<circle cx="%s" cy="%s" r="1.8" fill="%s" fill-opacity="0.6"/>`, f(c.x(s.X[j])), f(c.y(s.Y[j])), Color(i))
+		}
+	}
+	c.b.WriteString(`</g>`)
+	names := make([]string, 0, len(series))
+	colors := make([]string, 0, len(series))
+	for i, s := range series {
+		names = append(names, s.Name)
+		colors = append(colors, Color(i))
+	}
+	c.legend(names, colors)
+	return c.String()
+}
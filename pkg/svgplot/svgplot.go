@@ -0,0 +1,184 @@
+package svgplot
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Colors is the palette used for the series of a plot (v1, v2, ...).
+var Colors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b"}
+
+func Color(i int) string {
+	return Colors[i%len(Colors)]
+}
+
+const (
+	marginLeft   = 70.0
+	marginRight  = 20.0
+	marginTop    = 30.0
+	marginBottom = 45.0
+)
+
+// canvas maps data coordinates to the drawing area of an svg image.
+type canvas struct {
+	b             strings.Builder
+	width, height float64
+	xMin, xMax    float64
+	yMin, yMax    float64
+	plotW, plotH  float64
+	clipID        string
+}
+
+// clipIDs makes the clip path ids unique if multiple plots are embedded in the same document
+var clipIDs atomic.Int64
+
+func newCanvas(width, height float64, title string) *canvas {
+	c := &canvas{
+		width:  width,
+		height: height,
+		plotW:  width - marginLeft - marginRight,
+		plotH:  height - marginTop - marginBottom,
+		clipID: fmt.Sprintf("plot-area-%d", clipIDs.Add(1)),
+	}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" font-family="sans-serif" font-size="11">`,
+		f(width), f(height), f(width), f(height))
+	fmt.Fprintf(&c.b, `<clipPath id="%s"><rect x="%s" y="%s" width="%s" height="%s"/></clipPath>`,
+		c.clipID, f(marginLeft), f(marginTop), f(c.plotW), f(c.plotH))
+	fmt.Fprintf(&c.b, `<rect width="100%%" height="100%%" fill="white"/>`)
+	if title != "" {
+		fmt.Fprintf(&c.b, `<text x="%s" y="18" text-anchor="middle" font-size="13" font-weight="bold">%s</text>`, f(width/2), html.EscapeString(title))
+	}
+	return c
+}
+
+func f(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// setRange sets the data range and adds a small padding.
+func (c *canvas) setRange(xMin, xMax, yMin, yMax float64) {
+	if xMin == xMax {
+		xMin, xMax = xMin-1, xMax+1
+	}
+	if yMin == yMax {
+		yMin, yMax = yMin-1, yMax+1
+	}
+	c.xMin, c.xMax, c.yMin, c.yMax = xMin, xMax, yMin, yMax
+}
+
+func (c *canvas) x(v float64) float64 {
+	return marginLeft + (v-c.xMin)/(c.xMax-c.xMin)*c.plotW
+}
+
+func (c *canvas) y(v float64) float64 {
+	return marginTop + c.plotH - (v-c.yMin)/(c.yMax-c.yMin)*c.plotH
+}
+
+// niceTicks returns about n round tick values between min and max.
+func niceTicks(min, max float64, n int) []float64 {
+	span := max - min
+	if span <= 0 || math.IsNaN(span) || math.IsInf(span, 0) {
+		return []float64{min}
+	}
+	step := math.Pow(10, math.Floor(math.Log10(span/float64(n))))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if span/(step*m) <= float64(n) {
+			step *= m
+			break
+		}
+	}
+	ticks := make([]float64, 0)
+	for t := math.Ceil(min/step) * step; t <= max+step*1e-9; t += step {
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+func formatTick(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	abs := math.Abs(v)
+	if abs >= 1e5 || abs < 1e-3 {
+		return strconv.FormatFloat(v, 'e', 2, 64)
+	}
+	// round to hide floating point errors of the tick steps (e.g. 1.5999999999999999)
+	return strconv.FormatFloat(v, 'g', 10, 64)
+}
+
+func (c *canvas) axes(xLabel, yLabel string, xTicks, yTicks bool) {
+	fmt.Fprintf(&c.b, `<rect x="%s" y="%s" width="%s" height="%s" fill="none" stroke="#333"/>`,
+		f(marginLeft), f(marginTop), f(c.plotW), f(c.plotH))
+	if yTicks {
+		for _, t := range niceTicks(c.yMin, c.yMax, 6) {
+			y := c.y(t)
+			fmt.Fprintf(&c.b, `<line x1="%s" x2="%s" y1="%s" y2="%s" stroke="#ddd"/>`, f(marginLeft), f(marginLeft+c.plotW), f(y), f(y))
+			fmt.Fprintf(&c.b, `<text x="%s" y="%s" text-anchor="end" dominant-baseline="middle">%s</text>`, f(marginLeft-5), f(y), formatTick(t))
+		}
+	}
+	if xTicks {
+		for _, t := range niceTicks(c.xMin, c.xMax, 8) {
+			x := c.x(t)
+			fmt.Fprintf(&c.b, `<line x1="%s" x2="%s" y1="%s" y2="%s" stroke="#ddd"/>`, f(x), f(x), f(marginTop), f(marginTop+c.plotH))
+			fmt.Fprintf(&c.b, `<text x="%s" y="%s" text-anchor="middle">%s</text>`, f(x), f(marginTop+c.plotH+15), formatTick(t))
+		}
+	}
+	if xLabel != "" {
+		fmt.Fprintf(&c.b, `<text x="%s" y="%s" text-anchor="middle">%s</text>`, f(marginLeft+c.plotW/2), f(c.height-8), html.EscapeString(xLabel))
+	}
+	if yLabel != "" {
+		fmt.Fprintf(&c.b, `<text transform="translate(14 %s) rotate(-90)" text-anchor="middle">%s</text>`, f(marginTop+c.plotH/2), html.EscapeString(yLabel))
+	}
+}
+
+func (c *canvas) legend(names []string, colors []string) {
+	x := marginLeft + 10
+	for i, name := range names {
+		fmt.Fprintf(&c.b, `<rect x="%s" y="%s" width="10" height="10" fill="%s"/>`, f(x), f(marginTop+6), colors[i])
+		fmt.Fprintf(&c.b, `<text x="%s" y="%s">%s</text>`, f(x+14), f(marginTop+15), html.EscapeString(name))
+		x += 24 + float64(len(name))*7
+	}
+}
+
+func (c *canvas) String() string {
+	return c.b.String() + "</svg>"
+}
+
+func finite(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// dataRange returns the minimum and maximum of all finite values.
+func dataRange(values ...[]float64) (float64, float64) {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, vs := range values {
+		for _, v := range vs {
+			if !finite(v) {
+				continue
+			}
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+	if math.IsInf(min, 1) {
+		return 0, 1
+	}
+	return min, max
+}
+
+func pad(min, max, fraction float64) (float64, float64) {
+	p := (max - min) * fraction
+	if p == 0 {
+		p = math.Abs(max) * fraction
+	}
+	return min - p, max + p
+}
@@ -0,0 +1,61 @@
+package table
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+var Formats = []string{"table", "csv", "json"}
+
+func IsValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the rows in the given format. The json format writes one
+// object per row using the header fields as keys.
+func Write(w io.Writer, format string, header []string, rows [][]string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(header); err != nil {
+			return err
+		}
+		if err := csvWriter.WriteAll(rows); err != nil {
+			return err
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	case "json":
+		jsonEncoder := json.NewEncoder(w)
+		for _, row := range rows {
+			obj := make(map[string]string, len(header))
+			for i, key := range header {
+				if i < len(row) {
+					obj[key] = row[i]
+				}
+			}
+			if err := jsonEncoder.Encode(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}